@@ -0,0 +1,162 @@
+package main
+
+import (
+	"math"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	common "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/common/v1"
+)
+
+// reachability marks the dictionary entries of a payload that are reachable
+// from its resource profiles, directly or through other entries, e.g. a
+// location through the stack of a sample. The zero value entries at index 0
+// are always reachable.
+type reachability struct {
+	strings    []bool
+	attributes []bool
+	mappings   []bool
+	functions  []bool
+	locations  []bool
+	links      []bool
+	stacks     []bool
+}
+
+// reachableEntries returns the reachability of the dictionary entries of
+// data. Out of range references are ignored.
+func reachableEntries(data *cprofiles.ExportProfilesServiceRequest) *reachability {
+	dict := data.GetDictionary()
+	r := &reachability{
+		strings:    make([]bool, len(dict.GetStringTable())),
+		attributes: make([]bool, len(dict.GetAttributeTable())),
+		mappings:   make([]bool, len(dict.GetMappingTable())),
+		functions:  make([]bool, len(dict.GetFunctionTable())),
+		locations:  make([]bool, len(dict.GetLocationTable())),
+		links:      make([]bool, len(dict.GetLinkTable())),
+		stacks:     make([]bool, len(dict.GetStackTable())),
+	}
+	for _, table := range r.tables() {
+		mark(table, 0)
+	}
+	var markKeyValues func(kvs []*common.KeyValue)
+	var markAnyValue func(av *common.AnyValue)
+	markKeyValues = func(kvs []*common.KeyValue) {
+		for _, kv := range kvs {
+			mark(r.strings, kv.GetKeyRef())
+			markAnyValue(kv.GetValue())
+		}
+	}
+	markAnyValue = func(av *common.AnyValue) {
+		switch v := av.GetValue().(type) {
+		case *common.AnyValue_StringRef:
+			mark(r.strings, v.StringRef)
+		case *common.AnyValue_ArrayValue:
+			for _, elem := range v.ArrayValue.GetValues() {
+				markAnyValue(elem)
+			}
+		case *common.AnyValue_KvlistValue:
+			markKeyValues(v.KvlistValue.GetValues())
+		}
+	}
+
+	// Entries only reference tables that are marked after them, so a single
+	// pass in this order marks everything that is reachable.
+	for _, rp := range data.GetResourceProfiles() {
+		markKeyValues(rp.GetResource().GetAttributes())
+		for _, sp := range rp.GetScopeProfiles() {
+			markKeyValues(sp.GetScope().GetAttributes())
+			for _, p := range sp.GetProfiles() {
+				mark(r.strings, p.GetSampleType().GetTypeStrindex())
+				mark(r.strings, p.GetSampleType().GetUnitStrindex())
+				mark(r.strings, p.GetPeriodType().GetTypeStrindex())
+				mark(r.strings, p.GetPeriodType().GetUnitStrindex())
+				markAll(r.attributes, p.GetAttributeIndices())
+				for _, s := range p.GetSamples() {
+					mark(r.stacks, s.GetStackIndex())
+					mark(r.links, s.GetLinkIndex())
+					markAll(r.attributes, s.GetAttributeIndices())
+				}
+			}
+		}
+	}
+	for i, stack := range dict.GetStackTable() {
+		if r.stacks[i] {
+			markAll(r.locations, stack.GetLocationIndices())
+		}
+	}
+	for i, loc := range dict.GetLocationTable() {
+		if !r.locations[i] {
+			continue
+		}
+		mark(r.mappings, loc.GetMappingIndex())
+		for _, line := range loc.GetLines() {
+			mark(r.functions, line.GetFunctionIndex())
+		}
+		markAll(r.attributes, loc.GetAttributeIndices())
+	}
+	for i, m := range dict.GetMappingTable() {
+		if r.mappings[i] {
+			mark(r.strings, m.GetFilenameStrindex())
+			markAll(r.attributes, m.GetAttributeIndices())
+		}
+	}
+	for i, fn := range dict.GetFunctionTable() {
+		if r.functions[i] {
+			mark(r.strings, fn.GetNameStrindex())
+			mark(r.strings, fn.GetSystemNameStrindex())
+			mark(r.strings, fn.GetFilenameStrindex())
+		}
+	}
+	for i, attr := range dict.GetAttributeTable() {
+		if r.attributes[i] {
+			mark(r.strings, attr.GetKeyStrindex())
+			mark(r.strings, attr.GetUnitStrindex())
+			markAnyValue(attr.GetValue())
+		}
+	}
+	return r
+}
+
+func (r *reachability) tables() [][]bool {
+	return [][]bool{r.strings, r.attributes, r.mappings, r.functions, r.locations, r.links, r.stacks}
+}
+
+func mark(table []bool, idx int32) {
+	if idx >= 0 && int(idx) < len(table) {
+		table[idx] = true
+	}
+}
+
+func markAll(table []bool, indices []int32) {
+	for _, idx := range indices {
+		mark(table, idx)
+	}
+}
+
+// dictionaryBloat counts the unreachable dictionary entries of payloads, over
+// all tables.
+type dictionaryBloat struct {
+	unreachable int
+	entries     int
+}
+
+// add counts the entries of the dictionary of data.
+func (b *dictionaryBloat) add(data *cprofiles.ExportProfilesServiceRequest) {
+	for _, table := range reachableEntries(data).tables() {
+		for _, reachable := range table {
+			if !reachable {
+				b.unreachable++
+			}
+		}
+		b.entries += len(table)
+	}
+}
+
+// score returns the fraction of the entries that are unreachable, or NaN if
+// there are no entries. A high score means the producer doesn't drop the
+// entries that are no longer used from its dictionary.
+func (b dictionaryBloat) score() float64 {
+	if b.entries == 0 {
+		return math.NaN()
+	}
+	return float64(b.unreachable) / float64(b.entries)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	common "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/common/v1"
+	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
+)
+
+func TestReachableEntries(t *testing.T) {
+	data := &cprofiles.ExportProfilesServiceRequest{
+		Dictionary: &profiles.ProfilesDictionary{
+			StringTable: []string{"", "main", "old", "libc.so", "thread.name", "worker", "cpu"},
+			AttributeTable: []*profiles.KeyValueAndUnit{
+				{},
+				{KeyStrindex: 4, Value: &common.AnyValue{Value: &common.AnyValue_StringRef{StringRef: 5}}},
+			},
+			MappingTable:  []*profiles.Mapping{{}, {FilenameStrindex: 3, AttributeIndices: []int32{1}}},
+			FunctionTable: []*profiles.Function{{}, {NameStrindex: 1}, {NameStrindex: 2}},
+			LocationTable: []*profiles.Location{
+				{},
+				{MappingIndex: 1, Lines: []*profiles.Line{{FunctionIndex: 1}}},
+				// Only referenced by the unreachable stack.
+				{Lines: []*profiles.Line{{FunctionIndex: 2}}},
+			},
+			LinkTable:  []*profiles.Link{{}},
+			StackTable: []*profiles.Stack{{}, {LocationIndices: []int32{1}}, {LocationIndices: []int32{2}}},
+		},
+		ResourceProfiles: []*profiles.ResourceProfiles{{
+			ScopeProfiles: []*profiles.ScopeProfiles{{
+				Profiles: []*profiles.Profile{{
+					SampleType: &profiles.ValueType{TypeStrindex: 6},
+					Samples:    []*profiles.Sample{{StackIndex: 1, Values: []int64{1}}},
+				}},
+			}},
+		}},
+	}
+
+	r := reachableEntries(data)
+	assertEqual(t, r.strings, []bool{true, true, false, true, true, true, true})
+	assertEqual(t, r.attributes, []bool{true, true})
+	assertEqual(t, r.mappings, []bool{true, true})
+	assertEqual(t, r.functions, []bool{true, true, false})
+	assertEqual(t, r.locations, []bool{true, true, false})
+	assertEqual(t, r.links, []bool{true})
+	assertEqual(t, r.stacks, []bool{true, true, false})
+
+	var b dictionaryBloat
+	b.add(data)
+	assertEqual(t, b.unreachable, 4)
+	assertEqual(t, b.entries, 21)
+	assertEqual(t, b.score(), 4.0/21)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	"github.com/open-telemetry/sig-profiling/profcheck"
+	"google.golang.org/protobuf/proto"
+)
+
+// canonicalize returns a copy of data with its dictionary in canonical form:
+// the string table is sorted with duplicates merged, entries that are not
+// reachable from the resource profiles are dropped, and attribute_indices
+// are sorted, as attributes are unordered. All references are rewritten, so
+// the payload is equivalent to data.
+func canonicalize(data *cprofiles.ExportProfilesServiceRequest) (*cprofiles.ExportProfilesServiceRequest, error) {
+	out := proto.Clone(data).(*cprofiles.ExportProfilesServiceRequest)
+	if out.Dictionary == nil {
+		return out, nil
+	}
+	sortStrings(out)
+	compactDictionary(out, reachableEntries(out))
+
+	for _, m := range out.Dictionary.MappingTable {
+		slices.Sort(m.AttributeIndices)
+	}
+	for _, loc := range out.Dictionary.LocationTable {
+		slices.Sort(loc.AttributeIndices)
+	}
+	for _, rp := range out.ResourceProfiles {
+		for _, sp := range rp.ScopeProfiles {
+			for _, p := range sp.Profiles {
+				slices.Sort(p.AttributeIndices)
+				for _, s := range p.Samples {
+					slices.Sort(s.AttributeIndices)
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// sortStrings sorts the string table of data, merging duplicate strings, and
+// rewrites the string references. The empty string stays at index 0, tables
+// without it at index 0 are left as they are.
+func sortStrings(data *cprofiles.ExportProfilesServiceRequest) {
+	dict := data.Dictionary
+	if len(dict.StringTable) == 0 || dict.StringTable[0] != "" {
+		return
+	}
+	sorted := slices.Compact(slices.Sorted(slices.Values(dict.StringTable)))
+	r := &dictRemap{strings: make([]int32, len(dict.StringTable))}
+	for i, s := range dict.StringTable {
+		idx, _ := slices.BinarySearch(sorted, s)
+		r.strings[i] = int32(idx)
+	}
+	_, r.attributes = keepEntries(dict.AttributeTable, allEntries(len(dict.AttributeTable)))
+	_, r.mappings = keepEntries(dict.MappingTable, allEntries(len(dict.MappingTable)))
+	_, r.functions = keepEntries(dict.FunctionTable, allEntries(len(dict.FunctionTable)))
+	_, r.locations = keepEntries(dict.LocationTable, allEntries(len(dict.LocationTable)))
+	_, r.links = keepEntries(dict.LinkTable, allEntries(len(dict.LinkTable)))
+	_, r.stacks = keepEntries(dict.StackTable, allEntries(len(dict.StackTable)))
+	dict.StringTable = sorted
+	r.apply(data)
+}
+
+// conformance counts the profcheck error findings of the payloads before and
+// after a verified strategy, see strategy.verify.
+type conformance struct {
+	inputErrors  int
+	outputErrors int
+}
+
+// add checks the payloads in and out with defaultChecker.
+func (c *conformance) add(in, out *cprofiles.ExportProfilesServiceRequest) error {
+	for _, payload := range []struct {
+		data   *cprofiles.ExportProfilesServiceRequest
+		errors *int
+	}{{in, &c.inputErrors}, {out, &c.outputErrors}} {
+		upstream, err := toUpstream(payload.data)
+		if err != nil {
+			return fmt.Errorf("convert to upstream: %w", err)
+		}
+		for _, f := range defaultChecker.Report(upstream) {
+			if f.Severity == profcheck.SeverityError {
+				*payload.errors++
+			}
+		}
+	}
+	return nil
+}
+
+// passed reports whether the strategy kept the payloads as conformant as
+// they were. Inputs that already fail the checks are not held against it.
+func (c conformance) passed() bool {
+	return c.outputErrors <= c.inputErrors
+}
+
+// result returns "pass" or "fail", as written to the conformance column of
+// the summary, or "" if c is nil because the strategy isn't verified.
+func (c *conformance) result() string {
+	switch {
+	case c == nil:
+		return ""
+	case c.passed():
+		return "pass"
+	default:
+		return "fail"
+	}
+}
+
+func (c conformance) String() string {
+	return fmt.Sprintf("profcheck %s, %d errors in the input and %d in the output", strings.ToUpper(c.result()), c.inputErrors, c.outputErrors)
+}
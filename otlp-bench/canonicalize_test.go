@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	common "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/common/v1"
+	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCanonicalize(t *testing.T) {
+	data := minimalRequest()
+	dict := data.Dictionary
+	// "main" is duplicated and "unused" is not referenced.
+	dict.StringTable = append(dict.StringTable, "main", "unused")
+	dict.FunctionTable = append(dict.FunctionTable, &profiles.Function{NameStrindex: 2})
+	dict.LocationTable = append(dict.LocationTable, &profiles.Location{Lines: []*profiles.Line{{FunctionIndex: 1}}})
+	dict.StackTable = append(dict.StackTable, &profiles.Stack{LocationIndices: []int32{1}})
+	dict.AttributeTable = append(dict.AttributeTable,
+		&profiles.KeyValueAndUnit{KeyStrindex: 3, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "other"}}},
+		&profiles.KeyValueAndUnit{KeyStrindex: 4})
+	sample := data.ResourceProfiles[0].ScopeProfiles[0].Profiles[0].Samples[0]
+	sample.StackIndex = 1
+	sample.AttributeIndices = []int32{2, 1}
+	input := proto.Clone(data).(*cprofiles.ExportProfilesServiceRequest)
+
+	got, err := canonicalize(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, data, input)
+
+	want := proto.Clone(input).(*cprofiles.ExportProfilesServiceRequest)
+	want.Dictionary.StringTable = []string{"", "main", "thread.name"}
+	want.Dictionary.FunctionTable[1].NameStrindex = 1
+	want.Dictionary.AttributeTable = []*profiles.KeyValueAndUnit{
+		{},
+		{KeyStrindex: 2, Value: &common.AnyValue{Value: &common.AnyValue_StringRef{StringRef: 1}}},
+		{KeyStrindex: 1, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "other"}}},
+	}
+	want.ResourceProfiles[0].ScopeProfiles[0].Profiles[0].Samples[0].AttributeIndices = []int32{1, 2}
+	assertEqual(t, got, want)
+
+	var c conformance
+	if err := c.add(input, got); err != nil {
+		t.Fatal(err)
+	}
+	if !c.passed() || c.outputErrors != 0 {
+		t.Errorf("got %s, want no errors", c)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	"github.com/urfave/cli/v3"
+)
+
+func (a *App) convertCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "convert",
+		Usage:     "convert gh733 profiles between the " + formatSingle + " and " + formatLengthPrefixed + " file formats",
+		ArgsUsage: "in out",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "from",
+				Usage: "format of the input, one of " + strings.Join(framings[1:], ", "),
+				Value: formatLengthPrefixed,
+			},
+			&cli.StringFlag{
+				Name:  "to",
+				Usage: "format of the output, one of " + formatSingle + ", " + formatLengthPrefixed,
+				Value: formatSingle,
+			},
+			&cli.BoolFlag{
+				Name:  "merge",
+				Usage: "with --to=" + formatSingle + ", combine several input messages into one with a shared dictionary instead of failing",
+			},
+		},
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:      "in",
+				UsageText: "OTLP profile file to read",
+			},
+			&cli.StringArg{
+				Name:      "out",
+				UsageText: "file to write the converted profile to",
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			in, out := cmd.StringArg("in"), cmd.StringArg("out")
+			if in == "" || out == "" {
+				return fmt.Errorf("convert requires an input and an output file")
+			}
+			return convert(in, out, cmd.String("from"), cmd.String("to"), cmd.Bool("merge"))
+		},
+	}
+}
+
+// convert reads the payloads of in, which must be in format from, and writes
+// them to out in format to. Several payloads can only be written as a single
+// message if merge is set, in which case they are combined by mergeRequests.
+func convert(in, out, from, to string, merge bool) error {
+	if !slices.Contains(framings[1:], from) {
+		return fmt.Errorf("unknown format %q, must be one of %s", from, strings.Join(framings[1:], ", "))
+	}
+	if to != formatSingle && to != formatLengthPrefixed {
+		return fmt.Errorf("unknown format %q, must be one of %s, %s", to, formatSingle, formatLengthPrefixed)
+	}
+	data, err := os.ReadFile(in)
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+	data, err = decompressInput(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+	payloads, err := unmarshalFormat(data, from)
+	if err != nil {
+		return fmt.Errorf("%s: unmarshal gh733 profile: %w", in, err)
+	}
+	if to == formatSingle && len(payloads) != 1 {
+		if !merge {
+			return fmt.Errorf("%s: has %d messages, a %s file holds exactly one, use --merge to combine them", in, len(payloads), formatSingle)
+		}
+		payloads = []*cprofiles.ExportProfilesServiceRequest{mergeRequests(payloads)}
+	}
+	encoded, err := marshalPayloads(payloads, to == formatLengthPrefixed)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(out, encoded, 0644); err != nil {
+		return fmt.Errorf("write %q: %w", out, err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestAppConvert(t *testing.T) {
+	dir := t.TempDir()
+	req := minimalRequest()
+	single := filepath.Join(dir, "single.otlp")
+	if err := writePayloads(single, []*cprofiles.ExportProfilesServiceRequest{req}); err != nil {
+		t.Fatal(err)
+	}
+
+	// single -> length-prefixed wraps the message in a length prefix, and
+	// converting back yields the original bytes.
+	prefixed := filepath.Join(dir, "prefixed.otlp")
+	if _, _, err := runTestApp(t, []string{"convert", "--from=single", "--to=length-prefixed", single, prefixed}); err != nil {
+		t.Fatal(err)
+	}
+	want, err := marshalPayloads([]*cprofiles.ExportProfilesServiceRequest{req}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, readFile(t, prefixed), want)
+	roundTrip := filepath.Join(dir, "roundtrip.otlp")
+	if _, _, err := runTestApp(t, []string{"convert", "--from=length-prefixed", "--to=single", prefixed, roundTrip}); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, readFile(t, roundTrip), readFile(t, single))
+
+	// Several messages can only become a single message with --merge.
+	multi := filepath.Join(dir, "multi.otlp")
+	if err := writePayloads(multi, []*cprofiles.ExportProfilesServiceRequest{req, req}); err != nil {
+		t.Fatal(err)
+	}
+	merged := filepath.Join(dir, "merged.otlp")
+	_, _, err = runTestApp(t, []string{"convert", multi, merged})
+	if err == nil || !strings.Contains(err.Error(), "has 2 messages") {
+		t.Errorf("convert without --merge: got error %v, want has 2 messages", err)
+	}
+	if _, _, err := runTestApp(t, []string{"convert", "--merge", multi, merged}); err != nil {
+		t.Fatal(err)
+	}
+	got := &cprofiles.ExportProfilesServiceRequest{}
+	if err := proto.Unmarshal(readFile(t, merged), got); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, got, mergeRequests([]*cprofiles.ExportProfilesServiceRequest{req, req}))
+
+	_, _, err = runTestApp(t, []string{"convert", "--to=json", single, merged})
+	if err == nil || !strings.Contains(err.Error(), `unknown format "json"`) {
+		t.Errorf("convert --to=json: got error %v, want unknown format", err)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// dictionaryTable is the size of one dictionary table, summed over the
+// payloads of a file.
+type dictionaryTable struct {
+	name    string
+	entries int
+	// bytes is what the table contributes to the marshaled payload,
+	// including field tags and length prefixes.
+	bytes int
+}
+
+// dictionaryTables returns the number of entries and the encoded size of
+// every table in the dictionaries of payloads, in field order. The size of a
+// table is measured by marshaling a dictionary with only that table set. The
+// payloads are inspected by reflection so that any proto version with a
+// dictionary field works.
+func dictionaryTables(payloads []proto.Message) []dictionaryTable {
+	var tables []dictionaryTable
+	index := map[string]int{}
+	for _, payload := range payloads {
+		msg := payload.ProtoReflect()
+		fd := msg.Descriptor().Fields().ByName("dictionary")
+		if fd == nil || !msg.Has(fd) {
+			continue
+		}
+		dict := msg.Get(fd).Message()
+		fields := dict.Descriptor().Fields()
+		for i := range fields.Len() {
+			field := fields.Get(i)
+			if !field.IsList() {
+				continue
+			}
+			name := string(field.Name())
+			idx, ok := index[name]
+			if !ok {
+				idx = len(tables)
+				index[name] = idx
+				tables = append(tables, dictionaryTable{name: name})
+			}
+			only := dict.New()
+			if dict.Has(field) {
+				only.Set(field, dict.Get(field))
+			}
+			tables[idx].entries += dict.Get(field).List().Len()
+			tables[idx].bytes += marshalOptions.Size(only.Interface())
+		}
+	}
+	return tables
+}
+
+func writeDictionaryRows(csvWriter *csv.Writer, file string, tables []dictionaryTable) error {
+	for _, t := range tables {
+		if err := csvWriter.Write([]string{file, t.name, fmt.Sprintf("%d", t.entries), fmt.Sprintf("%d", t.bytes)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+)
+
+func TestDictionaryTables(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "k8s.otlp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gh733, err := lookupProtoVersion("gh733")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloads, err := unmarshalPayloads(data, gh733.newRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := dictionaryTables(payloads)
+
+	var wantBytes, gotBytes int
+	wantEntries := map[string]int{}
+	for _, payload := range payloads {
+		dict := payload.(*cprofiles.ExportProfilesServiceRequest).GetDictionary()
+		wantBytes += marshalOptions.Size(dict)
+		wantEntries["string_table"] += len(dict.GetStringTable())
+		wantEntries["stack_table"] += len(dict.GetStackTable())
+	}
+	gotEntries := map[string]int{}
+	for _, table := range tables {
+		gotBytes += table.bytes
+		gotEntries[table.name] = table.entries
+	}
+	// The dictionary consists of its tables only, so their sizes must add
+	// up to its size.
+	assertEqual(t, gotBytes, wantBytes)
+	assertEqual(t, gotEntries["string_table"], wantEntries["string_table"])
+	assertEqual(t, gotEntries["stack_table"], wantEntries["stack_table"])
+}
+
+func TestAppDictionaryCSV(t *testing.T) {
+	outDir := filepath.Join(t.TempDir(), "out")
+	if _, _, err := runTestApp(t, []string{"--out", outDir, "--dictionary-sizes", filepath.Join("testdata", "k8s.otlp")}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filepath.Join(outDir, "dictionary.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, records[0], []string{"file", "table", "entries", "bytes"})
+	var tables []string
+	for _, record := range records[1:] {
+		tables = append(tables, record[1])
+	}
+	assertEqual(t, tables, []string{"mapping_table", "location_table", "function_table", "link_table", "string_table", "attribute_table", "stack_table"})
+
+	// Without --dictionary-sizes, the file isn't written.
+	outDir = filepath.Join(t.TempDir(), "out")
+	if _, _, err := runTestApp(t, []string{"--out", outDir, filepath.Join("testdata", "k8s.otlp")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "dictionary.csv")); !os.IsNotExist(err) {
+		t.Errorf("got dictionary.csv without --dictionary-sizes, stat error %v", err)
+	}
+}
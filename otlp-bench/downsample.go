@@ -0,0 +1,56 @@
+package main
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
+	"google.golang.org/protobuf/proto"
+)
+
+// downsampleSeed seeds the selection of the samples kept by downsample, so
+// that every run and every payload keep the same samples.
+const downsampleSeed = 1
+
+// downsample returns a copy of data that keeps rate, a fraction in (0, 1], of
+// the samples of every profile, rounded to the nearest count, as an agent
+// sampling less often would. The kept samples are chosen at random with
+// downsampleSeed and keep their order. The dictionary is left as is, so
+// entries only referenced by dropped samples become unreferenced, which the
+// bloat score of the strategy shows; downsample-compact drops them.
+func downsample(data *cprofiles.ExportProfilesServiceRequest, rate float64) (*cprofiles.ExportProfilesServiceRequest, error) {
+	out := proto.Clone(data).(*cprofiles.ExportProfilesServiceRequest)
+	r := rand.New(rand.NewPCG(downsampleSeed, 0))
+	for _, rp := range out.ResourceProfiles {
+		for _, sp := range rp.ScopeProfiles {
+			for _, p := range sp.Profiles {
+				p.Samples = downsampleSamples(r, p.Samples, rate)
+			}
+		}
+	}
+	return out, nil
+}
+
+// downsampleSamples returns rate of samples, chosen with r, in their order.
+func downsampleSamples(r *rand.Rand, samples []*profiles.Sample, rate float64) []*profiles.Sample {
+	n := int(math.Round(rate * float64(len(samples))))
+	keep := r.Perm(len(samples))[:n]
+	slices.Sort(keep)
+	kept := make([]*profiles.Sample, n)
+	for i, idx := range keep {
+		kept[i] = samples[idx]
+	}
+	return kept
+}
+
+// compactUnreferenced returns a copy of data without the dictionary entries
+// that are not reachable from its resource profiles.
+func compactUnreferenced(data *cprofiles.ExportProfilesServiceRequest) (*cprofiles.ExportProfilesServiceRequest, error) {
+	out := proto.Clone(data).(*cprofiles.ExportProfilesServiceRequest)
+	if out.Dictionary != nil {
+		compactDictionary(out, reachableEntries(out))
+	}
+	return out, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/csv"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
+)
+
+func TestDownsample(t *testing.T) {
+	data := minimalRequest()
+	p := data.ResourceProfiles[0].ScopeProfiles[0].Profiles[0]
+	p.Samples = nil
+	for i := range 20 {
+		p.Samples = append(p.Samples, &profiles.Sample{Values: []int64{int64(i)}})
+	}
+
+	got, err := downsample(data, 0.25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := got.ResourceProfiles[0].ScopeProfiles[0].Profiles[0].Samples
+	if len(samples) != 5 {
+		t.Fatalf("got %d samples, want 5", len(samples))
+	}
+	for i := 1; i < len(samples); i++ {
+		if samples[i].Values[0] <= samples[i-1].Values[0] {
+			t.Errorf("samples out of order: %v", samples)
+		}
+	}
+	assertEqual(t, len(p.Samples), 20)
+
+	again, err := downsample(data, 0.25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, again, got)
+}
+
+func TestAppSampleRate(t *testing.T) {
+	input := filepath.Join("testdata", "k8s.otlp")
+	stdout, _, err := runTestApp(t, []string{"--out", "-", "--sample-rate", "0.1", input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, len(records), 1+len(strategies)+2)
+	for _, record := range records[len(records)-2:] {
+		if !strings.HasPrefix(record[1], "downsample") || record[3] != "true" {
+			t.Errorf("got encoding %s, lossy %s, want a lossy downsample strategy", record[1], record[3])
+		}
+	}
+
+	for _, rate := range []string{"-0.5", "1.5"} {
+		if _, _, err := runTestApp(t, []string{"--out", "-", "--sample-rate", rate, input}); err == nil {
+			t.Errorf("expected error for --sample-rate %s", rate)
+		}
+	}
+}
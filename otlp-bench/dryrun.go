@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// dryRun writes the plan of a run to out without measuring anything: the
+// input files with their number of payloads, the encodings and codecs that
+// would be measured and the files that would be written to outDir. Files
+// that can't be read are reported in the plan rather than failing it.
+func dryRun(out io.Writer, opts runOptions, version protoVersion, files []string, outDir string, toStdout bool) {
+	fmt.Fprintf(out, "files (%d):\n", len(files))
+	for _, file := range files {
+		n, size, err := countFilePayloads(file, opts.framing, version.newRequest)
+		if err != nil {
+			fmt.Fprintf(out, "  %s: error: %v\n", file, err)
+			continue
+		}
+		note := ""
+		if opts.limit > 0 && n > opts.limit {
+			note = fmt.Sprintf(", %d measured with --limit", opts.limit)
+		}
+		fmt.Fprintf(out, "  %s: %d payloads, %s%s\n", file, n, humanBytes(size), note)
+	}
+	if opts.merge {
+		fmt.Fprintf(out, "merged into one payload measured as %s\n", mergedFilename)
+	}
+
+	var encodings []string
+	if version.transforms {
+		for _, s := range opts.strategies() {
+			encodings = append(encodings, s.name)
+		}
+	} else {
+		encodings = []string{strategies[0].name}
+	}
+	fmt.Fprintf(out, "encodings (%s): %s\n", version.name, strings.Join(encodings, ", "))
+	var codecs []string
+	for _, col := range sizeColumns {
+		codecs = append(codecs, strings.TrimSuffix(col, "_bytes"))
+	}
+	fmt.Fprintf(out, "sizes: %s\n", strings.Join(codecs, ", "))
+	if opts.repeat > 1 {
+		fmt.Fprintf(out, "repeated %d times\n", opts.repeat)
+	}
+	if opts.timingRuns > 0 {
+		var timed []string
+		for _, c := range timedCodecs {
+			timed = append(timed, c.name)
+		}
+		fmt.Fprintf(out, "timed: %s, %d runs after %d warmup runs\n", strings.Join(timed, ", "), opts.timingRuns, opts.warmup)
+	}
+
+	if toStdout {
+		fmt.Fprintf(out, "output: %s to stdout\n", summaryFilename(opts.outFormat))
+		return
+	}
+	if opts.outTemplate != "" {
+		fmt.Fprintf(out, "output directory: %s, new, with a -N suffix if it exists\n", outDir)
+	} else {
+		fmt.Fprintf(out, "output directory: %s, replacing its contents\n", outDir)
+	}
+	for _, name := range outputFiles(opts, version, files) {
+		fmt.Fprintf(out, "  %s\n", name)
+	}
+}
+
+// outputFiles returns the names of the files a run with opts writes to the
+// output directory, see run.
+func outputFiles(opts runOptions, version protoVersion, files []string) []string {
+	names := []string{summaryFilename(opts.outFormat), "manifest.json"}
+	for _, side := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"repeat.csv", opts.repeat > 1},
+		{"memstats.csv", opts.memStats},
+		{"timing.csv", opts.timingRuns > 0},
+		{"values.csv", opts.valueHist},
+		{"payload_formats.csv", opts.formats},
+		{"dictionary.csv", opts.dictSizes},
+		{"strings.txt", opts.topStrings > 0},
+	} {
+		if side.enabled {
+			names = append(names, side.name)
+		}
+	}
+	measured := files
+	if opts.merge {
+		measured = []string{mergedFilename}
+	}
+	for _, file := range files {
+		// The inputs are copied as they are.
+		names = append(names, filepath.Base(file))
+	}
+	for _, file := range measured {
+		base := filepath.Base(file)
+		// The other outputs are written by the strategies, which only run
+		// for the proto versions they can transform.
+		if !version.transforms {
+			continue
+		}
+		for _, s := range opts.strategies() {
+			if opts.dumpSamples > 0 {
+				names = append(names, base+"."+s.name+".txt")
+			}
+			if opts.emitCompressed {
+				names = append(names, base+"."+s.name+".gz", base+"."+s.name+".zst")
+			}
+		}
+		if opts.emit != "" {
+			names = append(names, base+"."+opts.emit+".otlp")
+		}
+	}
+	return names
+}
+
+// countFilePayloads returns the number of payloads in file and its size
+// after decompression. Only files whose framing has to be guessed are
+// decoded, the others are counted by their frame headers.
+func countFilePayloads(file, framing string, newMsg func() proto.Message) (int, int, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, 0, fmt.Errorf("read file: %w", err)
+	}
+	if data, err = decompressInput(data); err != nil {
+		return 0, 0, err
+	}
+	var n int
+	switch framing {
+	case formatSingle:
+		n = 1
+	case formatLengthPrefixed:
+		n, err = countFrames(data, 4)
+	case formatGRPC:
+		n, err = countFrames(data, 5)
+	default:
+		var msgs []proto.Message
+		msgs, err = unmarshalPayloads(data, newMsg)
+		n = len(msgs)
+	}
+	return n, len(data), err
+}
+
+// countFrames counts the frames of data, each a header of headerSize bytes
+// ending in the big-endian uint32 size of the message that follows.
+func countFrames(data []byte, headerSize int) (int, error) {
+	n := 0
+	for len(data) > 0 {
+		if len(data) < headerSize {
+			return 0, fmt.Errorf("data too short for a %d byte frame header", headerSize)
+		}
+		size := uint64(binary.BigEndian.Uint32(data[headerSize-4 : headerSize]))
+		if uint64(len(data)) < uint64(headerSize)+size {
+			return 0, fmt.Errorf("data length %d does not match expected size %d", len(data), uint64(headerSize)+size)
+		}
+		data = data[uint64(headerSize)+size:]
+		n++
+	}
+	return n, nil
+}
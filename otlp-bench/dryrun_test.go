@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+)
+
+func TestCountFrames(t *testing.T) {
+	data, err := marshalPayloads([]*cprofiles.ExportProfilesServiceRequest{minimalRequest(), minimalRequest(), minimalRequest()}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := countFrames(data, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, n, 3)
+	if _, err := countFrames(data[:len(data)-1], 4); err == nil {
+		t.Error("expected error for a truncated frame")
+	}
+
+	single, err := marshalOptions.Marshal(minimalRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err = countFrames(append(grpcFrame(0, single), grpcFrame(0, single)...), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, n, 2)
+}
+
+func TestAppDryRun(t *testing.T) {
+	outDir := t.TempDir()
+	kept := filepath.Join(outDir, "earlier-results.csv")
+	if err := os.WriteFile(kept, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	input := filepath.Join("testdata", "k8s.otlp")
+	stdout, _, err := runTestApp(t, []string{"--out", outDir, "--dry-run", "--limit", "1", "--emit", "baseline", input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		input + ": 2 payloads",
+		"1 measured with --limit",
+		"encodings (gh733): baseline, split-by-process",
+		"output directory: " + outDir + ", replacing its contents",
+		"  k8s.otlp.baseline.otlp\n",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("missing %q in the plan:\n%s", want, stdout)
+		}
+	}
+	// Nothing is measured and the output directory is left as it is.
+	if _, err := os.Stat(kept); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "summary.csv")); err == nil {
+		t.Error("unexpected summary.csv after a dry run")
+	}
+}
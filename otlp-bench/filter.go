@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	common "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/common/v1"
+	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
+)
+
+// resourceFilter matches resources with an attribute of the given key and
+// value, see --filter-resource.
+type resourceFilter struct {
+	key, value string
+}
+
+// parseResourceFilters parses the key=value arguments of --filter-resource.
+func parseResourceFilters(args []string) ([]resourceFilter, error) {
+	var filters []resourceFilter
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid resource filter %q, must be key=value", arg)
+		}
+		filters = append(filters, resourceFilter{key: key, value: value})
+	}
+	return filters, nil
+}
+
+// matchesResource reports whether the resource attributes of rp match all
+// filters. Keys and string values are resolved from the dictionary, other
+// values are compared as formatted by anyValueString, e.g. 42 for an int.
+func matchesResource(rp *profiles.ResourceProfiles, dict *profiles.ProfilesDictionary, filters []resourceFilter) bool {
+	attrs := rp.GetResource().GetAttributes()
+	for _, f := range filters {
+		if !slices.ContainsFunc(attrs, func(kv *common.KeyValue) bool {
+			key := kv.Key
+			if kv.KeyRef != 0 {
+				key = dictString(dict, kv.KeyRef)
+			}
+			return key == f.key && resolvedValueString(kv.Value, dict) == f.value
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolvedValueString returns av as a string, with string references
+// resolved and without the quotes of anyValueString.
+func resolvedValueString(av *common.AnyValue, dict *profiles.ProfilesDictionary) string {
+	switch v := av.GetValue().(type) {
+	case *common.AnyValue_StringValue:
+		return v.StringValue
+	case *common.AnyValue_StringRef:
+		return dictString(dict, v.StringRef)
+	default:
+		return anyValueString(av, dict)
+	}
+}
+
+// filterResources keeps only the resource profiles of the payloads in data
+// that match filters, and drops the dictionary entries that are no longer
+// reachable, so that the sizes are those of a capture of only the matching
+// resources. The payloads are returned re-encoded in the length-prefixed
+// format, with the number of resource profiles kept.
+func filterResources(data []byte, framing string, filters []resourceFilter) ([]byte, int, error) {
+	payloads, err := unmarshalFormat(data, framing)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unmarshal gh733 profile: %w", err)
+	}
+	kept := 0
+	for _, payload := range payloads {
+		payload.ResourceProfiles = slices.DeleteFunc(payload.ResourceProfiles, func(rp *profiles.ResourceProfiles) bool {
+			return !matchesResource(rp, payload.Dictionary, filters)
+		})
+		kept += len(payload.ResourceProfiles)
+		if payload.Dictionary != nil {
+			compactDictionary(payload, reachableEntries(payload))
+		}
+	}
+	filtered, err := marshalPayloads(payloads, true)
+	if err != nil {
+		return nil, 0, err
+	}
+	return filtered, kept, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/csv"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	common "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/common/v1"
+	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
+	resource "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/resource/v1"
+)
+
+func TestFilterResources(t *testing.T) {
+	serviceProfiles := func(service *common.KeyValue, stack int32) *profiles.ResourceProfiles {
+		return &profiles.ResourceProfiles{
+			Resource: &resource.Resource{Attributes: []*common.KeyValue{service}},
+			ScopeProfiles: []*profiles.ScopeProfiles{{
+				Profiles: []*profiles.Profile{{
+					SampleType: &profiles.ValueType{TypeStrindex: 1},
+					Samples:    []*profiles.Sample{{StackIndex: stack, Values: []int64{1}}},
+				}},
+			}},
+		}
+	}
+	req := &cprofiles.ExportProfilesServiceRequest{
+		Dictionary: &profiles.ProfilesDictionary{
+			StringTable:    []string{"", "cpu", "service.name", "api", "a", "b"},
+			AttributeTable: []*profiles.KeyValueAndUnit{{}},
+			MappingTable:   []*profiles.Mapping{{}},
+			FunctionTable:  []*profiles.Function{{}, {NameStrindex: 4}, {NameStrindex: 5}},
+			LocationTable:  []*profiles.Location{{}, {Lines: []*profiles.Line{{FunctionIndex: 1}}}, {Lines: []*profiles.Line{{FunctionIndex: 2}}}},
+			LinkTable:      []*profiles.Link{{}},
+			StackTable:     []*profiles.Stack{{}, {LocationIndices: []int32{1}}, {LocationIndices: []int32{2}}},
+		},
+		ResourceProfiles: []*profiles.ResourceProfiles{
+			serviceProfiles(&common.KeyValue{Key: "service.name", Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "web"}}}, 1),
+			serviceProfiles(&common.KeyValue{KeyRef: 2, Value: &common.AnyValue{Value: &common.AnyValue_StringRef{StringRef: 3}}}, 2),
+		},
+	}
+	data, err := marshalPayloads([]*cprofiles.ExportProfilesServiceRequest{req}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filters, err := parseResourceFilters([]string{"service.name=api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered, kept, err := filterResources(data, formatSingle, filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, kept, 1)
+	got, err := unmarshalFormat(filtered, formatLengthPrefixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the entries reachable from the api resource are kept.
+	want := &cprofiles.ExportProfilesServiceRequest{
+		Dictionary: &profiles.ProfilesDictionary{
+			StringTable:    []string{"", "cpu", "service.name", "api", "b"},
+			AttributeTable: []*profiles.KeyValueAndUnit{{}},
+			MappingTable:   []*profiles.Mapping{{}},
+			FunctionTable:  []*profiles.Function{{}, {NameStrindex: 4}},
+			LocationTable:  []*profiles.Location{{}, {Lines: []*profiles.Line{{FunctionIndex: 1}}}},
+			LinkTable:      []*profiles.Link{{}},
+			StackTable:     []*profiles.Stack{{}, {LocationIndices: []int32{1}}},
+		},
+		ResourceProfiles: []*profiles.ResourceProfiles{
+			serviceProfiles(&common.KeyValue{KeyRef: 2, Value: &common.AnyValue{Value: &common.AnyValue_StringRef{StringRef: 3}}}, 1),
+		},
+	}
+	assertEqual(t, got, []*cprofiles.ExportProfilesServiceRequest{want})
+
+	if _, err := parseResourceFilters([]string{"service.name"}); err == nil {
+		t.Error("expected error for a filter without a value")
+	}
+}
+
+func TestAppFilterResource(t *testing.T) {
+	input := filepath.Join("testdata", "k8s.otlp")
+	full, _, err := runTestApp(t, []string{"--out", "-", input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := "container.id=a538e0b90ee5ffa4cdc66842424eafd926fee25c0e91709e75078408da29058a"
+	filtered, _, err := runTestApp(t, []string{"--out", "-", "--filter-resource", filter, input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	baselineSize := func(summary string) int {
+		t.Helper()
+		records, err := csv.NewReader(strings.NewReader(summary)).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		size, err := strconv.Atoi(records[1][5])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return size
+	}
+	if got, all := baselineSize(filtered), baselineSize(full); got <= 0 || got >= all {
+		t.Errorf("got %d bytes for one container, want less than the %d bytes of all", got, all)
+	}
+
+	_, stderr, err := runTestApp(t, []string{"--out", "-", "--filter-resource", "container.id=none", input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stderr, "no resource matches --filter-resource") {
+		t.Errorf("got stderr %q, want a warning about no matching resource", stderr)
+	}
+	if _, _, err := runTestApp(t, []string{"--out", "-", "--proto-version", "upstream", "--filter-resource", filter, input}); err == nil {
+		t.Error("expected error for --filter-resource with a proto version the strategies can't transform")
+	}
+}
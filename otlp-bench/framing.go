@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	"google.golang.org/protobuf/proto"
+)
+
+// The file formats convert reads and writes. single is one plain protobuf
+// message, as in an OTLP/HTTP request body, lengthPrefixed is the format of
+// the fileexporter, see unmarshalLengthPrefixed. grpc is the framing of
+// captured gRPC request bodies, see unmarshalGRPC, and is only read. auto
+// guesses the format, see unmarshalPayloads.
+const (
+	formatAuto           = "auto"
+	formatSingle         = "single"
+	formatLengthPrefixed = "length-prefixed"
+	formatGRPC           = "grpc"
+)
+
+// framings are the formats the input can be read in, see --framing.
+var framings = []string{formatAuto, formatSingle, formatLengthPrefixed, formatGRPC}
+
+// unmarshalFormat decodes data in format, see unmarshalFramed.
+func unmarshalFormat(data []byte, format string) ([]*cprofiles.ExportProfilesServiceRequest, error) {
+	msgs, err := unmarshalFramed(data, format, func() proto.Message { return &cprofiles.ExportProfilesServiceRequest{} })
+	if err != nil {
+		return nil, err
+	}
+	reqs := make([]*cprofiles.ExportProfilesServiceRequest, len(msgs))
+	for i, msg := range msgs {
+		reqs[i] = msg.(*cprofiles.ExportProfilesServiceRequest)
+	}
+	return reqs, nil
+}
+
+// unmarshalFramed decodes data in format into messages created by newMsg.
+// Unless format is auto it does not guess the format, as some length-prefixed
+// data also decodes as a single message.
+func unmarshalFramed(data []byte, format string, newMsg func() proto.Message) ([]proto.Message, error) {
+	switch format {
+	case formatAuto:
+		return unmarshalPayloads(data, newMsg)
+	case formatSingle:
+		msg := newMsg()
+		if err := proto.Unmarshal(data, msg); err != nil {
+			return nil, err
+		}
+		return []proto.Message{msg}, nil
+	case formatLengthPrefixed:
+		return unmarshalLengthPrefixed(data, newMsg)
+	case formatGRPC:
+		return unmarshalGRPC(data, newMsg)
+	default:
+		return nil, fmt.Errorf("unknown format %q, must be one of %s", format, strings.Join(framings, ", "))
+	}
+}
+
+// limitPayloads keeps the first n payloads of data, decoded in format with
+// newMsg, and returns them re-encoded in the length-prefixed format. limited
+// reports whether any payloads were dropped.
+func limitPayloads(data []byte, format string, newMsg func() proto.Message, n int) (out []byte, limited bool, err error) {
+	msgs, err := unmarshalFramed(data, format, newMsg)
+	if err != nil {
+		return nil, false, err
+	}
+	limited = len(msgs) > n
+	for _, msg := range msgs[:min(n, len(msgs))] {
+		payload, err := marshalOptions.Marshal(msg)
+		if err != nil {
+			return nil, false, fmt.Errorf("marshal payload: %w", err)
+		}
+		out = binary.BigEndian.AppendUint32(out, uint32(len(payload)))
+		out = append(out, payload...)
+	}
+	return out, limited, nil
+}
+
+// unmarshalGRPC decodes data in the framing of gRPC messages, where each
+// message is prefixed by a compressed flag byte and its size as a big-endian
+// uint32. Compressed messages must be gzip compressed, the grpc-encoding the
+// collector uses.
+// See https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-HTTP2.md#length-prefixed-message
+func unmarshalGRPC(data []byte, newMsg func() proto.Message) ([]proto.Message, error) {
+	var msgs []proto.Message
+	for len(data) > 0 {
+		if len(data) < 5 {
+			return nil, fmt.Errorf("data too short for gRPC framing")
+		}
+		compressed := data[0]
+		size := binary.BigEndian.Uint32(data[1:5])
+		if uint64(len(data)) < 5+uint64(size) {
+			return nil, fmt.Errorf("data length %d does not match expected size %d", len(data), 5+uint64(size))
+		}
+		body := data[5 : 5+size]
+		data = data[5+size:]
+		switch compressed {
+		case 0:
+		case 1:
+			if !bytes.HasPrefix(body, gzipMagic) {
+				return nil, fmt.Errorf("compressed gRPC message is not gzip compressed")
+			}
+			var err error
+			if body, err = decompressInput(body); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("invalid gRPC compressed flag %d", compressed)
+		}
+		msg := newMsg()
+		if err := proto.Unmarshal(body, msg); err != nil {
+			return nil, fmt.Errorf("unmarshal gRPC message: %w", err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
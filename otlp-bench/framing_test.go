@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestUnmarshalGRPC(t *testing.T) {
+	req := minimalRequest()
+	data, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := gzipCompress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	framed := append(grpcFrame(0, data), grpcFrame(1, compressed)...)
+
+	want := []*cprofiles.ExportProfilesServiceRequest{req, req}
+	got, err := unmarshalFormat(framed, formatGRPC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, got, want)
+	// The gRPC framing is detected without --framing.
+	got, err = unmarshalOTLP(framed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, got, want)
+
+	for _, tc := range []struct {
+		desc    string
+		data    []byte
+		wantErr string
+	}{
+		{"invalid flag", grpcFrame(2, data), "invalid gRPC compressed flag 2"},
+		{"not gzip", grpcFrame(1, data), "not gzip compressed"},
+		{"truncated", grpcFrame(0, data)[:10], "does not match expected size"},
+	} {
+		_, err := unmarshalFormat(tc.data, formatGRPC)
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: got error %v, want error containing %q", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestAppFraming(t *testing.T) {
+	input := filepath.Join("testdata", "k8s.otlp")
+	payloads, err := unmarshalOTLP(readFile(t, input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var framed []byte
+	for _, payload := range payloads {
+		data, err := proto.Marshal(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		framed = append(framed, grpcFrame(0, data)...)
+	}
+	grpcInput := filepath.Join(t.TempDir(), "k8s.grpc")
+	if err := os.WriteFile(grpcInput, framed, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The payloads are the same, so are their re-encoded sizes and hashes.
+	want, _, err := runTestApp(t, []string{"--out", "-", input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := runTestApp(t, []string{"--out", "-", "--framing", formatGRPC, grpcInput})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, strings.ReplaceAll(got, grpcInput, input), want)
+
+	if _, _, err := runTestApp(t, []string{"--out", "-", "--framing", formatSingle, grpcInput}); err == nil {
+		t.Error("expected error for gRPC framed input read as a single message")
+	}
+	if _, _, err := runTestApp(t, []string{"--out", "-", "--framing", "http", input}); err == nil || !strings.Contains(err.Error(), `unknown framing "http"`) {
+		t.Errorf("--framing=http: got error %v, want unknown framing", err)
+	}
+}
+
+// grpcFrame returns data with the gRPC message prefix of compressed flag and
+// size.
+func grpcFrame(flag byte, data []byte) []byte {
+	return append(binary.BigEndian.AppendUint32([]byte{flag}, uint32(len(data))), data...)
+}
+
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	"google.golang.org/protobuf/proto"
+)
+
+// FuzzStrategies verifies that every strategy either transforms an arbitrary
+// request or returns an error, but never panics, and that the outputs can be
+// measured and printed.
+func FuzzStrategies(f *testing.F) {
+	for _, input := range []*cprofiles.ExportProfilesServiceRequest{
+		createTestProfilesData([]testSample{
+			{processAttrs: map[string]string{"process.pid": "123"}, otherAttrs: map[string]string{"thread.id": "456"}},
+		}),
+		createTestProfilesDataWithUnit([]testSample{
+			{processAttrs: map[string]string{"process.pid": "123"}},
+		}),
+		{},
+	} {
+		data, err := proto.Marshal(input)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	data, err := os.ReadFile(filepath.Join("testdata", "profile.otlp"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	payloads, err := unmarshalOTLP(data)
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, payload := range payloads {
+		data, err := proto.Marshal(payload)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var in cprofiles.ExportProfilesServiceRequest
+		if err := proto.Unmarshal(b, &in); err != nil {
+			return
+		}
+		outputs := map[string]*cprofiles.ExportProfilesServiceRequest{}
+		for _, s := range strategies {
+			base := &in
+			if s.base != "" {
+				if base = outputs[s.base]; base == nil {
+					continue
+				}
+			}
+			out, err := s.transform(base)
+			if err != nil {
+				continue
+			}
+			outputs[s.name] = out
+			if _, _, err := profileSizes(out); err != nil {
+				t.Errorf("%s: %v", s.name, err)
+			}
+			printProfile(io.Discard, out, 10)
+		}
+	})
+}
@@ -1,10 +1,33 @@
 module github.com/open-telemetry/sig-profiling/otlp-bench
 
-go 1.25
+go 1.25.0
 
 require (
-	github.com/google/go-cmp v0.7.0 // indirect
-	github.com/lmittmann/tint v1.1.2 // indirect
-	github.com/urfave/cli/v3 v3.5.0 // indirect
-	google.golang.org/protobuf v1.36.10 // indirect
+	github.com/google/go-cmp v0.7.0
+	github.com/klauspost/compress v1.17.9
+	github.com/open-telemetry/sig-profiling/profcheck v0.0.0-00010101000000-000000000000
+	github.com/parquet-go/parquet-go v0.32.0
+	github.com/urfave/cli/v3 v3.5.0
+	go.opentelemetry.io/proto/otlp v1.11.0
+	go.opentelemetry.io/proto/otlp/collector/profiles/v1development v0.4.0
+	go.opentelemetry.io/proto/otlp/profiles/v1development v0.4.0
+	google.golang.org/protobuf v1.36.11
 )
+
+require (
+	github.com/andybalholm/brotli v1.1.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.29.0 // indirect
+	github.com/parquet-go/bitpack v1.0.0 // indirect
+	github.com/parquet-go/jsonlite v1.0.0 // indirect
+	github.com/pierrec/lz4/v4 v4.1.21 // indirect
+	github.com/twpayne/go-geom v1.6.1 // indirect
+	golang.org/x/net v0.57.0 // indirect
+	golang.org/x/sys v0.47.0 // indirect
+	golang.org/x/text v0.40.0 // indirect
+	google.golang.org/genproto/googleapis/api v0.0.0-20260720211330-0afa2a65878a // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20260720211330-0afa2a65878a // indirect
+	google.golang.org/grpc v1.82.1 // indirect
+)
+
+replace github.com/open-telemetry/sig-profiling/profcheck => ../profcheck
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
+	"google.golang.org/protobuf/proto"
+)
+
+// hoistCommonAttrs returns a copy of data in which the attributes that every
+// sample of a profile has are moved from the samples to the profile, which
+// applies them to all its samples. Attributes are compared by their resolved
+// key, value and unit, so duplicate attribute table entries count as the same
+// attribute. An attribute is not hoisted if the profile already has one with
+// the same key but another value. The dictionary is shared with data.
+func hoistCommonAttrs(data *cprofiles.ExportProfilesServiceRequest) (*cprofiles.ExportProfilesServiceRequest, error) {
+	if data.Dictionary == nil {
+		return nil, errMissingDictionary
+	}
+	newProfile := proto.Clone(data).(*cprofiles.ExportProfilesServiceRequest)
+	newProfile.Dictionary = data.Dictionary
+	for _, rp := range newProfile.ResourceProfiles {
+		for _, sp := range rp.ScopeProfiles {
+			for _, p := range sp.Profiles {
+				if err := hoistProfileAttrs(p, data.Dictionary); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return newProfile, nil
+}
+
+// hoistProfileAttrs moves the attributes that all samples of p have to p.
+func hoistProfileAttrs(p *profiles.Profile, dict *profiles.ProfilesDictionary) error {
+	if len(p.Samples) == 0 {
+		return nil
+	}
+	// attrString identifies an attribute by its resolved key, value and unit.
+	attrString := func(idx int32) (string, error) {
+		attr := at(dict.AttributeTable, idx)
+		if attr == nil {
+			return "", fmt.Errorf("attribute index %d is out of range", idx)
+		}
+		return keyValueAndUnitsString([]*profiles.KeyValueAndUnit{attr}, dict), nil
+	}
+
+	// shared maps the attributes of the first sample that all other samples
+	// have too to their index in the first sample.
+	shared := map[string]int32{}
+	for _, idx := range p.Samples[0].AttributeIndices {
+		s, err := attrString(idx)
+		if err != nil {
+			return err
+		}
+		shared[s] = idx
+	}
+	for _, sample := range p.Samples[1:] {
+		seen := map[string]bool{}
+		for _, idx := range sample.AttributeIndices {
+			s, err := attrString(idx)
+			if err != nil {
+				return err
+			}
+			seen[s] = true
+		}
+		for s := range shared {
+			if !seen[s] {
+				delete(shared, s)
+			}
+		}
+	}
+
+	profileAttrs := map[string]bool{}
+	profileKeys := map[string]bool{}
+	for _, idx := range p.AttributeIndices {
+		s, err := attrString(idx)
+		if err != nil {
+			return err
+		}
+		profileAttrs[s] = true
+		profileKeys[dictString(dict, dict.AttributeTable[idx].KeyStrindex)] = true
+	}
+	for s, idx := range shared {
+		if profileAttrs[s] {
+			continue
+		}
+		if profileKeys[dictString(dict, dict.AttributeTable[idx].KeyStrindex)] {
+			// The profile attribute would conflict with the hoisted one.
+			delete(shared, s)
+		}
+	}
+	if len(shared) == 0 {
+		return nil
+	}
+
+	// The hoisted attributes are appended in the order of the first sample,
+	// so that the output doesn't depend on map iteration order.
+	for _, idx := range p.Samples[0].AttributeIndices {
+		s, _ := attrString(idx)
+		if _, ok := shared[s]; ok && !profileAttrs[s] {
+			p.AttributeIndices = append(p.AttributeIndices, idx)
+			profileAttrs[s] = true
+		}
+	}
+	for _, sample := range p.Samples {
+		sample.AttributeIndices = slices.DeleteFunc(sample.AttributeIndices, func(idx int32) bool {
+			s, _ := attrString(idx)
+			_, ok := shared[s]
+			return ok
+		})
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	common "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/common/v1"
+	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestHoistCommonAttrs(t *testing.T) {
+	data := minimalRequest()
+	dict := data.Dictionary
+	dict.StringTable = append(dict.StringTable, "worker", "span.id")
+	dict.AttributeTable = append(dict.AttributeTable,
+		// [2] duplicates [1], thread.name=main.
+		&profiles.KeyValueAndUnit{KeyStrindex: 1, Value: &common.AnyValue{Value: &common.AnyValue_StringRef{StringRef: 2}}},
+		// [3] is thread.name=worker.
+		&profiles.KeyValueAndUnit{KeyStrindex: 1, Value: &common.AnyValue{Value: &common.AnyValue_StringRef{StringRef: 3}}},
+		// [4] and [5] are span.id=1 and span.id=2.
+		&profiles.KeyValueAndUnit{KeyStrindex: 4, Value: &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: 1}}},
+		&profiles.KeyValueAndUnit{KeyStrindex: 4, Value: &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: 2}}})
+	sp := data.ResourceProfiles[0].ScopeProfiles[0]
+	sp.Profiles = []*profiles.Profile{{
+		Samples: []*profiles.Sample{
+			{Values: []int64{1}, AttributeIndices: []int32{1, 4}},
+			{Values: []int64{2}, AttributeIndices: []int32{5, 2}},
+		},
+	}, {
+		// The profile has a conflicting thread.name, which is kept on the
+		// samples.
+		AttributeIndices: []int32{3},
+		Samples: []*profiles.Sample{
+			{Values: []int64{1}, AttributeIndices: []int32{1}},
+			{Values: []int64{2}, AttributeIndices: []int32{1}},
+		},
+	}}
+	input := proto.Clone(data).(*cprofiles.ExportProfilesServiceRequest)
+
+	got, err := hoistCommonAttrs(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, data, input)
+
+	want := proto.Clone(input).(*cprofiles.ExportProfilesServiceRequest)
+	wantProfile := want.ResourceProfiles[0].ScopeProfiles[0].Profiles[0]
+	wantProfile.AttributeIndices = []int32{1}
+	wantProfile.Samples[0].AttributeIndices = []int32{4}
+	wantProfile.Samples[1].AttributeIndices = []int32{5}
+	assertEqual(t, got, want)
+
+	sample := data.ResourceProfiles[0].ScopeProfiles[0].Profiles[0].Samples[0]
+	sample.AttributeIndices = []int32{99}
+	if _, err := hoistCommonAttrs(data); err == nil {
+		t.Error("expected error for out of range attribute index")
+	}
+}
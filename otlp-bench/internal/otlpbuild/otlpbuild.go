@@ -4,13 +4,16 @@ import (
 	"bytes"
 	"context"
 	"crypto/sha1"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/fs"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 )
@@ -27,8 +30,19 @@ type Config struct {
 	DstDir string
 	// PackagePrefix is the prefix to use for the Go package names.
 	PackagePrefix string
+	// Incremental only compiles the proto files whose rewritten content
+	// changed since the last build using the same TmpDir, and the files
+	// importing them, directly or not. Nothing is compiled if no file
+	// changed. The hashes of the last build are kept in a manifest in
+	// TmpDir. All files are compiled if there is no manifest, the protoc
+	// image changed, a proto file was removed or the files in DstDir were
+	// modified.
+	Incremental bool
 }
 
+// protocImage is the docker image used to compile the proto files.
+const protocImage = "otel/build-protobuf:0.9.0"
+
 // Build builds the OTLP Go bindings and uses the base name of the DstDir as a
 // namespace to allow importing multiple versions of the same proto files into
 // the same program.
@@ -49,7 +63,10 @@ func Build(ctx context.Context, c Config) error {
 		return fmt.Errorf("get absolute path: %w", err)
 	}
 
-	// copy srcDir to nameSpaceDir
+	// copy srcDir to nameSpaceDir, replacing the copy of a previous build
+	if err := os.RemoveAll(namespaceDir); err != nil {
+		return fmt.Errorf("remove namespace directory: %w", err)
+	}
 	if err := os.CopyFS(filepath.Join(namespaceDir, "opentelemetry"), os.DirFS(c.SrcDir)); err != nil {
 		return fmt.Errorf("copy source directory: %w", err)
 	}
@@ -67,14 +84,55 @@ func Build(ctx context.Context, c Config) error {
 		}
 	}
 
+	// only compile the files affected by changes since the last build
+	manifestPath := filepath.Join(c.TmpDir, "manifest-"+namespace+".json")
+	var inputs map[string]string
+	var affected []string
+	if c.Incremental {
+		if inputs, err = fileHashes(srcDir, protoFiles); err != nil {
+			return fmt.Errorf("hash proto files: %w", err)
+		}
+		manifest, err := loadManifest(manifestPath)
+		if err != nil {
+			return err
+		}
+		if changed, ok := manifest.changedInputs(protocImage, inputs, dstDir); ok {
+			if len(changed) == 0 {
+				return nil
+			}
+			imports, err := protoImports(srcDir, protoFiles)
+			if err != nil {
+				return fmt.Errorf("parse proto imports: %w", err)
+			}
+			for _, rel := range affectedFiles(changed, imports) {
+				affected = append(affected, filepath.Join(srcDir, filepath.FromSlash(rel)))
+			}
+		}
+	}
+
 	// compile proto files
 	if err := os.MkdirAll(dstDir, 0o755); err != nil {
 		return fmt.Errorf("create destination directory: %w", err)
 	}
-	if err := compileProtoFiles(ctx, c.TmpDir, srcDir, namespace, dstDir, protoFiles); err != nil {
+	if affected != nil {
+		if err := compileChangedProtoFiles(ctx, c.TmpDir, srcDir, namespaceDir, dstDir, affected); err != nil {
+			return fmt.Errorf("compile changed proto files: %w", err)
+		}
+	} else if err := compileProtoFiles(ctx, c.TmpDir, srcDir, namespace, dstDir, protoFiles); err != nil {
 		return fmt.Errorf("compile proto files: %w", err)
 	}
 
+	if c.Incremental {
+		outputs, err := dirHashes(dstDir)
+		if err != nil {
+			return fmt.Errorf("hash output files: %w", err)
+		}
+		manifest := buildManifest{Image: protocImage, Inputs: inputs, Outputs: outputs}
+		if err := manifest.write(manifestPath); err != nil {
+			return err
+		}
+	}
+
 	return nil
 
 }
@@ -150,17 +208,210 @@ func namespaceHash(namespace string) string {
 	return string(encoded)
 }
 
+// buildManifest records the inputs and outputs of an incremental build. Paths
+// are relative and hashes are hex encoded sha1 sums of the file contents.
+type buildManifest struct {
+	Image   string            `json:"image"`
+	Inputs  map[string]string `json:"inputs"`
+	Outputs map[string]string `json:"outputs"`
+}
+
+// loadManifest reads the manifest at path. It returns an empty manifest if
+// the file does not exist.
+func loadManifest(path string) (buildManifest, error) {
+	var m buildManifest
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	} else if err != nil {
+		return m, fmt.Errorf("read manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("parse manifest %s: %w", path, err)
+	}
+	return m, nil
+}
+
+func (m buildManifest) write(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal manifest: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return nil
+}
+
+// changedInputs returns the sorted inputs whose hash differs from the
+// recorded build, including new inputs. ok reports whether the recorded build
+// can be updated by compiling them: it used image, no input was removed, and
+// dstDir still holds its outputs.
+func (m buildManifest) changedInputs(image string, inputs map[string]string, dstDir string) (changed []string, ok bool) {
+	if m.Image != image || len(m.Inputs) == 0 {
+		return nil, false
+	}
+	for input := range m.Inputs {
+		if _, ok := inputs[input]; !ok {
+			return nil, false
+		}
+	}
+	outputs, err := dirHashes(dstDir)
+	if err != nil || !maps.Equal(m.Outputs, outputs) {
+		return nil, false
+	}
+	for input, hash := range inputs {
+		if m.Inputs[input] != hash {
+			changed = append(changed, input)
+		}
+	}
+	sort.Strings(changed)
+	return changed, true
+}
+
+var protoImportRe = regexp.MustCompile(`(?m)^import\s+(?:public\s+|weak\s+)?"([^"]+)"`)
+
+// protoImports returns the imports of each of files, keyed and valued by
+// their path relative to root, the proto path they are compiled with.
+func protoImports(root string, files []string) (map[string][]string, error) {
+	imports := make(map[string][]string, len(files))
+	for _, file := range files {
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return nil, err
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var deps []string
+		for _, m := range protoImportRe.FindAllSubmatch(content, -1) {
+			deps = append(deps, string(m[1]))
+		}
+		imports[filepath.ToSlash(rel)] = deps
+	}
+	return imports, nil
+}
+
+// affectedFiles returns the sorted files that must be compiled again after
+// changed did: changed and every file importing one of them, directly or
+// not. The generated code of an importer refers to the Go types of its
+// imports, so it may change with them.
+func affectedFiles(changed []string, imports map[string][]string) []string {
+	importers := map[string][]string{}
+	for file, deps := range imports {
+		for _, dep := range deps {
+			importers[dep] = append(importers[dep], file)
+		}
+	}
+	affected := map[string]bool{}
+	queue := slices.Clone(changed)
+	for len(queue) > 0 {
+		file := queue[0]
+		queue = queue[1:]
+		if affected[file] {
+			continue
+		}
+		affected[file] = true
+		queue = append(queue, importers[file]...)
+	}
+	return slices.Sorted(maps.Keys(affected))
+}
+
+// fileHashes returns the hashes of files keyed by their path relative to root.
+func fileHashes(root string, files []string) (map[string]string, error) {
+	hashes := make(map[string]string, len(files))
+	for _, file := range files {
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return nil, err
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		hashes[filepath.ToSlash(rel)] = fmt.Sprintf("%x", sha1.Sum(content))
+	}
+	return hashes, nil
+}
+
+// dirHashes returns the hashes of all files in dir keyed by their path
+// relative to dir.
+func dirHashes(dir string) (map[string]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fileHashes(dir, files)
+}
+
 func compileProtoFiles(ctx context.Context, tmpDir, protoDir, namespace, dstDir string, protoFiles []string) error {
+	tmpDstDir, err := runProtoc(ctx, tmpDir, protoDir, protoFiles)
+	if err != nil {
+		return err
+	}
+
+	// copy to dstDir
+	if err := os.RemoveAll(dstDir); err != nil {
+		return fmt.Errorf("remove destination directory: %w", err)
+	}
+	if err := os.CopyFS(dstDir, os.DirFS(filepath.Join(tmpDstDir, namespace))); err != nil {
+		return fmt.Errorf("copy tmp dst to final dst directory: %w", err)
+	}
+
+	return nil
+}
+
+// compileChangedProtoFiles compiles protoFiles, which are in namespaceDir, and
+// replaces their generated files in dstDir, keeping the other files of a
+// previous build.
+func compileChangedProtoFiles(ctx context.Context, tmpDir, protoDir, namespaceDir, dstDir string, protoFiles []string) error {
+	tmpDstDir, err := runProtoc(ctx, tmpDir, protoDir, protoFiles)
+	if err != nil {
+		return err
+	}
+	for _, protoFile := range protoFiles {
+		rel, err := filepath.Rel(namespaceDir, protoFile)
+		if err != nil {
+			return err
+		}
+		goFile := strings.TrimSuffix(rel, ".proto") + ".pb.go"
+		content, err := os.ReadFile(filepath.Join(tmpDstDir, filepath.Base(namespaceDir), goFile))
+		if err != nil {
+			return fmt.Errorf("read generated file: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dstDir, goFile)), 0o755); err != nil {
+			return fmt.Errorf("create destination directory: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(dstDir, goFile), content, 0o644); err != nil {
+			return fmt.Errorf("write generated file: %w", err)
+		}
+	}
+	return nil
+}
+
+// runProtoc compiles protoFiles with protocImage into the dst directory of
+// tmpDir, which it returns.
+func runProtoc(ctx context.Context, tmpDir, protoDir string, protoFiles []string) (string, error) {
 	uid := os.Getuid()
 
 	absTmpDir, err := filepath.Abs(tmpDir)
 	if err != nil {
-		return fmt.Errorf("get absolute temporary directory: %w", err)
+		return "", fmt.Errorf("get absolute temporary directory: %w", err)
 	}
 
 	tmpDstDir := filepath.Join(absTmpDir, "dst")
 	if err := os.MkdirAll(tmpDstDir, 0o755); err != nil {
-		return fmt.Errorf("create destination directory: %w", err)
+		return "", fmt.Errorf("create destination directory: %w", err)
 	}
 
 	cmdArgs := []string{
@@ -170,7 +421,7 @@ func compileProtoFiles(ctx context.Context, tmpDir, protoDir, namespace, dstDir
 		"-u", fmt.Sprintf("%d", uid),
 		"-v", fmt.Sprintf("%s:%s", absTmpDir, absTmpDir),
 		"-w", absTmpDir,
-		"otel/build-protobuf:0.9.0",
+		protocImage,
 		"--proto_path=" + protoDir,
 		"--go_opt=paths=source_relative",
 		"--go_out=" + tmpDstDir,
@@ -183,16 +434,7 @@ func compileProtoFiles(ctx context.Context, tmpDir, protoDir, namespace, dstDir
 	cmd.Stdout = &buf
 	cmd.Stderr = &buf
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("%s: %s: %s", strings.Join(cmdArgs, " "), err, buf.String())
+		return "", fmt.Errorf("%s: %s: %s", strings.Join(cmdArgs, " "), err, buf.String())
 	}
-
-	// copy to dstDir
-	if err := os.RemoveAll(dstDir); err != nil {
-		return fmt.Errorf("remove destination directory: %w", err)
-	}
-	if err := os.CopyFS(dstDir, os.DirFS(filepath.Join(tmpDstDir, namespace))); err != nil {
-		return fmt.Errorf("copy tmp dst to final dst directory: %w", err)
-	}
-
-	return nil
+	return tmpDstDir, nil
 }
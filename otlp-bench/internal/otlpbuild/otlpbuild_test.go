@@ -55,6 +55,107 @@ func testImport(t *testing.T) {
 	}
 }
 
+func TestBuildManifestChangedInputs(t *testing.T) {
+	dstDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dstDir, "a.pb.go"), []byte("package a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outputs, err := dirHashes(dstDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := map[string]string{"foo/a.proto": "1234", "foo/b.proto": "abcd"}
+	m := buildManifest{Image: protocImage, Inputs: inputs, Outputs: outputs}
+
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	if err := m.write(manifestPath); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadManifest(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(m, loaded); diff != "" {
+		t.Errorf("loadManifest mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, tc := range []struct {
+		desc        string
+		image       string
+		inputs      map[string]string
+		wantChanged []string
+		wantOK      bool
+	}{
+		{"unchanged", protocImage, inputs, nil, true},
+		{"changed input", protocImage, map[string]string{"foo/a.proto": "5678", "foo/b.proto": "abcd"}, []string{"foo/a.proto"}, true},
+		{"new input", protocImage, map[string]string{"foo/a.proto": "1234", "foo/b.proto": "abcd", "foo/c.proto": "ef"}, []string{"foo/c.proto"}, true},
+		{"removed input", protocImage, map[string]string{"foo/a.proto": "1234"}, nil, false},
+		{"changed image", "other-image", inputs, nil, false},
+	} {
+		changed, ok := m.changedInputs(tc.image, tc.inputs, dstDir)
+		if diff := cmp.Diff(tc.wantChanged, changed); diff != "" || ok != tc.wantOK {
+			t.Errorf("%s: changedInputs() = %v, %t, want %v, %t", tc.desc, changed, ok, tc.wantChanged, tc.wantOK)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dstDir, "a.pb.go"), []byte("package b"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.changedInputs(protocImage, inputs, dstDir); ok {
+		t.Error("changedInputs: got ok for modified outputs")
+	}
+
+	empty, err := loadManifest(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := empty.changedInputs(protocImage, nil, dstDir); ok {
+		t.Error("changedInputs: got ok for missing manifest")
+	}
+}
+
+func TestAffectedFiles(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"foo/common.proto":   `syntax = "proto3";`,
+		"foo/resource.proto": `import "foo/common.proto";`,
+		"foo/profiles.proto": "import \"foo/common.proto\";\nimport public \"foo/resource.proto\";",
+		"foo/service.proto":  `import "foo/profiles.proto";`,
+		"foo/logs.proto":     `syntax = "proto3";`,
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	imports, err := protoImports(root, paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"foo/common.proto", "foo/resource.proto"}, imports["foo/profiles.proto"]); diff != "" {
+		t.Errorf("protoImports mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, tc := range []struct {
+		changed []string
+		want    []string
+	}{
+		{[]string{"foo/logs.proto"}, []string{"foo/logs.proto"}},
+		{[]string{"foo/resource.proto"}, []string{"foo/profiles.proto", "foo/resource.proto", "foo/service.proto"}},
+		{[]string{"foo/common.proto"}, []string{"foo/common.proto", "foo/profiles.proto", "foo/resource.proto", "foo/service.proto"}},
+	} {
+		if diff := cmp.Diff(tc.want, affectedFiles(tc.changed, imports)); diff != "" {
+			t.Errorf("affectedFiles(%v) mismatch (-want +got):\n%s", tc.changed, diff)
+		}
+	}
+}
+
 func TestRewriteProtoFile(t *testing.T) {
 	in := bytes.TrimSpace([]byte(`
 syntax = "proto3";
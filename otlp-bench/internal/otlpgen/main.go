@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpbuild"
 )
@@ -36,7 +37,27 @@ func run(ctx context.Context, stdout, stderr io.Writer, args []string) (err erro
 	// /tmp is often mounted on a different filesystem than the dst directory
 	// which causes issues with the docker volume sharing.
 	tmpDir := filepath.Join(filepath.Dir(dst), "tmp")
-	if err := os.RemoveAll(tmpDir); err != nil {
+	cloneDir := filepath.Join(tmpDir, "clone")
+	buildDir := filepath.Join(tmpDir, "build")
+
+	// In offline mode no network git operations are performed. Instead the
+	// checkout left behind by a previous run with KEEP_TMP_DIR=1 (or placed
+	// there manually) is reused, so only the build directory is reset.
+	offline := os.Getenv("OTLPBUILD_OFFLINE") != ""
+	// In incremental mode the build directory is kept between runs so that
+	// otlpbuild can skip compiling unchanged proto files.
+	incremental := os.Getenv("OTLPBUILD_INCREMENTAL") != ""
+	var cleanDir string
+	switch {
+	case offline && incremental:
+	case offline:
+		cleanDir = buildDir
+	case incremental:
+		cleanDir = cloneDir
+	default:
+		cleanDir = tmpDir
+	}
+	if err := os.RemoveAll(cleanDir); err != nil {
 		return fmt.Errorf("remove temporary directory: %w", err)
 	}
 	if err := os.MkdirAll(tmpDir, 0o755); err != nil {
@@ -47,20 +68,23 @@ func run(ctx context.Context, stdout, stderr io.Writer, args []string) (err erro
 			fmt.Fprintf(stderr, "keeping temporary directory: %s\n", tmpDir)
 			return
 		}
-		err = cmp.Or(err, os.RemoveAll(tmpDir))
+		err = cmp.Or(err, os.RemoveAll(cleanDir))
 	}()
 
-	cloneDir := filepath.Join(tmpDir, "clone")
-	if err = clone(ctx, remote, revision, cloneDir); err != nil {
+	if offline {
+		if err = checkout(ctx, revision, cloneDir); err != nil {
+			return fmt.Errorf("offline checkout: %w", err)
+		}
+	} else if err = clone(ctx, remote, revision, cloneDir); err != nil {
 		return fmt.Errorf("clone: %w", err)
 	}
 
-	buildDir := filepath.Join(tmpDir, "build")
 	if err := otlpbuild.Build(ctx, otlpbuild.Config{
 		SrcDir:        filepath.Join(cloneDir, "opentelemetry"),
 		TmpDir:        buildDir,
 		DstDir:        dstAbs,
 		PackagePrefix: pkgPrefix,
+		Incremental:   incremental,
 	}); err != nil {
 		return fmt.Errorf("build: %w", err)
 	}
@@ -83,3 +107,30 @@ func clone(ctx context.Context, remote, revision, cloneDir string) error {
 	}
 	return nil
 }
+
+// checkout switches an existing checkout in cloneDir to revision without
+// touching the network. It fails if the revision is not available locally.
+func checkout(ctx context.Context, revision, cloneDir string) error {
+	if _, err := os.Stat(filepath.Join(cloneDir, ".git")); err != nil {
+		return fmt.Errorf("no existing checkout in %s: %w", cloneDir, err)
+	}
+	if err := git(ctx, cloneDir, "cat-file", "-e", revision+"^{commit}"); err != nil {
+		return fmt.Errorf("revision %s is not present in %s: %w", revision, cloneDir, err)
+	}
+	if err := git(ctx, cloneDir, "checkout", "--quiet", "--detach", revision); err != nil {
+		return fmt.Errorf("checkout %s: %w", revision, err)
+	}
+	return nil
+}
+
+func git(ctx context.Context, dir string, args ...string) error {
+	var buf bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Stdout = &buf
+	cmd.Stderr = &buf
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, buf.String())
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	common "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/common/v1"
+	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// writeJSONPayloads writes every payload of file to <base>.<i>.json in dir.
+// Dictionary references are resolved inline so that each file is
+// self-contained, unless indices is set, in which case the payload is written
+// as plain protojson. Unknown fields are dropped either way.
+func writeJSONPayloads(dir, file string, payloads []*cprofiles.ExportProfilesServiceRequest, indices bool) error {
+	for i, payload := range payloads {
+		var data []byte
+		var err error
+		if indices {
+			data, err = protojson.MarshalOptions{Multiline: true}.Marshal(payload)
+		} else {
+			data, err = json.MarshalIndent(resolveRequest(payload), "", "  ")
+		}
+		if err != nil {
+			return fmt.Errorf("marshal payload %d: %w", i, err)
+		}
+		outPath := filepath.Join(dir, fmt.Sprintf("%s.%d.json", filepath.Base(file), i))
+		if err := os.WriteFile(outPath, append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("write %q: %w", outPath, err)
+		}
+	}
+	return nil
+}
+
+// The json* types mirror the protojson layout of a request, with dictionary
+// references replaced by the values they point to. 64-bit integers that can
+// exceed the precision of a double are strings, as in protojson.
+type (
+	jsonRequest struct {
+		ResourceProfiles []jsonResourceProfiles `json:"resourceProfiles"`
+	}
+	jsonResourceProfiles struct {
+		Resource      map[string]any      `json:"resource,omitempty"`
+		ScopeProfiles []jsonScopeProfiles `json:"scopeProfiles"`
+		SchemaURL     string              `json:"schemaUrl,omitempty"`
+	}
+	jsonScopeProfiles struct {
+		Scope     jsonScope     `json:"scope"`
+		Profiles  []jsonProfile `json:"profiles"`
+		SchemaURL string        `json:"schemaUrl,omitempty"`
+	}
+	jsonScope struct {
+		Name       string         `json:"name,omitempty"`
+		Version    string         `json:"version,omitempty"`
+		Attributes map[string]any `json:"attributes,omitempty"`
+	}
+	jsonProfile struct {
+		SampleType            jsonValueType  `json:"sampleType"`
+		TimeUnixNano          uint64         `json:"timeUnixNano,string"`
+		DurationNano          uint64         `json:"durationNano,string"`
+		PeriodType            jsonValueType  `json:"periodType"`
+		Period                int64          `json:"period,omitempty"`
+		ProfileID             string         `json:"profileId,omitempty"`
+		OriginalPayloadFormat string         `json:"originalPayloadFormat,omitempty"`
+		Attributes            map[string]any `json:"attributes,omitempty"`
+		Samples               []jsonSample   `json:"samples"`
+	}
+	jsonValueType struct {
+		Type string `json:"type"`
+		Unit string `json:"unit"`
+	}
+	jsonSample struct {
+		Stack              []jsonLocation `json:"stack"`
+		Values             []int64        `json:"values,omitempty"`
+		TimestampsUnixNano []string       `json:"timestampsUnixNano,omitempty"`
+		Attributes         map[string]any `json:"attributes,omitempty"`
+		Link               *jsonLink      `json:"link,omitempty"`
+	}
+	jsonLocation struct {
+		Address string     `json:"address,omitempty"`
+		Mapping string     `json:"mapping,omitempty"`
+		Lines   []jsonLine `json:"lines,omitempty"`
+	}
+	jsonLine struct {
+		Function   string `json:"function,omitempty"`
+		SystemName string `json:"systemName,omitempty"`
+		Filename   string `json:"filename,omitempty"`
+		Line       int64  `json:"line,omitempty"`
+		Column     int64  `json:"column,omitempty"`
+	}
+	jsonLink struct {
+		TraceID string `json:"traceId"`
+		SpanID  string `json:"spanId"`
+	}
+)
+
+// resolveRequest returns data with its dictionary references resolved.
+// Out of range indices resolve to zero values rather than failing, so that
+// broken payloads can still be inspected.
+func resolveRequest(data *cprofiles.ExportProfilesServiceRequest) jsonRequest {
+	r := jsonResolver{dict: data.GetDictionary()}
+	req := jsonRequest{ResourceProfiles: []jsonResourceProfiles{}}
+	for _, rp := range data.ResourceProfiles {
+		jrp := jsonResourceProfiles{
+			Resource:      r.keyValues(rp.GetResource().GetAttributes()),
+			ScopeProfiles: []jsonScopeProfiles{},
+			SchemaURL:     rp.SchemaUrl,
+		}
+		for _, sp := range rp.ScopeProfiles {
+			jsp := jsonScopeProfiles{
+				Scope: jsonScope{
+					Name:       sp.GetScope().GetName(),
+					Version:    sp.GetScope().GetVersion(),
+					Attributes: r.keyValues(sp.GetScope().GetAttributes()),
+				},
+				Profiles:  []jsonProfile{},
+				SchemaURL: sp.SchemaUrl,
+			}
+			for _, p := range sp.Profiles {
+				jsp.Profiles = append(jsp.Profiles, r.profile(p))
+			}
+			jrp.ScopeProfiles = append(jrp.ScopeProfiles, jsp)
+		}
+		req.ResourceProfiles = append(req.ResourceProfiles, jrp)
+	}
+	return req
+}
+
+type jsonResolver struct {
+	dict *profiles.ProfilesDictionary
+}
+
+func (r jsonResolver) profile(p *profiles.Profile) jsonProfile {
+	jp := jsonProfile{
+		SampleType:            r.valueType(p.GetSampleType()),
+		TimeUnixNano:          p.TimeUnixNano,
+		DurationNano:          p.DurationNano,
+		PeriodType:            r.valueType(p.GetPeriodType()),
+		Period:                p.Period,
+		ProfileID:             hex.EncodeToString(p.ProfileId),
+		OriginalPayloadFormat: p.OriginalPayloadFormat,
+		Attributes:            r.attributes(p.AttributeIndices),
+		Samples:               []jsonSample{},
+	}
+	for _, s := range p.Samples {
+		js := jsonSample{
+			Stack:      r.stack(s.StackIndex),
+			Values:     s.Values,
+			Attributes: r.attributes(s.AttributeIndices),
+		}
+		for _, ts := range s.TimestampsUnixNano {
+			js.TimestampsUnixNano = append(js.TimestampsUnixNano, strconv.FormatUint(ts, 10))
+		}
+		if link := at(r.dict.GetLinkTable(), s.LinkIndex); s.LinkIndex != 0 && link != nil {
+			js.Link = &jsonLink{TraceID: hex.EncodeToString(link.TraceId), SpanID: hex.EncodeToString(link.SpanId)}
+		}
+		jp.Samples = append(jp.Samples, js)
+	}
+	return jp
+}
+
+func (r jsonResolver) stack(idx int32) []jsonLocation {
+	locs := []jsonLocation{}
+	for _, locIdx := range at(r.dict.GetStackTable(), idx).GetLocationIndices() {
+		loc := at(r.dict.GetLocationTable(), locIdx)
+		jl := jsonLocation{
+			Mapping: r.str(at(r.dict.GetMappingTable(), loc.GetMappingIndex()).GetFilenameStrindex()),
+		}
+		if loc.GetAddress() != 0 {
+			jl.Address = fmt.Sprintf("0x%x", loc.GetAddress())
+		}
+		for _, line := range loc.GetLines() {
+			fn := at(r.dict.GetFunctionTable(), line.GetFunctionIndex())
+			jl.Lines = append(jl.Lines, jsonLine{
+				Function:   r.str(fn.GetNameStrindex()),
+				SystemName: r.str(fn.GetSystemNameStrindex()),
+				Filename:   r.str(fn.GetFilenameStrindex()),
+				Line:       line.GetLine(),
+				Column:     line.GetColumn(),
+			})
+		}
+		locs = append(locs, jl)
+	}
+	return locs
+}
+
+func (r jsonResolver) valueType(vt *profiles.ValueType) jsonValueType {
+	return jsonValueType{Type: r.str(vt.GetTypeStrindex()), Unit: r.str(vt.GetUnitStrindex())}
+}
+
+func (r jsonResolver) str(idx int32) string {
+	if idx < 0 || int(idx) >= len(r.dict.GetStringTable()) {
+		return ""
+	}
+	return r.dict.GetStringTable()[idx]
+}
+
+// attributes resolves indices into the attribute table. Units are dropped.
+func (r jsonResolver) attributes(indices []int32) map[string]any {
+	if len(indices) == 0 {
+		return nil
+	}
+	attrs := map[string]any{}
+	for _, idx := range indices {
+		attr := at(r.dict.GetAttributeTable(), idx)
+		attrs[r.str(attr.GetKeyStrindex())] = r.anyValue(attr.GetValue())
+	}
+	return attrs
+}
+
+func (r jsonResolver) keyValues(kvs []*common.KeyValue) map[string]any {
+	if len(kvs) == 0 {
+		return nil
+	}
+	attrs := map[string]any{}
+	for _, kv := range kvs {
+		key := kv.GetKey()
+		if kv.GetKeyRef() != 0 {
+			key = r.str(kv.GetKeyRef())
+		}
+		attrs[key] = r.anyValue(kv.GetValue())
+	}
+	return attrs
+}
+
+func (r jsonResolver) anyValue(av *common.AnyValue) any {
+	switch v := av.GetValue().(type) {
+	case *common.AnyValue_StringValue:
+		return v.StringValue
+	case *common.AnyValue_StringRef:
+		return r.str(v.StringRef)
+	case *common.AnyValue_BoolValue:
+		return v.BoolValue
+	case *common.AnyValue_IntValue:
+		return v.IntValue
+	case *common.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *common.AnyValue_BytesValue:
+		return hex.EncodeToString(v.BytesValue)
+	case *common.AnyValue_ArrayValue:
+		values := []any{}
+		for _, elem := range v.ArrayValue.GetValues() {
+			values = append(values, r.anyValue(elem))
+		}
+		return values
+	case *common.AnyValue_KvlistValue:
+		return r.keyValues(v.KvlistValue.GetValues())
+	default:
+		return nil
+	}
+}
+
+// at returns table[idx], or nil if idx is out of range.
+func at[T any](table []*T, idx int32) *T {
+	if idx < 0 || int(idx) >= len(table) {
+		return nil
+	}
+	return table[idx]
+}
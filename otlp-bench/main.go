@@ -7,19 +7,29 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	gohash "hash"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"runtime"
 	"slices"
 	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
 	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
 	common "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/common/v1"
 	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
 	resource "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/resource/v1"
 	"github.com/urfave/cli/v3"
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -48,19 +58,149 @@ func (a *App) Run(ctx context.Context, args ...string) error {
 		Writer:    a.Stdout,
 		ErrWriter: a.Stderr,
 		ArgsUsage: "file [file ...]",
+		Commands: []*cli.Command{
+			a.validateCommand(),
+			a.convertCommand(),
+		},
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:    "out",
-				Usage:   "directory to write results",
+				Usage:   "directory to write results, or - to write only the summary to stdout",
 				Aliases: []string{"o"},
 				Value:   "otlp-bench-results",
 			},
+			&cli.StringFlag{
+				Name:  "out-template",
+				Usage: "write results to a new directory named by this template instead of --out, e.g. results/{timestamp}-{label}; {timestamp} is the UTC start time, {label} the --label",
+			},
+			&cli.StringFlag{
+				Name:  "label",
+				Usage: "label of the run, for {label} in --out-template",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "list the input files with their number of payloads, the encodings and the output files of the run, and exit without measuring",
+			},
+			&cli.StringFlag{
+				Name:  "out-format",
+				Usage: "format of the summary, one of csv, parquet",
+				Value: "csv",
+			},
+			&cli.StringFlag{
+				Name:  "proto-version",
+				Usage: "proto version to decode the input with, e.g. upstream; only " + benchProtoVersion + " payloads are transformed by the strategies, others are measured as decoded",
+				Value: benchProtoVersion,
+			},
+			&cli.StringFlag{
+				Name:  "framing",
+				Usage: "format of the input, one of " + strings.Join(framings, ", ") + "; auto tries a single message, then length-prefixed, then grpc",
+				Value: formatAuto,
+			},
+			&cli.StringSliceFlag{
+				Name:  "compare-versions",
+				Usage: "decode the input with two proto versions (e.g. gh733,upstream) and report where they diverge instead of benchmarking",
+			},
+			&cli.StringSliceFlag{
+				Name:  "map-versions",
+				Usage: "decode the input with the first of two proto versions (e.g. gh733,upstream), copy it field by field into the second and report the sizes of both instead of benchmarking",
+			},
 			&cli.IntFlag{
 				Name:    "samples",
 				Usage:   "scale samples in baseline profile by duplicating them this many times",
 				Aliases: []string{"s"},
 				Value:   1,
 			},
+			&cli.IntFlag{
+				Name:  "top-strings",
+				Usage: "write this many most referenced and longest strings of each payload to strings.txt",
+			},
+			&cli.BoolFlag{
+				Name:  "value-histogram",
+				Usage: "write the number of samples by bit length of their first value to values.csv",
+			},
+			&cli.BoolFlag{
+				Name:  "dictionary-sizes",
+				Usage: "write the number of entries and encoded bytes of every dictionary table to dictionary.csv",
+			},
+			&cli.BoolFlag{
+				Name:  "profile-format-breakdown",
+				Usage: "write the number of profiles and original_payload bytes by original_payload_format to payload_formats.csv",
+			},
+			&cli.IntFlag{
+				Name:  "repeat",
+				Usage: "run the measurement this many times and write min/mean/max per size column to repeat.csv",
+				Value: 1,
+			},
+			&cli.BoolFlag{
+				Name:  "mem-stats",
+				Usage: "write the bytes and objects allocated by each strategy's transform to memstats.csv",
+			},
+			&cli.IntFlag{
+				Name:  "timing-runs",
+				Usage: "compress the output of every strategy this many times per codec and write the median/p95 times to timing.csv",
+			},
+			&cli.IntFlag{
+				Name:  "warmup",
+				Usage: "with --timing-runs, compress this many times per codec before the timed runs",
+			},
+			&cli.StringFlag{
+				Name:  "json-out",
+				Usage: "directory to write every decoded payload to as <file>.<payload>.json, with dictionary references resolved",
+			},
+			&cli.BoolFlag{
+				Name:  "json-indices",
+				Usage: "with --json-out, keep dictionary references as indices and write plain protojson",
+			},
+			&cli.StringFlag{
+				Name:  "emit",
+				Usage: "write the re-encoded output of this strategy to <file>.<strategy>.otlp, e.g. to check it with profcheck",
+			},
+			&cli.StringSliceFlag{
+				Name:  "filter-resource",
+				Usage: "measure only the resource profiles with this key=value resource attribute, e.g. service.name=checkout; repeat to require several",
+			},
+			&cli.BoolFlag{
+				Name:  "profile-ids-unique",
+				Usage: "report the non-empty profile IDs that occur more than once across all files, with where they occur",
+			},
+			&cli.StringSliceFlag{
+				Name:  "strip-keys",
+				Usage: "measure the strip-attrs strategy, which removes the attributes with these keys, e.g. k8s.pod.uid,container.id",
+			},
+			&cli.StringSliceFlag{
+				Name:  "exclude-strategy",
+				Usage: "do not measure the strategy with this name, e.g. resource-attr-dict, to skip an expensive transform; repeat to exclude several. A strategy can only be excluded together with the strategies based on it, and baseline can't be excluded",
+			},
+			&cli.FloatFlag{
+				Name:  "sample-rate",
+				Usage: "measure the lossy downsample strategy, which keeps this fraction of the samples of every profile, e.g. 0.1, and downsample-compact, which also drops the dictionary entries no longer referenced",
+			},
+			&cli.BoolFlag{
+				Name:  "emit-compressed",
+				Usage: "write the measured gzip and zstd output of every strategy to <file>.<strategy>.gz and .zst",
+			},
+			&cli.IntFlag{
+				Name:  "dump-samples",
+				Usage: "write at most this many samples per profile to the .txt dumps, 0 disables the dumps",
+				Value: 1000,
+			},
+			&cli.BoolFlag{
+				Name:    "quiet",
+				Usage:   "do not print a summary of the sizes to stdout",
+				Aliases: []string{"q"},
+			},
+			&cli.IntFlag{
+				Name:  "limit",
+				Usage: "measure only the first `N` payloads of every file, 0 measures all; limited files are marked in the limited column of the summary",
+			},
+			&cli.BoolFlag{
+				Name:  "normalize-time",
+				Usage: "rebase the timestamps of every profile to start at 0 before measuring, so that captures taken at different times compare by their structure; the sizes are not real-world sizes and are marked in the time_normalized column of the summary",
+			},
+			&cli.BoolFlag{
+				Name:  "merge",
+				Usage: "combine the payloads of all files into one payload with a shared dictionary and measure it as file \"" + mergedFilename + "\"",
+			},
 		},
 		Arguments: []cli.Argument{
 			&cli.StringArgs{
@@ -72,103 +212,1134 @@ func (a *App) Run(ctx context.Context, args ...string) error {
 			},
 		},
 		Action: func(ctx context.Context, cmd *cli.Command) error {
-			samples := cmd.Int("samples")
-			outDir := cmd.String("out")
+			if versions := cmd.StringSlice("compare-versions"); len(versions) > 0 {
+				return a.runVersionPair("compare-versions", versions, cmd.String("framing"), cmd.StringArgs("file"), compareVersions)
+			}
+			if versions := cmd.StringSlice("map-versions"); len(versions) > 0 {
+				return a.runVersionPair("map-versions", versions, cmd.String("framing"), cmd.StringArgs("file"), mapVersions)
+			}
+			if cmd.IsSet("out") && cmd.IsSet("out-template") {
+				return fmt.Errorf("--out and --out-template are mutually exclusive")
+			}
+			opts := runOptions{
+				outDir:            cmd.String("out"),
+				outTemplate:       cmd.String("out-template"),
+				label:             cmd.String("label"),
+				dryRun:            cmd.Bool("dry-run"),
+				outFormat:         cmd.String("out-format"),
+				samples:           cmd.Int("samples"),
+				repeat:            cmd.Int("repeat"),
+				topStrings:        cmd.Int("top-strings"),
+				valueHist:         cmd.Bool("value-histogram"),
+				formats:           cmd.Bool("profile-format-breakdown"),
+				dictSizes:         cmd.Bool("dictionary-sizes"),
+				memStats:          cmd.Bool("mem-stats"),
+				jsonOut:           cmd.String("json-out"),
+				jsonIndices:       cmd.Bool("json-indices"),
+				merge:             cmd.Bool("merge"),
+				dumpSamples:       cmd.Int("dump-samples"),
+				emit:              cmd.String("emit"),
+				emitCompressed:    cmd.Bool("emit-compressed"),
+				stripKeys:         cmd.StringSlice("strip-keys"),
+				sampleRate:        cmd.Float("sample-rate"),
+				excludeStrategies: cmd.StringSlice("exclude-strategy"),
+				framing:           cmd.String("framing"),
+				filterResource:    cmd.StringSlice("filter-resource"),
+				limit:             cmd.Int("limit"),
+				normalizeTime:     cmd.Bool("normalize-time"),
+				uniqueIDs:         cmd.Bool("profile-ids-unique"),
+				warmup:            cmd.Int("warmup"),
+				timingRuns:        cmd.Int("timing-runs"),
+				quiet:             cmd.Bool("quiet"),
+				protoVersion:      cmd.String("proto-version"),
+			}
 			files := cmd.StringArgs("file")
-			return a.run(ctx, samples, outDir, files...)
+			return a.run(ctx, opts, files...)
 		},
 	}
 	return cmd.Run(ctx, args)
 }
 
-func (a *App) run(_ context.Context, samples int, outDir string, files ...string) error {
+// runOptions holds the flags that control a benchmark run.
+type runOptions struct {
+	outDir string
+	// outTemplate names a new directory for the results in place of outDir,
+	// see expandOutTemplate.
+	outTemplate string
+	// label is the value of {label} in outTemplate.
+	label string
+	// dryRun lists what the run would do instead of doing it.
+	dryRun    bool
+	outFormat string
+	samples   int
+	repeat    int
+	// topStrings is the number of strings to list in strings.txt, or 0.
+	topStrings int
+	// valueHist writes a histogram of the sample value sizes to values.csv.
+	valueHist bool
+	// formats writes the original payload bytes by format to
+	// payload_formats.csv.
+	formats bool
+	// dictSizes writes the sizes of the dictionary tables to dictionary.csv.
+	dictSizes bool
+	// memStats records the allocations of every transform in memstats.csv.
+	memStats bool
+	// timingRuns is the number of times the output of every strategy is
+	// compressed with each codec to record the timings in timing.csv, or 0.
+	timingRuns int
+	// warmup is the number of untimed compressions before the timed ones.
+	warmup int
+	// jsonOut is the directory to write the decoded payloads to as JSON, or "".
+	jsonOut string
+	// jsonIndices keeps dictionary references as indices in the JSON output.
+	jsonIndices bool
+	// merge measures all files combined into one payload, see mergeFiles.
+	merge bool
+	// dumpSamples is the number of samples per profile written to the text
+	// dumps, 0 disables them.
+	dumpSamples int
+	// quiet suppresses the human readable size summary on stdout.
+	quiet bool
+	// emit is the name of the strategy whose output is written to a file,
+	// or "".
+	emit string
+	// stripKeys are the attribute keys removed by the strip-attrs strategy,
+	// which is only measured if it is not empty.
+	stripKeys []string
+	// sampleRate is the fraction of samples kept by the downsample
+	// strategies, which are only measured if it is not 0.
+	sampleRate float64
+	// excludeStrategies are the names of the strategies not to measure.
+	excludeStrategies []string
+	// emitCompressed writes the gzip and zstd compressed output of every
+	// strategy to files.
+	emitCompressed bool
+	// protoVersion is the name of the proto version to decode the input
+	// with, see protoVersions.
+	protoVersion string
+	// framing is the format of the input files, see framings.
+	framing string
+	// filterResource are the key=value resource attributes the resource
+	// profiles must have to be measured, see filterResources.
+	filterResource []string
+	// limit is the number of payloads measured per file, or 0 for all.
+	limit int
+	// normalizeTime rebases the timestamps of every profile to 0 before
+	// measuring, see normalizeTime.
+	normalizeTime bool
+	// uniqueIDs reports duplicate profile IDs across all files.
+	uniqueIDs bool
+}
+
+func (a *App) run(_ context.Context, opts runOptions, files ...string) error {
+	outDir := opts.outDir
+	switch {
+	case opts.outTemplate != "":
+		dir, err := expandOutTemplate(opts.outTemplate, opts.label, time.Now())
+		if err != nil {
+			return err
+		}
+		outDir = dir
+	case opts.label != "":
+		return fmt.Errorf("--label requires --out-template")
+	}
 	if outDir == "" {
 		return fmt.Errorf("output directory must not be empty")
 	}
-
-	os.RemoveAll(outDir)
-	if err := os.MkdirAll(outDir, 0o755); err != nil {
-		return fmt.Errorf("create output directory %q: %w", outDir, err)
+	if opts.repeat < 1 {
+		return fmt.Errorf("repeat must be at least 1, got %d", opts.repeat)
 	}
-
-	resultsPath := filepath.Join(outDir, "summary.csv")
-	outFile, err := os.Create(resultsPath)
+	if opts.dumpSamples < 0 {
+		return fmt.Errorf("dump-samples must not be negative, got %d", opts.dumpSamples)
+	}
+	if opts.timingRuns < 0 {
+		return fmt.Errorf("timing-runs must not be negative, got %d", opts.timingRuns)
+	}
+	if opts.warmup < 0 {
+		return fmt.Errorf("warmup must not be negative, got %d", opts.warmup)
+	}
+	if opts.warmup > 0 && opts.timingRuns == 0 {
+		return fmt.Errorf("--warmup requires --timing-runs")
+	}
+	if opts.limit < 0 {
+		return fmt.Errorf("limit must not be negative, got %d", opts.limit)
+	}
+	if opts.sampleRate < 0 || opts.sampleRate > 1 {
+		return fmt.Errorf("sample-rate must be between 0 and 1, got %v", opts.sampleRate)
+	}
+	if opts.limit > 0 && opts.merge {
+		return fmt.Errorf("--limit and --merge are mutually exclusive")
+	}
+	if !slices.Contains(outFormats, opts.outFormat) {
+		return fmt.Errorf("unknown output format %q, must be one of %s", opts.outFormat, strings.Join(outFormats, ", "))
+	}
+	if !slices.Contains(framings, opts.framing) {
+		return fmt.Errorf("unknown framing %q, must be one of %s", opts.framing, strings.Join(framings, ", "))
+	}
+	version, err := lookupProtoVersion(opts.protoVersion)
 	if err != nil {
-		return fmt.Errorf("create results file %q: %w", resultsPath, err)
+		return err
+	}
+	if !version.transforms {
+		// These options work on the decoded gh733 payloads.
+		switch {
+		case opts.samples > 1:
+			return fmt.Errorf("--samples requires --proto-version=%s", benchProtoVersion)
+		case opts.topStrings > 0:
+			return fmt.Errorf("--top-strings requires --proto-version=%s", benchProtoVersion)
+		case opts.valueHist:
+			return fmt.Errorf("--value-histogram requires --proto-version=%s", benchProtoVersion)
+		case opts.formats:
+			return fmt.Errorf("--profile-format-breakdown requires --proto-version=%s", benchProtoVersion)
+		case opts.emit != "":
+			return fmt.Errorf("--emit requires --proto-version=%s", benchProtoVersion)
+		case opts.emitCompressed:
+			return fmt.Errorf("--emit-compressed requires --proto-version=%s", benchProtoVersion)
+		case opts.timingRuns > 0:
+			return fmt.Errorf("--timing-runs requires --proto-version=%s", benchProtoVersion)
+		case len(opts.stripKeys) > 0:
+			return fmt.Errorf("--strip-keys requires --proto-version=%s", benchProtoVersion)
+		case opts.sampleRate != 0:
+			return fmt.Errorf("--sample-rate requires --proto-version=%s", benchProtoVersion)
+		case len(opts.excludeStrategies) > 0:
+			return fmt.Errorf("--exclude-strategy requires --proto-version=%s", benchProtoVersion)
+		case len(opts.filterResource) > 0:
+			return fmt.Errorf("--filter-resource requires --proto-version=%s", benchProtoVersion)
+		case opts.uniqueIDs:
+			return fmt.Errorf("--profile-ids-unique requires --proto-version=%s", benchProtoVersion)
+		case opts.jsonOut != "":
+			return fmt.Errorf("--json-out requires --proto-version=%s", benchProtoVersion)
+		case opts.merge:
+			return fmt.Errorf("--merge requires --proto-version=%s", benchProtoVersion)
+		case opts.normalizeTime:
+			return fmt.Errorf("--normalize-time requires --proto-version=%s", benchProtoVersion)
+		}
+	}
+	filters, err := parseResourceFilters(opts.filterResource)
+	if err != nil {
+		return err
+	}
+	files, err = expandGlobs(files)
+	if err != nil {
+		return err
 	}
-	defer outFile.Close()
-
-	csvWriter := csv.NewWriter(outFile)
 
-	if err := csvWriter.Write([]string{"file", "encoding", "payloads", "uncompressed_bytes", "gzip_6_bytes"}); err != nil {
-		return fmt.Errorf("write header row: %w", err)
+	// With --out=- only the summary is written to stdout, skipping the
+	// output directory, the input copies and the text dumps.
+	toStdout := outDir == "-"
+	if toStdout && opts.repeat > 1 {
+		return fmt.Errorf("--repeat requires an output directory")
 	}
-	for _, file := range files {
-		data, err := os.ReadFile(file)
+	if toStdout && opts.topStrings > 0 {
+		return fmt.Errorf("--top-strings requires an output directory")
+	}
+	if toStdout && opts.memStats {
+		return fmt.Errorf("--mem-stats requires an output directory")
+	}
+	if toStdout && opts.timingRuns > 0 {
+		return fmt.Errorf("--timing-runs requires an output directory")
+	}
+	if toStdout && opts.valueHist {
+		return fmt.Errorf("--value-histogram requires an output directory")
+	}
+	if toStdout && opts.formats {
+		return fmt.Errorf("--profile-format-breakdown requires an output directory")
+	}
+	if toStdout && opts.dictSizes {
+		return fmt.Errorf("--dictionary-sizes requires an output directory")
+	}
+	if toStdout && opts.emitCompressed {
+		return fmt.Errorf("--emit-compressed requires an output directory")
+	}
+	if err := checkExcludedStrategies(opts); err != nil {
+		return err
+	}
+	if opts.emit != "" {
+		if toStdout {
+			return fmt.Errorf("--emit requires an output directory")
+		}
+		if !slices.ContainsFunc(opts.strategies(), func(s strategy) bool { return s.name == opts.emit }) {
+			var names []string
+			for _, s := range opts.strategies() {
+				names = append(names, s.name)
+			}
+			return fmt.Errorf("unknown strategy %q, must be one of %s", opts.emit, strings.Join(names, ", "))
+		}
+	}
+	if opts.dryRun {
+		dryRun(a.Stdout, opts, version, files, outDir, toStdout)
+		return nil
+	}
+	m := manifest{Files: files, Samples: opts.samples, Repeat: opts.repeat, Merge: opts.merge, NormalizeTime: opts.normalizeTime, ProtoVersion: version.name, Label: opts.label}
+	var results io.Writer = a.Stdout
+	if !toStdout {
+		if opts.outTemplate != "" {
+			// Every run gets a new directory, so earlier results are kept.
+			outDir, err = createUniqueDir(outDir)
+			if err != nil {
+				return fmt.Errorf("create output directory: %w", err)
+			}
+			fmt.Fprintf(a.Stderr, "writing results to %s\n", outDir)
+		} else {
+			os.RemoveAll(outDir)
+			if err := os.MkdirAll(outDir, 0o755); err != nil {
+				return fmt.Errorf("create output directory %q: %w", outDir, err)
+			}
+		}
+
+		resultsPath := filepath.Join(outDir, summaryFilename(opts.outFormat))
+		outFile, err := os.Create(resultsPath)
 		if err != nil {
-			return fmt.Errorf("read file: %w", err)
+			return fmt.Errorf("create results file %q: %w", resultsPath, err)
 		}
+		defer outFile.Close()
+		results = outFile
 
-		// Copy input file to output directory
-		copyPath := filepath.Join(outDir, filepath.Base(file))
-		if err := os.WriteFile(copyPath, data, 0644); err != nil {
-			return fmt.Errorf("copy input file to %q: %w", copyPath, err)
+		if err := writeManifest(outDir, m); err != nil {
+			return err
 		}
+	}
 
-		baselinePayloads, err := unmarshalOTLP(data)
+	summary, err := newSummaryWriter(results, opts.outFormat)
+	if err != nil {
+		return err
+	}
+	var repeatWriter *csv.Writer
+	if opts.repeat > 1 {
+		repeatPath := filepath.Join(outDir, "repeat.csv")
+		repeatFile, err := os.Create(repeatPath)
 		if err != nil {
-			return fmt.Errorf("unmarshal gh733 profile: %w", err)
+			return fmt.Errorf("create repeat file %q: %w", repeatPath, err)
 		}
-
-		var stats struct {
-			baseline         profileSize
-			splitByProcess   profileSize
-			resourceAttrDict profileSize
+		defer repeatFile.Close()
+		repeatWriter = csv.NewWriter(repeatFile)
+		if err := repeatWriter.Write([]string{"file", "encoding", "column", "runs", "min", "mean", "max"}); err != nil {
+			return fmt.Errorf("write repeat header row: %w", err)
+		}
+	}
+	if opts.jsonOut != "" {
+		if err := os.MkdirAll(opts.jsonOut, 0o755); err != nil {
+			return fmt.Errorf("create json output directory %q: %w", opts.jsonOut, err)
+		}
+	}
+	var memStatsWriter *csv.Writer
+	if opts.memStats {
+		memStatsPath := filepath.Join(outDir, "memstats.csv")
+		memStatsFile, err := os.Create(memStatsPath)
+		if err != nil {
+			return fmt.Errorf("create mem stats file %q: %w", memStatsPath, err)
 		}
-		for _, baseline := range baselinePayloads {
-			if samples > 1 {
-				scaleSamples(baseline, samples)
+		defer memStatsFile.Close()
+		memStatsWriter = csv.NewWriter(memStatsFile)
+		if err := memStatsWriter.Write([]string{"file", "encoding", "alloc_bytes", "allocs"}); err != nil {
+			return fmt.Errorf("write mem stats header row: %w", err)
+		}
+	}
+	var timingWriter *csv.Writer
+	if opts.timingRuns > 0 {
+		timingPath := filepath.Join(outDir, "timing.csv")
+		timingFile, err := os.Create(timingPath)
+		if err != nil {
+			return fmt.Errorf("create timing file %q: %w", timingPath, err)
+		}
+		defer timingFile.Close()
+		timingWriter = csv.NewWriter(timingFile)
+		if err := timingWriter.Write([]string{"file", "encoding", "codec", "runs", "median_ns", "p95_ns"}); err != nil {
+			return fmt.Errorf("write timing header row: %w", err)
+		}
+	}
+	var valuesWriter *csv.Writer
+	if opts.valueHist {
+		valuesPath := filepath.Join(outDir, "values.csv")
+		valuesFile, err := os.Create(valuesPath)
+		if err != nil {
+			return fmt.Errorf("create values file %q: %w", valuesPath, err)
+		}
+		defer valuesFile.Close()
+		valuesWriter = csv.NewWriter(valuesFile)
+		if err := valuesWriter.Write([]string{"file", "bits", "varint_bytes", "samples"}); err != nil {
+			return fmt.Errorf("write values header row: %w", err)
+		}
+	}
+	var formatsWriter *csv.Writer
+	if opts.formats {
+		formatsPath := filepath.Join(outDir, "payload_formats.csv")
+		formatsFile, err := os.Create(formatsPath)
+		if err != nil {
+			return fmt.Errorf("create payload formats file %q: %w", formatsPath, err)
+		}
+		defer formatsFile.Close()
+		formatsWriter = csv.NewWriter(formatsFile)
+		if err := formatsWriter.Write([]string{"file", "format", "profiles", "bytes", "payload_fraction"}); err != nil {
+			return fmt.Errorf("write payload formats header row: %w", err)
+		}
+	}
+	var dictWriter *csv.Writer
+	if opts.dictSizes {
+		dictPath := filepath.Join(outDir, "dictionary.csv")
+		dictFile, err := os.Create(dictPath)
+		if err != nil {
+			return fmt.Errorf("create dictionary file %q: %w", dictPath, err)
+		}
+		defer dictFile.Close()
+		dictWriter = csv.NewWriter(dictFile)
+		if err := dictWriter.Write([]string{"file", "table", "entries", "bytes"}); err != nil {
+			return fmt.Errorf("write dictionary header row: %w", err)
+		}
+	}
+	// With --merge, all files are measured as a single payload.
+	var merged []byte
+	if opts.merge {
+		merged, err = mergeFiles(files, outDir, toStdout, opts.framing)
+		if err != nil {
+			return err
+		}
+		files = []string{mergedFilename}
+		opts.framing = formatSingle
+	}
+	var skipped []skippedFile
+	var ids profileIDs
+	for _, file := range files {
+		// A panic on one file, e.g. on malformed input, skips the file
+		// instead of losing the results of the whole run.
+		recovered, err := catchPanic(func() error {
+			data := merged
+			if !opts.merge {
+				data, err = readInput(file, outDir, toStdout)
+				if err != nil {
+					return err
+				}
+			}
+			// The limited and filtered payloads are re-encoded, so the
+			// framing is only changed for this file.
+			opts := opts
+			var limited bool
+			if opts.limit > 0 {
+				data, limited, err = limitPayloads(data, opts.framing, version.newRequest, opts.limit)
+				if err != nil {
+					return fmt.Errorf("unmarshal %s profile: %w", version.name, err)
+				}
+				opts.framing = formatLengthPrefixed
+			}
+			if len(filters) > 0 {
+				var kept int
+				data, kept, err = filterResources(data, opts.framing, filters)
+				if err != nil {
+					return fmt.Errorf("%s: filter resources: %w", file, err)
+				}
+				if kept == 0 {
+					fmt.Fprintf(a.Stderr, "warning: %s: no resource matches --filter-resource\n", file)
+				}
+				opts.framing = formatLengthPrefixed
+			}
+			if opts.normalizeTime {
+				data, err = normalizeTime(data, opts.framing)
+				if err != nil {
+					return fmt.Errorf("%s: normalize time: %w", file, err)
+				}
+				opts.framing = formatLengthPrefixed
+			}
+
+			if opts.topStrings > 0 || opts.jsonOut != "" || opts.valueHist || opts.formats || opts.uniqueIDs || opts.dictSizes {
+				msgs, err := unmarshalFramed(data, opts.framing, version.newRequest)
+				if err != nil {
+					return fmt.Errorf("unmarshal %s profile: %w", version.name, err)
+				}
+				if opts.dictSizes {
+					if err := writeDictionaryRows(dictWriter, file, dictionaryTables(msgs)); err != nil {
+						return fmt.Errorf("write dictionary rows: %w", err)
+					}
+					dictWriter.Flush()
+				}
+				// The options below require --proto-version=gh733, so
+				// every message is a gh733 request if they are set.
+				var payloads []*cprofiles.ExportProfilesServiceRequest
+				for _, msg := range msgs {
+					if req, ok := msg.(*cprofiles.ExportProfilesServiceRequest); ok {
+						payloads = append(payloads, req)
+					}
+				}
+				if opts.uniqueIDs {
+					ids.add(file, payloads)
+				}
+				if opts.topStrings > 0 {
+					if err := writeTopStrings(outDir, file, payloads, opts.topStrings); err != nil {
+						return fmt.Errorf("write top strings: %w", err)
+					}
+				}
+				if opts.valueHist {
+					var h valueHistogram
+					for _, payload := range payloads {
+						h.add(payload)
+					}
+					if err := writeValueHistogram(valuesWriter, file, &h); err != nil {
+						return fmt.Errorf("write value histogram: %w", err)
+					}
+					valuesWriter.Flush()
+				}
+				if opts.formats {
+					var f payloadFormats
+					for _, payload := range payloads {
+						f.add(payload)
+					}
+					if err := writePayloadFormats(formatsWriter, file, &f); err != nil {
+						return fmt.Errorf("write payload formats: %w", err)
+					}
+					formatsWriter.Flush()
+				}
+				if opts.jsonOut != "" {
+					if err := writeJSONPayloads(opts.jsonOut, file, payloads, opts.jsonIndices); err != nil {
+						return fmt.Errorf("write json payloads: %w", err)
+					}
+				}
 			}
 
 			baseFilename := filepath.Base(file)
-			if err := appendTextProfileToFile(outDir, baseFilename, "baseline", baseline); err != nil {
-				return fmt.Errorf("write baseline profile: %w", err)
+			var runs [][]encodingSize
+			var payloadCount int
+			var emitted []*cprofiles.ExportProfilesServiceRequest
+			compressed := map[string]*encodedPayload{}
+			for run := range opts.repeat {
+				// Text dumps are only written once, they don't change between runs.
+				dump := func(suffix string, data *cprofiles.ExportProfilesServiceRequest, enc encodedPayload) error {
+					if toStdout || run > 0 {
+						return nil
+					}
+					if suffix == opts.emit {
+						emitted = append(emitted, data)
+					}
+					if opts.emitCompressed {
+						// Concatenated gzip members and zstd frames are valid
+						// streams, so the files decompress to the payloads one
+						// after the other and their sizes match the summary.
+						c := compressed[suffix]
+						if c == nil {
+							c = &encodedPayload{}
+							compressed[suffix] = c
+						}
+						c.gzip6 = append(c.gzip6, enc.gzip6...)
+						c.zstd3 = append(c.zstd3, enc.zstd3...)
+					}
+					if opts.dumpSamples == 0 {
+						return nil
+					}
+					return appendTextProfileToFile(outDir, baseFilename, suffix, data, opts.dumpSamples)
+				}
+				var sizes []encodingSize
+				var payloads int
+				if version.transforms {
+					sizes, payloads, err = a.measureFile(file, data, opts, dump)
+				} else {
+					sizes, payloads, err = measureDecoded(data, opts.framing, version)
+				}
+				if err != nil {
+					return err
+				}
+				runs = append(runs, sizes)
+				payloadCount = payloads
 			}
-			baselineSizes, err := profileSizes(baseline)
-			if err != nil {
-				return fmt.Errorf("calculate baseline sizes: %w", err)
+			if opts.emit != "" {
+				emitPath := filepath.Join(outDir, baseFilename+"."+opts.emit+".otlp")
+				if err := writePayloads(emitPath, emitted); err != nil {
+					return fmt.Errorf("emit %s: %w", opts.emit, err)
+				}
+			}
+			if opts.emitCompressed {
+				for _, s := range opts.strategies() {
+					c := compressed[s.name]
+					if c == nil {
+						continue
+					}
+					for ext, data := range map[string][]byte{"gz": c.gzip6, "zst": c.zstd3} {
+						path := filepath.Join(outDir, baseFilename+"."+s.name+"."+ext)
+						if err := os.WriteFile(path, data, 0o644); err != nil {
+							return fmt.Errorf("write compressed %s output %q: %w", s.name, path, err)
+						}
+					}
+				}
 			}
-			stats.baseline = stats.baseline.Add(baselineSizes)
 
-			byProcess := splitByProcess(baseline)
-			if err := appendTextProfileToFile(outDir, baseFilename, "split-by-process", byProcess); err != nil {
-				return fmt.Errorf("write split-by-process profile: %w", err)
+			for i := range runs[0] {
+				runs[0][i].limited = limited
+				runs[0][i].timeNormalized = opts.normalizeTime
 			}
-			byProcessSizes, err := profileSizes(byProcess)
-			if err != nil {
-				return fmt.Errorf("calculate split-by-process sizes: %w", err)
+			for _, es := range runs[0] {
+				if err := summary.WriteRow(file, es, runs[0][0].size, payloadCount); err != nil {
+					return fmt.Errorf("write summary row: %w", err)
+				}
+			}
+			// With --out=- stdout is taken by the summary.
+			if !toStdout && !opts.quiet {
+				printSizeSummary(a.Stdout, file, runs[0])
+			}
+			for _, es := range runs[0] {
+				if es.conformance != nil && !es.conformance.passed() {
+					fmt.Fprintf(a.Stderr, "warning: %s: %s output fails checks the input passes: %s\n", file, es.encoding, es.conformance)
+				}
+			}
+			if repeatWriter != nil {
+				if err := writeRepeatRows(repeatWriter, file, runs); err != nil {
+					return fmt.Errorf("write repeat rows: %w", err)
+				}
+				repeatWriter.Flush()
+			}
+			if memStatsWriter != nil {
+				for _, es := range runs[0] {
+					if err := memStatsWriter.Write([]string{
+						file,
+						es.encoding,
+						fmt.Sprintf("%d", es.alloc.bytes),
+						fmt.Sprintf("%d", es.alloc.objects),
+					}); err != nil {
+						return fmt.Errorf("write mem stats row: %w", err)
+					}
+				}
+				memStatsWriter.Flush()
+			}
+			if timingWriter != nil {
+				if err := writeTimingRows(timingWriter, file, runs[0]); err != nil {
+					return fmt.Errorf("write timing rows: %w", err)
+				}
+				timingWriter.Flush()
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if recovered != nil {
+			fmt.Fprintf(a.Stderr, "error: %s: skipped after panic: %v\n", file, recovered)
+			skipped = append(skipped, skippedFile{File: file, Error: fmt.Sprint(recovered)})
+		}
+	}
+	if opts.uniqueIDs && ids.writeDuplicates(a.Stderr) == 0 && !opts.quiet {
+		fmt.Fprintln(a.Stderr, "all profile IDs are unique")
+	}
+	if err := summary.Close(); err != nil {
+		return err
+	}
+	if repeatWriter != nil {
+		if err := repeatWriter.Error(); err != nil {
+			return fmt.Errorf("flush repeat csv: %w", err)
+		}
+	}
+	if memStatsWriter != nil {
+		if err := memStatsWriter.Error(); err != nil {
+			return fmt.Errorf("flush mem stats csv: %w", err)
+		}
+	}
+	if timingWriter != nil {
+		if err := timingWriter.Error(); err != nil {
+			return fmt.Errorf("flush timing csv: %w", err)
+		}
+	}
+	if valuesWriter != nil {
+		if err := valuesWriter.Error(); err != nil {
+			return fmt.Errorf("flush values csv: %w", err)
+		}
+	}
+	if formatsWriter != nil {
+		if err := formatsWriter.Error(); err != nil {
+			return fmt.Errorf("flush payload formats csv: %w", err)
+		}
+	}
+	if dictWriter != nil {
+		if err := dictWriter.Error(); err != nil {
+			return fmt.Errorf("flush dictionary csv: %w", err)
+		}
+	}
+	if len(skipped) > 0 {
+		if !toStdout {
+			m.Skipped = skipped
+			if err := writeManifest(outDir, m); err != nil {
+				return err
+			}
+		}
+		return fmt.Errorf("%d of %d files skipped after a panic", len(skipped), len(files))
+	}
+	return nil
+}
+
+// catchPanic calls fn and returns the value it panicked with, if any, or
+// the error it returned.
+func catchPanic(fn func() error) (recovered any, err error) {
+	defer func() {
+		recovered = recover()
+	}()
+	return nil, fn()
+}
+
+// encodingSize is the total size of all payloads of a file for one encoding.
+type encodingSize struct {
+	encoding string
+	// protoVersion is the name of the proto version the payloads were
+	// decoded and encoded with, see protoVersions.
+	protoVersion string
+	lossy        bool
+	size         profileSize
+	// sha256 is the hex encoded hash of the encoded payloads, in order.
+	sha256 string
+	// alloc is what the transform allocated over all payloads. It is only
+	// recorded with --mem-stats.
+	alloc allocStats
+	// timings are the compression times of the encoded payloads. They are
+	// only recorded with --timing-runs.
+	timings codecTimings
+	// bloat counts the unreachable dictionary entries of the payloads. It
+	// is only counted for payloads the strategies can transform.
+	bloat dictionaryBloat
+	// conformance is the profcheck result of the payloads, only for
+	// strategies with verify set.
+	conformance *conformance
+	// limited is set if only the first payloads of the file were measured,
+	// see --limit.
+	limited bool
+	// timeNormalized is set if the timestamps were rebased to 0 before
+	// measuring, see --normalize-time.
+	timeNormalized bool
+}
+
+// allocStats is the heap allocation done by a strategy's transform.
+type allocStats struct {
+	bytes   uint64
+	objects uint64
+}
+
+// measureAlloc calls fn and returns the heap allocations it made. It reads
+// runtime.MemStats, which stops the world, so it is only used on request.
+func measureAlloc(fn func()) allocStats {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	fn()
+	runtime.ReadMemStats(&after)
+	return allocStats{
+		bytes:   after.TotalAlloc - before.TotalAlloc,
+		objects: after.Mallocs - before.Mallocs,
+	}
+}
+
+// strategy is an alternative encoding of a payload whose size is measured.
+type strategy struct {
+	name string
+	// base is the name of the strategy whose output is transformed, or ""
+	// for the input payload. Base strategies must come first in strategies.
+	base string
+	// lossy strategies drop information from the payload. Their sizes are
+	// for comparison only, the output is not equivalent to the input.
+	lossy bool
+	// verify strategies have their output checked by profcheck, with the
+	// result reported next to their sizes.
+	verify bool
+	// transform returns the encoded payload. It must not modify its input,
+	// and returns an error rather than panicking on malformed input.
+	transform func(*cprofiles.ExportProfilesServiceRequest) (*cprofiles.ExportProfilesServiceRequest, error)
+}
+
+// strategies are measured for every payload, in CSV row order.
+var strategies = []strategy{
+	{name: "baseline", transform: identity},
+	{name: "split-by-process", base: "baseline", transform: splitByProcess},
+	{name: "resource-attr-dict", base: "split-by-process", transform: useResourceAttrDict},
+	{name: "sort-resources", base: "split-by-process", transform: sortResources},
+	{name: "split-by-profile-id", base: "baseline", transform: splitByProfileID},
+	{name: "intern-attr-values", base: "baseline", transform: internAttrValues},
+	{name: "hoist-common-attrs", base: "baseline", transform: hoistCommonAttrs},
+	{name: "strip-timestamps", base: "baseline", lossy: true, transform: stripTimestamps},
+	{name: "canonicalize", base: "baseline", verify: true, transform: canonicalize},
+}
+
+// strategies returns the strategies to measure, which are the fixed
+// strategies followed by the ones configured by opts, without the ones
+// excluded by --exclude-strategy.
+func (o runOptions) strategies() []strategy {
+	return slices.DeleteFunc(o.configuredStrategies(), func(s strategy) bool {
+		return slices.Contains(o.excludeStrategies, s.name)
+	})
+}
+
+// checkExcludedStrategies verifies that the --exclude-strategy names are
+// strategies configured by opts, and that no measured strategy is based on an
+// excluded one. The baseline is the reference of all size changes, so it
+// can't be excluded.
+func checkExcludedStrategies(opts runOptions) error {
+	configured := opts.configuredStrategies()
+	for _, name := range opts.excludeStrategies {
+		if name == configured[0].name {
+			return fmt.Errorf("--exclude-strategy: can't exclude %s, the sizes are relative to it", name)
+		}
+		if !slices.ContainsFunc(configured, func(s strategy) bool { return s.name == name }) {
+			var names []string
+			for _, s := range configured[1:] {
+				names = append(names, s.name)
+			}
+			return fmt.Errorf("--exclude-strategy: unknown strategy %q, must be one of %s", name, strings.Join(names, ", "))
+		}
+	}
+	for _, s := range opts.strategies() {
+		if slices.Contains(opts.excludeStrategies, s.base) {
+			return fmt.Errorf("--exclude-strategy: %s is based on %s, exclude it too", s.name, s.base)
+		}
+	}
+	return nil
+}
+
+// configuredStrategies returns the fixed strategies followed by the ones
+// configured by opts.
+func (o runOptions) configuredStrategies() []strategy {
+	configured := slices.Clone(strategies)
+	if len(o.stripKeys) > 0 {
+		keys := map[string]bool{}
+		for _, key := range o.stripKeys {
+			keys[key] = true
+		}
+		configured = append(configured, strategy{
+			name:  "strip-attrs",
+			base:  "baseline",
+			lossy: true,
+			transform: func(data *cprofiles.ExportProfilesServiceRequest) (*cprofiles.ExportProfilesServiceRequest, error) {
+				return stripAttributes(data, keys)
+			},
+		})
+	}
+	if o.sampleRate > 0 {
+		configured = append(configured, strategy{
+			name:  "downsample",
+			base:  "baseline",
+			lossy: true,
+			transform: func(data *cprofiles.ExportProfilesServiceRequest) (*cprofiles.ExportProfilesServiceRequest, error) {
+				return downsample(data, o.sampleRate)
+			},
+		}, strategy{
+			name:      "downsample-compact",
+			base:      "downsample",
+			lossy:     true,
+			transform: compactUnreferenced,
+		})
+	}
+	return configured
+}
+
+// measureFile decodes data and measures the size of every encoding of its
+// payloads. It returns the sizes in CSV row order and the number of payloads.
+// dump is called with the output of every strategy for every payload.
+func (a *App) measureFile(file string, data []byte, opts runOptions, dump func(string, *cprofiles.ExportProfilesServiceRequest, encodedPayload) error) ([]encodingSize, int, error) {
+	baselinePayloads, err := unmarshalFormat(data, opts.framing)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unmarshal gh733 profile: %w", err)
+	}
+
+	strategies := opts.strategies()
+	stats := make([]profileSize, len(strategies))
+	allocs := make([]allocStats, len(strategies))
+	timings := make([]codecTimings, len(strategies))
+	bloat := make([]dictionaryBloat, len(strategies))
+	checks := make([]*conformance, len(strategies))
+	hashes := make([]gohash.Hash, len(strategies))
+	for i := range hashes {
+		hashes[i] = sha256.New()
+	}
+	// Empty profiles are often scrapes that should have been dropped, they
+	// cost dictionary and framing bytes without adding data.
+	if empty, total := emptyProfiles(baselinePayloads); empty > 0 {
+		fmt.Fprintf(a.Stderr, "warning: %s: %d of %d profiles have no samples\n", file, empty, total)
+	}
+	samples := opts.samples
+	for _, baseline := range baselinePayloads {
+		if samples > 1 {
+			switch size := estimateScaledSize(baseline, samples); {
+			case size > maxScaledSize:
+				return nil, 0, fmt.Errorf("%s: scaling samples by %d would produce a ~%d byte payload, exceeding the %d byte protobuf limit", file, samples, size, maxScaledSize)
+			case size > warnScaledSize:
+				fmt.Fprintf(a.Stderr, "warning: %s: scaling samples by %d produces a ~%d byte payload\n", file, samples, size)
 			}
-			stats.splitByProcess = stats.splitByProcess.Add(byProcessSizes)
+			scaleSamples(baseline, samples)
+		}
 
-			resourceAttrDict := useResourceAttrDict(byProcess)
-			if err := appendTextProfileToFile(outDir, baseFilename, "resource-attr-dict", resourceAttrDict); err != nil {
-				return fmt.Errorf("write resource-attr-dict profile: %w", err)
+		outputs := map[string]*cprofiles.ExportProfilesServiceRequest{}
+		for i, s := range strategies {
+			in := baseline
+			if s.base != "" {
+				in = outputs[s.base]
 			}
-			resourceAttrDictSizes, err := profileSizes(resourceAttrDict)
+			var out *cprofiles.ExportProfilesServiceRequest
+			var err error
+			if opts.memStats {
+				alloc := measureAlloc(func() { out, err = s.transform(in) })
+				allocs[i].bytes += alloc.bytes
+				allocs[i].objects += alloc.objects
+			} else {
+				out, err = s.transform(in)
+			}
+			if err != nil {
+				return nil, 0, fmt.Errorf("transform %s: %w", s.name, err)
+			}
+			outputs[s.name] = out
+
+			enc, err := encodePayload(out)
 			if err != nil {
-				return fmt.Errorf("calculate resource-attr-dict sizes: %w", err)
+				return nil, 0, fmt.Errorf("calculate %s sizes: %w", s.name, err)
+			}
+			if err := dump(s.name, out, enc); err != nil {
+				return nil, 0, fmt.Errorf("write %s profile: %w", s.name, err)
+			}
+			stats[i] = stats[i].Add(enc.size())
+			bloat[i].add(out)
+			if s.verify {
+				if checks[i] == nil {
+					checks[i] = &conformance{}
+				}
+				if err := checks[i].add(in, out); err != nil {
+					return nil, 0, fmt.Errorf("check %s output: %w", s.name, err)
+				}
+			}
+			if opts.timingRuns > 0 {
+				// Sizes are deterministic and measured once above, only
+				// the times vary between runs.
+				if err := timings[i].add(enc.uncompressed, opts.warmup, opts.timingRuns); err != nil {
+					return nil, 0, fmt.Errorf("time %s compression: %w", s.name, err)
+				}
 			}
-			stats.resourceAttrDict = stats.resourceAttrDict.Add(resourceAttrDictSizes)
+			hashes[i].Write(enc.uncompressed)
 		}
-		payloadCount := len(baselinePayloads)
-		writeRow(csvWriter, file, "baseline", payloadCount, stats.baseline)
-		writeRow(csvWriter, file, "split-by-process", payloadCount, stats.splitByProcess)
-		writeRow(csvWriter, file, "resource-attr-dict", payloadCount, stats.resourceAttrDict)
-		csvWriter.Flush()
 	}
-	csvWriter.Flush()
-	if err := csvWriter.Error(); err != nil {
-		return fmt.Errorf("flush csv: %w", err)
+	var sizes []encodingSize
+	for i, s := range strategies {
+		sizes = append(sizes, encodingSize{
+			encoding:     s.name,
+			protoVersion: benchProtoVersion,
+			lossy:        s.lossy,
+			size:         stats[i],
+			sha256:       hex.EncodeToString(hashes[i].Sum(nil)),
+			alloc:        allocs[i],
+			timings:      timings[i],
+			bloat:        bloat[i],
+			conformance:  checks[i],
+		})
+	}
+	return sizes, len(baselinePayloads), nil
+}
+
+// emptyProfiles returns the number of profiles in payloads that have no
+// samples, and the number of all profiles.
+func emptyProfiles(payloads []*cprofiles.ExportProfilesServiceRequest) (empty, total int) {
+	for _, payload := range payloads {
+		for _, rp := range payload.GetResourceProfiles() {
+			for _, sp := range rp.GetScopeProfiles() {
+				for _, p := range sp.GetProfiles() {
+					if len(p.GetSamples()) == 0 {
+						empty++
+					}
+					total++
+				}
+			}
+		}
+	}
+	return empty, total
+}
+
+// printSizeSummary writes the uncompressed and zstd sizes of every encoding
+// of file to out, with the change relative to the baseline, which is the
+// first encoding in sizes.
+func printSizeSummary(out io.Writer, file string, sizes []encodingSize) {
+	var notes []string
+	if sizes[0].limited {
+		notes = append(notes, "limited by --limit")
+	}
+	if sizes[0].timeNormalized {
+		notes = append(notes, "time normalized by --normalize-time, not real-world sizes")
+	}
+	if len(notes) > 0 {
+		fmt.Fprintf(out, "%s (%s):\n", file, strings.Join(notes, ", "))
+	} else {
+		fmt.Fprintf(out, "%s:\n", file)
+	}
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "  encoding\tuncompressed\t\tzstd\t\n")
+	baseline := sizes[0].size
+	for i, es := range sizes {
+		var uncompressedChange, zstdChange string
+		if i > 0 {
+			uncompressedChange = percentChange(baseline.uncompressed, es.size.uncompressed)
+			zstdChange = percentChange(baseline.zstd3, es.size.zstd3)
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n",
+			es.encoding,
+			humanBytes(es.size.uncompressed), uncompressedChange,
+			humanBytes(es.size.zstd3), zstdChange)
+	}
+	tw.Flush()
+	for _, es := range sizes {
+		if es.conformance != nil {
+			fmt.Fprintf(out, "  %s: %s\n", es.encoding, es.conformance)
+		}
+	}
+}
+
+// humanBytes formats n bytes with a binary unit, e.g. "1.5 MiB".
+func humanBytes(n int) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := unit, 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGT"[exp])
+}
+
+// percentChange formats the change from base to v, e.g. "-12.5%".
+func percentChange(base, v int) string {
+	if base == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%+.1f%%", 100*float64(v-base)/float64(base))
+}
+
+// readInput reads and decompresses the input file. Unless toStdout is set,
+// the file is copied to outDir as is.
+func readInput(file, outDir string, toStdout bool) ([]byte, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+
+	// Copy input file to output directory
+	if !toStdout {
+		copyPath := filepath.Join(outDir, filepath.Base(file))
+		if err := os.WriteFile(copyPath, data, 0644); err != nil {
+			return nil, fmt.Errorf("copy input file to %q: %w", copyPath, err)
+		}
+	}
+
+	data, err = decompressInput(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return data, nil
+}
+
+// writePayloads writes payloads to path. A single payload is written as
+// plain protobuf, several in the length-prefixed format that unmarshalPayloads
+// reads.
+func writePayloads(path string, payloads []*cprofiles.ExportProfilesServiceRequest) error {
+	out, err := marshalPayloads(payloads, len(payloads) > 1)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		return fmt.Errorf("write %q: %w", path, err)
+	}
+	return nil
+}
+
+// marshalPayloads encodes payloads, each prefixed with its length if
+// lengthPrefixed is set and concatenated otherwise.
+func marshalPayloads(payloads []*cprofiles.ExportProfilesServiceRequest, lengthPrefixed bool) ([]byte, error) {
+	var out []byte
+	for _, payload := range payloads {
+		data, err := marshalOptions.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("marshal payload: %w", err)
+		}
+		if lengthPrefixed {
+			out = binary.BigEndian.AppendUint32(out, uint32(len(data)))
+		}
+		out = append(out, data...)
+	}
+	return out, nil
+}
+
+// writeRepeatRows writes the min, mean and max of every size column across
+// runs, which are the results of repeated measureFile calls for file.
+func writeRepeatRows(csvWriter *csv.Writer, file string, runs [][]encodingSize) error {
+	for i, es := range runs[0] {
+		for col, name := range sizeColumns {
+			minVal, maxVal, sum := math.MaxInt, 0, 0
+			for _, run := range runs {
+				v := run[i].size.values()[col]
+				minVal, maxVal, sum = min(minVal, v), max(maxVal, v), sum+v
+			}
+			if err := csvWriter.Write([]string{
+				file,
+				es.encoding,
+				name,
+				fmt.Sprintf("%d", len(runs)),
+				fmt.Sprintf("%d", minVal),
+				fmt.Sprintf("%.1f", float64(sum)/float64(len(runs))),
+				fmt.Sprintf("%d", maxVal),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// manifest records how a benchmark run was invoked. It is written to
+// manifest.json in the output directory.
+type manifest struct {
+	Files   []string `json:"files"`
+	Samples int      `json:"samples"`
+	Repeat  int      `json:"repeat"`
+	Merge   bool     `json:"merge,omitempty"`
+	// NormalizeTime is set if the sizes are of --normalize-time payloads.
+	NormalizeTime bool `json:"normalize_time,omitempty"`
+	// ProtoVersion is the proto version the input was decoded with.
+	ProtoVersion string `json:"proto_version"`
+	// Label is the --label of the run.
+	Label string `json:"label,omitempty"`
+	// Skipped are the files that were skipped because processing them
+	// panicked. Their results may be missing or incomplete.
+	Skipped []skippedFile `json:"skipped,omitempty"`
+}
+
+// skippedFile is a file that was skipped during a run.
+type skippedFile struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+func writeManifest(outDir string, m manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal manifest: %w", err)
+	}
+	manifestPath := filepath.Join(outDir, "manifest.json")
+	if err := os.WriteFile(manifestPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("write manifest %q: %w", manifestPath, err)
+	}
+	return nil
+}
+
+// runVersionPair calls fn with the two proto versions named in versions, the
+// value of --flag, on the decompressed contents of every file, which are in
+// the given framing.
+func (a *App) runVersionPair(flag string, versions []string, framing string, files []string, fn func(out io.Writer, file string, data []byte, framing string, a, b protoVersion) error) error {
+	if len(versions) != 2 {
+		return fmt.Errorf("--%s needs exactly two versions, got %d", flag, len(versions))
+	}
+	va, err := lookupProtoVersion(versions[0])
+	if err != nil {
+		return err
+	}
+	vb, err := lookupProtoVersion(versions[1])
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("read file: %w", err)
+		}
+		data, err = decompressInput(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		if err := fn(a.Stdout, file, data, framing, va, vb); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
 	}
 	return nil
 }
@@ -176,60 +1347,267 @@ func (a *App) run(_ context.Context, samples int, outDir string, files ...string
 type profileSize struct {
 	uncompressed int
 	gzip6        int
+	zstd3        int
+}
+
+// sizeColumns are the CSV column names of the values returned by
+// profileSize.values.
+var sizeColumns = []string{"uncompressed_bytes", "gzip_6_bytes", "zstd_3_bytes"}
+
+func (p profileSize) values() []int {
+	return []int{p.uncompressed, p.gzip6, p.zstd3}
+}
+
+// changeColumns are the CSV column names of the values returned by
+// sizeChanges, the absolute and the percentage change of each size column.
+var changeColumns = func() []string {
+	var columns []string
+	for _, col := range sizeColumns {
+		columns = append(columns, col+"_delta", col+"_change_pct")
+	}
+	return columns
+}()
+
+// savedColumns are the CSV column names of the values returned by
+// profileSize.saved.
+var savedColumns = []string{"gzip_saved", "zstd_saved"}
+
+// saved returns the bytes each codec saves compared to the uncompressed
+// size, in savedColumns order.
+func (p profileSize) saved() []int {
+	return []int{p.uncompressed - p.gzip6, p.uncompressed - p.zstd3}
+}
+
+// sizeChange is the change of a size column relative to the baseline.
+type sizeChange struct {
+	delta int
+	// pct is the change in percent of the baseline size, NaN if the
+	// baseline size is zero.
+	pct float64
+}
+
+// sizeChanges returns the change of every value of size relative to base, in
+// sizeColumns order.
+func sizeChanges(base, size profileSize) []sizeChange {
+	baseValues, values := base.values(), size.values()
+	changes := make([]sizeChange, len(values))
+	for i, v := range values {
+		changes[i] = sizeChange{delta: v - baseValues[i], pct: math.NaN()}
+		if baseValues[i] != 0 {
+			changes[i].pct = 100 * float64(changes[i].delta) / float64(baseValues[i])
+		}
+	}
+	return changes
 }
 
 func (p profileSize) Add(other profileSize) profileSize {
 	return profileSize{
 		uncompressed: p.uncompressed + other.uncompressed,
 		gzip6:        p.gzip6 + other.gzip6,
+		zstd3:        p.zstd3 + other.zstd3,
 	}
 }
 
-func profileSizes(profile *cprofiles.ExportProfilesServiceRequest) (profileSize, error) {
-	uncompressed, err := proto.Marshal(profile)
+// marshalOptions are used to encode every measured payload. Deterministic
+// marshaling makes the encoded bytes, and thus their sizes and hashes,
+// reproducible across runs.
+var marshalOptions = proto.MarshalOptions{Deterministic: true}
+
+// zstdEncoder compresses payloads with zstd's default level 3. EncodeAll is
+// safe for concurrent use.
+var zstdEncoder = func() *zstd.Encoder {
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
 	if err != nil {
-		return profileSize{}, fmt.Errorf("marshal profile: %w", err)
+		panic(fmt.Sprintf("create zstd encoder: %v", err))
 	}
+	return enc
+}()
+
+// profileSizes returns the sizes of profile and its encoded bytes.
+func profileSizes(profile proto.Message) (profileSize, []byte, error) {
+	enc, err := encodePayload(profile)
+	if err != nil {
+		return profileSize{}, nil, err
+	}
+	return enc.size(), enc.uncompressed, nil
+}
 
+// encodedPayload is a payload in each of the measured encodings.
+type encodedPayload struct {
+	uncompressed []byte
+	gzip6        []byte
+	zstd3        []byte
+}
+
+func (e encodedPayload) size() profileSize {
+	return profileSize{
+		uncompressed: len(e.uncompressed),
+		gzip6:        len(e.gzip6),
+		zstd3:        len(e.zstd3),
+	}
+}
+
+// encodePayload marshals profile and compresses the result. The codecs run
+// concurrently, they only read the shared uncompressed bytes.
+func encodePayload(profile proto.Message) (encodedPayload, error) {
+	uncompressed, err := marshalOptions.Marshal(profile)
+	if err != nil {
+		return encodedPayload{}, fmt.Errorf("marshal profile: %w", err)
+	}
+
+	enc := encodedPayload{uncompressed: uncompressed}
+	codecs := []func() error{
+		func() (err error) {
+			enc.gzip6, err = gzipCompress(uncompressed)
+			return err
+		},
+		func() error {
+			enc.zstd3 = zstdEncoder.EncodeAll(uncompressed, nil)
+			return nil
+		},
+	}
+	errs := make([]error, len(codecs))
+	var wg sync.WaitGroup
+	for i, compress := range codecs {
+		wg.Go(func() { errs[i] = compress() })
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return encodedPayload{}, err
+		}
+	}
+	return enc, nil
+}
+
+// gzipCompress compresses data with gzip's default level 6.
+func gzipCompress(data []byte) ([]byte, error) {
 	var compressed bytes.Buffer
 	gw, err := gzip.NewWriterLevel(&compressed, gzip.DefaultCompression)
 	if err != nil {
-		return profileSize{}, fmt.Errorf("create gzip writer: %w", err)
+		return nil, fmt.Errorf("create gzip writer: %w", err)
 	}
-	if _, err := gw.Write(uncompressed); err != nil {
-		return profileSize{}, fmt.Errorf("write compressed data: %w", err)
+	if _, err := gw.Write(data); err != nil {
+		return nil, fmt.Errorf("write compressed data: %w", err)
 	}
 	if err := gw.Close(); err != nil {
-		return profileSize{}, fmt.Errorf("close gzip writer: %w", err)
+		return nil, fmt.Errorf("close gzip writer: %w", err)
 	}
+	return compressed.Bytes(), nil
+}
 
-	return profileSize{
-		uncompressed: len(uncompressed),
-		gzip6:        compressed.Len(),
-	}, nil
-}
-
-func writeRow(csvWriter *csv.Writer, file, encoding string, payloads int, sizes profileSize) error {
-	return csvWriter.Write([]string{
-		file,
-		encoding,
-		fmt.Sprintf("%d", payloads),
-		fmt.Sprintf("%d", sizes.uncompressed),
-		fmt.Sprintf("%d", sizes.gzip6),
-	})
+func writeRow(csvWriter *csv.Writer, file string, es encodingSize, baseline profileSize, payloads int) error {
+	row := []string{file, es.encoding, es.protoVersion, fmt.Sprintf("%t", es.lossy), fmt.Sprintf("%d", payloads)}
+	for _, v := range es.size.values() {
+		row = append(row, fmt.Sprintf("%d", v))
+	}
+	row = append(row, es.sha256)
+	for _, c := range sizeChanges(baseline, es.size) {
+		pct := ""
+		if !math.IsNaN(c.pct) {
+			pct = fmt.Sprintf("%.2f", c.pct)
+		}
+		row = append(row, fmt.Sprintf("%d", c.delta), pct)
+	}
+	for _, v := range es.size.saved() {
+		row = append(row, fmt.Sprintf("%d", v))
+	}
+	bloat := ""
+	if score := es.bloat.score(); !math.IsNaN(score) {
+		bloat = fmt.Sprintf("%.4f", score)
+	}
+	row = append(row, bloat, fmt.Sprintf("%t", es.limited), fmt.Sprintf("%t", es.timeNormalized), es.conformance.result())
+	return csvWriter.Write(row)
+}
+
+// expandGlobs expands the glob patterns in files, for shells that don't or
+// when the patterns are quoted. Arguments without glob meta characters are
+// returned as is.
+func expandGlobs(files []string) ([]string, error) {
+	var expanded []string
+	for _, file := range files {
+		if !strings.ContainsAny(file, "*?[") {
+			expanded = append(expanded, file)
+			continue
+		}
+		matches, err := filepath.Glob(file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %q: %w", file, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("glob pattern %q matches no files", file)
+		}
+		expanded = append(expanded, matches...)
+	}
+	return expanded, nil
+}
+
+// gzipMagic are the first bytes of a gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decompressInput returns data, decompressed if it is gzip compressed.
+func decompressInput(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return data, nil
+	}
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("create gzip reader: %w", err)
+	}
+	defer gr.Close()
+	decompressed, err := io.ReadAll(gr)
+	if err != nil {
+		return nil, fmt.Errorf("decompress gzip input: %w", err)
+	}
+	return decompressed, nil
 }
 
 func unmarshalOTLP(data []byte) ([]*cprofiles.ExportProfilesServiceRequest, error) {
+	payloads, err := unmarshalPayloads(data, func() proto.Message { return &cprofiles.ExportProfilesServiceRequest{} })
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]*cprofiles.ExportProfilesServiceRequest, len(payloads))
+	for i, payload := range payloads {
+		msgs[i] = payload.(*cprofiles.ExportProfilesServiceRequest)
+	}
+	return msgs, nil
+}
+
+// unmarshalPayloads decodes data into messages created by newMsg, which allows
+// decoding the same data with different proto versions. The format is guessed:
+// a single message, the length-prefixed format or the gRPC framing, in that
+// order.
+func unmarshalPayloads(data []byte, newMsg func() proto.Message) ([]proto.Message, error) {
 	// First try direct unmarshaling
-	var msg cprofiles.ExportProfilesServiceRequest
-	if err := proto.Unmarshal(data, &msg); err == nil {
-		return []*cprofiles.ExportProfilesServiceRequest{&msg}, nil
+	msg := newMsg()
+	if err := proto.Unmarshal(data, msg); err == nil {
+		return []proto.Message{msg}, nil
 	}
 
 	// If direct unmarshaling fails, try length-prefixed format
-	// The first 4 bytes contain the size as a big-endian uint32.
-	// See https://github.com/open-telemetry/opentelemetry-collector-contrib/blob/main/exporter/fileexporter/README.md#file-format
-	var msgs []*cprofiles.ExportProfilesServiceRequest
+	msgs, err := unmarshalLengthPrefixed(data, newMsg)
+	if err == nil {
+		return msgs, nil
+	}
+
+	// Then the gRPC framing, whose first byte is the compressed flag 0 or 1.
+	// As neither is a valid protobuf tag, it never decodes as a single
+	// message.
+	if len(data) > 0 && data[0] <= 1 {
+		if msgs, grpcErr := unmarshalGRPC(data, newMsg); grpcErr == nil {
+			return msgs, nil
+		}
+	}
+	return nil, err
+}
+
+// unmarshalLengthPrefixed decodes data in the length-prefixed format of the
+// fileexporter, where the first 4 bytes of each message contain its size as a
+// big-endian uint32.
+// See https://github.com/open-telemetry/opentelemetry-collector-contrib/blob/main/exporter/fileexporter/README.md#file-format
+func unmarshalLengthPrefixed(data []byte, newMsg func() proto.Message) ([]proto.Message, error) {
+	var msgs []proto.Message
 	for len(data) > 0 {
 		if len(data) < 4 {
 			return nil, fmt.Errorf("data too short for length-prefixed format")
@@ -241,16 +1619,46 @@ func unmarshalOTLP(data []byte) ([]*cprofiles.ExportProfilesServiceRequest, erro
 		}
 
 		data = data[4:]
-		var msg cprofiles.ExportProfilesServiceRequest
-		if err := proto.Unmarshal(data[:size], &msg); err != nil {
+		msg := newMsg()
+		if err := proto.Unmarshal(data[:size], msg); err != nil {
 			return nil, fmt.Errorf("unmarshal length-prefixed message: %w", err)
 		}
-		msgs = append(msgs, &msg)
+		msgs = append(msgs, msg)
 		data = data[size:]
 	}
 	return msgs, nil
 }
 
+const (
+	// maxScaledSize is the largest message protobuf can marshal.
+	maxScaledSize = math.MaxInt32
+	// warnScaledSize is the payload size above which scaling samples is
+	// likely to be slow and memory hungry.
+	warnScaledSize = 512 << 20
+)
+
+// estimateScaledSize returns the approximate marshaled size of data after
+// scaleSamples(data, factor) without actually scaling it. The estimate ignores
+// the growth of the length prefixes of the enclosing messages.
+func estimateScaledSize(data *cprofiles.ExportProfilesServiceRequest, factor int) int {
+	size := proto.Size(data)
+	samplesField := (&profiles.Profile{}).ProtoReflect().Descriptor().Fields().ByName("samples").Number()
+	samplesSize := 0
+	for _, rp := range data.ResourceProfiles {
+		for _, sp := range rp.ScopeProfiles {
+			for _, p := range sp.Profiles {
+				for _, s := range p.Samples {
+					samplesSize += protowire.SizeTag(samplesField) + protowire.SizeBytes(proto.Size(s))
+				}
+			}
+		}
+	}
+	if samplesSize > 0 && factor-1 > (math.MaxInt-size)/samplesSize {
+		return math.MaxInt
+	}
+	return size + (factor-1)*samplesSize
+}
+
 func scaleSamples(data *cprofiles.ExportProfilesServiceRequest, factor int) {
 	for _, rp := range data.ResourceProfiles {
 		for _, sp := range rp.ScopeProfiles {
@@ -266,19 +1674,82 @@ func scaleSamples(data *cprofiles.ExportProfilesServiceRequest, factor int) {
 	}
 }
 
+func identity(data *cprofiles.ExportProfilesServiceRequest) (*cprofiles.ExportProfilesServiceRequest, error) {
+	return data, nil
+}
+
+// stripTimestamps returns a copy of data without sample timestamps, turning
+// it into an aggregated profile. Samples that only differed by their
+// timestamps are merged into one sample by summing their values. This is
+// lossy and only meant to measure how much the timestamps cost.
+func stripTimestamps(data *cprofiles.ExportProfilesServiceRequest) (*cprofiles.ExportProfilesServiceRequest, error) {
+	newProfile := proto.Clone(data).(*cprofiles.ExportProfilesServiceRequest)
+	for _, rp := range newProfile.ResourceProfiles {
+		for _, sp := range rp.ScopeProfiles {
+			for _, p := range sp.Profiles {
+				p.Samples = aggregateSamples(p.Samples)
+			}
+		}
+	}
+	return newProfile, nil
+}
+
+// aggregateSamples drops the timestamps of samples and merges samples with the
+// same stack, attributes and link. Each merged sample has a single value, the
+// sum of its values, or the number of timestamps for timestamp-only samples.
+func aggregateSamples(samples []*profiles.Sample) []*profiles.Sample {
+	var aggregated []*profiles.Sample
+	index := map[string]*profiles.Sample{}
+	for _, s := range samples {
+		var value int64
+		for _, v := range s.Values {
+			value += v
+		}
+		if len(s.Values) == 0 {
+			value = int64(len(s.TimestampsUnixNano))
+		}
+
+		// Attribute indices are a set, so the same attributes in another
+		// order are merged too.
+		attrs := slices.Clone(s.AttributeIndices)
+		slices.Sort(attrs)
+		key := fmt.Sprint(s.StackIndex, attrs, s.LinkIndex)
+		if prev, ok := index[key]; ok {
+			prev.Values[0] += value
+			continue
+		}
+		newS := &profiles.Sample{
+			StackIndex:       s.StackIndex,
+			Values:           []int64{value},
+			AttributeIndices: s.AttributeIndices,
+			LinkIndex:        s.LinkIndex,
+		}
+		index[key] = newS
+		aggregated = append(aggregated, newS)
+	}
+	return aggregated
+}
+
+// errMissingDictionary is returned by transforms that need to look up or add
+// dictionary entries.
+var errMissingDictionary = errors.New("payload has no dictionary")
+
 var processAttributes = map[string]struct{}{
 	"process.pid":             {},
 	"process.executable.name": {},
 	"process.executable.path": {},
 }
 
-func splitByProcess(data *cprofiles.ExportProfilesServiceRequest) *cprofiles.ExportProfilesServiceRequest {
+func splitByProcess(data *cprofiles.ExportProfilesServiceRequest) (*cprofiles.ExportProfilesServiceRequest, error) {
+	if data.Dictionary == nil {
+		return nil, errMissingDictionary
+	}
 	newProfile := &cprofiles.ExportProfilesServiceRequest{
 		Dictionary: proto.Clone(data.Dictionary).(*profiles.ProfilesDictionary),
 	}
 	resourceProfilesIdx := map[string]*profiles.ResourceProfiles{}
 	for _, rp := range data.ResourceProfiles {
-		resourceAttrsStr := hash(keyValuesString(rp.Resource.Attributes, data.Dictionary))
+		resourceAttrsStr := hash(keyValuesString(rp.GetResource().GetAttributes(), data.Dictionary))
 		for si, sp := range rp.ScopeProfiles {
 			for pi, p := range sp.Profiles {
 				for _, s := range p.Samples {
@@ -291,8 +1762,11 @@ func splitByProcess(data *cprofiles.ExportProfilesServiceRequest) *cprofiles.Exp
 					}
 					processAttrs := []*profiles.KeyValueAndUnit{}
 					for _, ai := range s.AttributeIndices {
-						attr := data.Dictionary.AttributeTable[ai]
-						key := data.Dictionary.StringTable[attr.KeyStrindex]
+						attr := at(data.Dictionary.AttributeTable, ai)
+						if attr == nil {
+							return nil, fmt.Errorf("attribute index %d is out of range", ai)
+						}
+						key := dictString(data.Dictionary, attr.KeyStrindex)
 						if _, ok := processAttributes[key]; ok {
 							processAttrs = append(processAttrs, attr)
 						} else {
@@ -303,14 +1777,14 @@ func splitByProcess(data *cprofiles.ExportProfilesServiceRequest) *cprofiles.Exp
 					combinedHash := hash(resourceAttrsStr, processAttrsStr)
 					newRp, ok := resourceProfilesIdx[string(combinedHash)]
 					if !ok {
-						newRpAttrs := make([]*common.KeyValue, len(rp.Resource.Attributes))
-						copy(newRpAttrs, rp.Resource.Attributes)
+						newRpAttrs := make([]*common.KeyValue, len(rp.GetResource().GetAttributes()))
+						copy(newRpAttrs, rp.GetResource().GetAttributes())
 						for _, pa := range processAttrs {
 							if pa.UnitStrindex != 0 {
-								panic("process attribute with unit is not supported")
+								return nil, errors.New("process attribute with unit is not supported")
 							}
 							newRpAttrs = append(newRpAttrs, &common.KeyValue{
-								Key:   data.Dictionary.StringTable[pa.KeyStrindex],
+								Key:   dictString(data.Dictionary, pa.KeyStrindex),
 								Value: pa.Value,
 							})
 						}
@@ -318,8 +1792,8 @@ func splitByProcess(data *cprofiles.ExportProfilesServiceRequest) *cprofiles.Exp
 						newRp = &profiles.ResourceProfiles{
 							Resource: &resource.Resource{
 								Attributes:             newRpAttrs,
-								DroppedAttributesCount: rp.Resource.DroppedAttributesCount,
-								EntityRefs:             rp.Resource.EntityRefs,
+								DroppedAttributesCount: rp.GetResource().GetDroppedAttributesCount(),
+								EntityRefs:             rp.GetResource().GetEntityRefs(),
 							},
 							ScopeProfiles: make([]*profiles.ScopeProfiles, len(rp.ScopeProfiles)),
 							SchemaUrl:     rp.SchemaUrl,
@@ -339,7 +1813,7 @@ func splitByProcess(data *cprofiles.ExportProfilesServiceRequest) *cprofiles.Exp
 					newP := newSp.Profiles[pi]
 					if newP == nil {
 						if p.OriginalPayload != nil {
-							panic("splitting a profile with an original payload is not supported")
+							return nil, errors.New("splitting a profile with an original payload is not supported")
 						}
 						newP = &profiles.Profile{
 							SampleType:             p.SampleType,
@@ -361,7 +1835,34 @@ func splitByProcess(data *cprofiles.ExportProfilesServiceRequest) *cprofiles.Exp
 			}
 		}
 	}
-	return newProfile
+	return newProfile, nil
+}
+
+// sortResources returns a copy of data with the resource profiles sorted by
+// their resource attributes, which places resources that share attributes
+// next to each other. Unlike splitByProcess it sorts by the attribute string
+// itself rather than its hash, which would scatter similar resources. The
+// resource profiles themselves are shared with data.
+func sortResources(data *cprofiles.ExportProfilesServiceRequest) (*cprofiles.ExportProfilesServiceRequest, error) {
+	type keyed struct {
+		key string
+		rp  *profiles.ResourceProfiles
+	}
+	sorted := make([]keyed, len(data.ResourceProfiles))
+	for i, rp := range data.ResourceProfiles {
+		sorted[i] = keyed{keyValuesString(rp.GetResource().GetAttributes(), data.Dictionary), rp}
+	}
+	slices.SortStableFunc(sorted, func(a, b keyed) int {
+		return strings.Compare(a.key, b.key)
+	})
+	newProfile := &cprofiles.ExportProfilesServiceRequest{
+		Dictionary:       data.Dictionary,
+		ResourceProfiles: make([]*profiles.ResourceProfiles, len(sorted)),
+	}
+	for i, k := range sorted {
+		newProfile.ResourceProfiles[i] = k.rp
+	}
+	return newProfile, nil
 }
 
 func hash(values ...string) string {
@@ -376,45 +1877,52 @@ func keyValueAndUnitsString(attrs []*profiles.KeyValueAndUnit, dict *profiles.Pr
 	attrsCopy := make([]*profiles.KeyValueAndUnit, len(attrs))
 	copy(attrsCopy, attrs)
 	slices.SortFunc(attrsCopy, func(a, b *profiles.KeyValueAndUnit) int {
-		return strings.Compare(dict.StringTable[a.KeyStrindex], dict.StringTable[b.KeyStrindex])
+		return strings.Compare(dictString(dict, a.KeyStrindex), dictString(dict, b.KeyStrindex))
 	})
 	var parts []string
 	for _, attr := range attrsCopy {
 		unit := ""
 		if attr.UnitStrindex != 0 {
-			unit = fmt.Sprintf(" &%s", dict.StringTable[attr.UnitStrindex])
+			unit = fmt.Sprintf(" &%s", dictString(dict, attr.UnitStrindex))
 		}
-		parts = append(parts, fmt.Sprintf("&%s=%s%s", dict.StringTable[attr.KeyStrindex], anyValueString(attr.Value, dict), unit))
+		parts = append(parts, fmt.Sprintf("&%s=%s%s", dictString(dict, attr.KeyStrindex), anyValueString(attr.Value, dict), unit))
 	}
 	return strings.Join(parts, ", ")
 }
 
-func appendTextProfileToFile(outDir, baseFilename, suffix string, data *cprofiles.ExportProfilesServiceRequest) error {
+func appendTextProfileToFile(outDir, baseFilename, suffix string, data *cprofiles.ExportProfilesServiceRequest, maxSamples int) error {
 	outPath := filepath.Join(outDir, baseFilename+"."+suffix+".txt")
 	f, err := os.OpenFile(outPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return fmt.Errorf("open file %q: %w", outPath, err)
 	}
 	defer f.Close()
-	printProfile(f, data)
+	printProfile(f, data, maxSamples)
 	return nil
 }
 
-func printProfile(out io.Writer, data *cprofiles.ExportProfilesServiceRequest) {
+// printProfile writes a text representation of data to out, with at most
+// maxSamples samples per profile.
+func printProfile(out io.Writer, data *cprofiles.ExportProfilesServiceRequest, maxSamples int) {
 	for _, rp := range data.ResourceProfiles {
-		fmt.Fprintf(out, "Resource: %s\n", keyValuesString(rp.Resource.Attributes, data.Dictionary))
+		fmt.Fprintf(out, "Resource: %s\n", keyValuesString(rp.GetResource().GetAttributes(), data.Dictionary))
 		for _, sp := range rp.ScopeProfiles {
-			fmt.Fprintf(out, "  Scope: %s: %s\n", sp.Scope.Name, keyValuesString(sp.Scope.Attributes, data.Dictionary))
+			fmt.Fprintf(out, "  Scope: %s: %s\n", sp.GetScope().GetName(), keyValuesString(sp.GetScope().GetAttributes(), data.Dictionary))
 			for _, p := range sp.Profiles {
-				typeStr, unitStr := data.Dictionary.StringTable[p.SampleType.TypeStrindex], data.Dictionary.StringTable[p.SampleType.UnitStrindex]
+				typeStr, unitStr := dictString(data.Dictionary, p.GetSampleType().GetTypeStrindex()), dictString(data.Dictionary, p.GetSampleType().GetUnitStrindex())
 				end := time.Unix(int64(p.TimeUnixNano/1e9), int64(p.TimeUnixNano%1e9))
 				start := end.Add(-time.Duration(p.DurationNano))
 				fmt.Fprintf(out, "    Profile: %s=%s (%s - %s)\n", typeStr, unitStr, start.String(), end.String())
-				for _, s := range p.Samples {
+				for i, s := range p.Samples {
+					if i == maxSamples {
+						fmt.Fprintf(out, "      ... (%d more samples omitted)\n", len(p.Samples)-maxSamples)
+						break
+					}
 					attrs := []*profiles.KeyValueAndUnit{}
 					for _, ai := range s.AttributeIndices {
-						attr := data.Dictionary.AttributeTable[ai]
-						attrs = append(attrs, attr)
+						if attr := at(data.Dictionary.GetAttributeTable(), ai); attr != nil {
+							attrs = append(attrs, attr)
+						}
 					}
 					fmt.Fprintf(out, "      Sample: %s\n", keyValueAndUnitsString(attrs, data.Dictionary))
 				}
@@ -433,7 +1941,7 @@ func keyValuesString(attrs []*common.KeyValue, dict *profiles.ProfilesDictionary
 	for _, attr := range attrsCopy {
 		key := attr.Key
 		if attr.KeyRef != 0 {
-			key = "&" + dict.StringTable[attr.KeyRef]
+			key = "&" + dictString(dict, attr.KeyRef)
 		}
 		parts = append(parts, fmt.Sprintf("%s=%s", key, anyValueString(attr.Value, dict)))
 	}
@@ -441,12 +1949,11 @@ func keyValuesString(attrs []*common.KeyValue, dict *profiles.ProfilesDictionary
 }
 
 func anyValueString(av *common.AnyValue, dict *profiles.ProfilesDictionary) string {
-	switch av.Value.(type) {
+	switch av.GetValue().(type) {
 	case *common.AnyValue_StringValue:
 		return fmt.Sprintf("%q", av.GetStringValue())
 	case *common.AnyValue_StringRef:
-		str := dict.StringTable[av.GetStringRef()]
-		return fmt.Sprintf("&%q", str)
+		return fmt.Sprintf("&%q", dictString(dict, av.GetStringRef()))
 	case *common.AnyValue_IntValue:
 		return fmt.Sprintf("%d", av.GetIntValue())
 	default:
@@ -454,17 +1961,21 @@ func anyValueString(av *common.AnyValue, dict *profiles.ProfilesDictionary) stri
 	}
 }
 
-func useResourceAttrDict(data *cprofiles.ExportProfilesServiceRequest) *cprofiles.ExportProfilesServiceRequest {
+func useResourceAttrDict(data *cprofiles.ExportProfilesServiceRequest) (*cprofiles.ExportProfilesServiceRequest, error) {
+	if data.Dictionary == nil {
+		return nil, errMissingDictionary
+	}
 	newProfile := &cprofiles.ExportProfilesServiceRequest{
 		Dictionary: proto.Clone(data.Dictionary).(*profiles.ProfilesDictionary),
 	}
+	strs := newStringIndex(newProfile.Dictionary)
 
 	for _, rp := range data.ResourceProfiles {
 		newRp := &profiles.ResourceProfiles{
 			Resource: &resource.Resource{
-				Attributes:             dictifyKeyValues(rp.Resource.Attributes, newProfile.Dictionary),
-				DroppedAttributesCount: rp.Resource.DroppedAttributesCount,
-				EntityRefs:             rp.Resource.EntityRefs,
+				Attributes:             dictifyKeyValues(rp.GetResource().GetAttributes(), strs),
+				DroppedAttributesCount: rp.GetResource().GetDroppedAttributesCount(),
+				EntityRefs:             rp.GetResource().GetEntityRefs(),
 			},
 			ScopeProfiles: rp.ScopeProfiles,
 			SchemaUrl:     rp.SchemaUrl,
@@ -472,10 +1983,29 @@ func useResourceAttrDict(data *cprofiles.ExportProfilesServiceRequest) *cprofile
 		newProfile.ResourceProfiles = append(newProfile.ResourceProfiles, newRp)
 	}
 
-	return newProfile
+	return newProfile, nil
 }
 
-func dictifyKeyValues(attrs []*common.KeyValue, dict *profiles.ProfilesDictionary) []*common.KeyValue {
+// internAttrValues returns a copy of data whose attribute table references
+// string values through the string table instead of inlining them.
+func internAttrValues(data *cprofiles.ExportProfilesServiceRequest) (*cprofiles.ExportProfilesServiceRequest, error) {
+	if data.Dictionary == nil {
+		return nil, errMissingDictionary
+	}
+	newProfile := &cprofiles.ExportProfilesServiceRequest{
+		Dictionary:       proto.Clone(data.Dictionary).(*profiles.ProfilesDictionary),
+		ResourceProfiles: data.ResourceProfiles,
+	}
+	strs := newStringIndex(newProfile.Dictionary)
+	for _, attr := range newProfile.Dictionary.AttributeTable {
+		if attr.Value != nil {
+			attr.Value = dictAnyValue(attr.Value, strs)
+		}
+	}
+	return newProfile, nil
+}
+
+func dictifyKeyValues(attrs []*common.KeyValue, strs *stringIndex) []*common.KeyValue {
 	newAttrs := make([]*common.KeyValue, 0, len(attrs))
 	for _, attr := range attrs {
 		if attr.KeyRef != 0 {
@@ -483,9 +2013,9 @@ func dictifyKeyValues(attrs []*common.KeyValue, dict *profiles.ProfilesDictionar
 			continue
 		}
 
-		value := dictAnyValue(attr.Value, dict)
+		value := dictAnyValue(attr.Value, strs)
 		newAttr := &common.KeyValue{
-			KeyRef: dictStrIndex(attr.Key, dict),
+			KeyRef: strs.index(attr.Key),
 			Value:  value,
 		}
 		newAttrs = append(newAttrs, newAttr)
@@ -493,25 +2023,55 @@ func dictifyKeyValues(attrs []*common.KeyValue, dict *profiles.ProfilesDictionar
 	return newAttrs
 }
 
-func dictAnyValue(av *common.AnyValue, dict *profiles.ProfilesDictionary) *common.AnyValue {
-	if _, ok := av.Value.(*common.AnyValue_StringValue); ok {
+func dictAnyValue(av *common.AnyValue, strs *stringIndex) *common.AnyValue {
+	if _, ok := av.GetValue().(*common.AnyValue_StringValue); ok {
 		return &common.AnyValue{
 			Value: &common.AnyValue_StringRef{
-				StringRef: dictStrIndex(av.GetStringValue(), dict),
+				StringRef: strs.index(av.GetStringValue()),
 			},
 		}
 	}
 	return av
 }
 
-// dictStrIndex returns the index of the string in the dictionary. If the string
-// is not found, it is added to the dictionary.
-func dictStrIndex(str string, dict *profiles.ProfilesDictionary) int32 {
+// dictString returns the string at idx in the string table of dict, or a
+// placeholder if idx is out of range, so that malformed payloads can still be
+// printed and hashed.
+func dictString(dict *profiles.ProfilesDictionary, idx int32) string {
+	if idx < 0 || int(idx) >= len(dict.GetStringTable()) {
+		return fmt.Sprintf("<invalid string index %d>", idx)
+	}
+	return dict.StringTable[idx]
+}
+
+// stringIndex looks up strings in the string table of a dictionary by value,
+// like dictMerger.str, so that interning every attribute costs a map lookup
+// rather than a scan of the table.
+type stringIndex struct {
+	dict    *profiles.ProfilesDictionary
+	indices map[string]int32
+}
+
+// newStringIndex indexes the string table of dict. Of duplicate strings, the
+// first one is used.
+func newStringIndex(dict *profiles.ProfilesDictionary) *stringIndex {
+	indices := make(map[string]int32, len(dict.StringTable))
 	for i, s := range dict.StringTable {
-		if s == str {
-			return int32(i)
+		if _, ok := indices[s]; !ok {
+			indices[s] = int32(i)
 		}
 	}
-	dict.StringTable = append(dict.StringTable, str)
-	return int32(len(dict.StringTable) - 1)
+	return &stringIndex{dict: dict, indices: indices}
+}
+
+// index returns the index of str in the string table. If the string is not
+// found, it is added to the table.
+func (s *stringIndex) index(str string) int32 {
+	if idx, ok := s.indices[str]; ok {
+		return idx
+	}
+	idx := int32(len(s.dict.StringTable))
+	s.dict.StringTable = append(s.dict.StringTable, str)
+	s.indices[str] = idx
+	return idx
 }
@@ -4,17 +4,24 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/csv"
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
 	common "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/common/v1"
 	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
 	resource "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/resource/v1"
+	"github.com/parquet-go/parquet-go"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/testing/protocmp"
 )
 
@@ -33,8 +40,743 @@ func TestApp(t *testing.T) {
 	if err != nil {
 		t.Fatalf("read csv: %v\n%s\n", err, string(results))
 	}
-	assertEqual(t, records[0], []string{"file", "encoding", "payloads", "uncompressed_bytes", "gzip_6_bytes"})
-	assertEqual(t, len(records), 4)
+	assertEqual(t, records[0], []string{
+		"file", "encoding", "proto_version", "lossy", "payloads", "uncompressed_bytes", "gzip_6_bytes", "zstd_3_bytes", "sha256",
+		"uncompressed_bytes_delta", "uncompressed_bytes_change_pct", "gzip_6_bytes_delta", "gzip_6_bytes_change_pct", "zstd_3_bytes_delta", "zstd_3_bytes_change_pct",
+		"gzip_saved", "zstd_saved", "bloat_score", "limited", "time_normalized", "conformance",
+	})
+	assertEqual(t, len(records), 1+len(strategies))
+	assertEqual(t, records[1][2], benchProtoVersion)
+	// The changes are relative to the baseline in the first row.
+	for _, record := range records[1:] {
+		for i := range sizeColumns {
+			size, _ := strconv.Atoi(record[5+i])
+			base, _ := strconv.Atoi(records[1][5+i])
+			assertEqual(t, record[9+2*i], strconv.Itoa(size-base))
+			assertEqual(t, record[10+2*i], fmt.Sprintf("%.2f", 100*float64(size-base)/float64(base)))
+		}
+		uncompressed, _ := strconv.Atoi(record[5])
+		for i, col := range []int{6, 7} {
+			size, _ := strconv.Atoi(record[col])
+			assertEqual(t, record[15+i], strconv.Itoa(uncompressed-size))
+		}
+		if score, err := strconv.ParseFloat(record[17], 64); err != nil || score < 0 || score > 1 {
+			t.Errorf("%s: got bloat score %q, want a fraction", record[1], record[17])
+		}
+		// Only the verified strategies are checked by profcheck.
+		wantConformance := ""
+		if record[1] == "canonicalize" {
+			wantConformance = "pass"
+		}
+		assertEqual(t, record[20], wantConformance)
+	}
+}
+
+func TestHumanBytes(t *testing.T) {
+	for _, tc := range []struct {
+		n    int
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1536, "1.5 KiB"},
+		{5 << 20, "5.0 MiB"},
+		{3 << 30, "3.0 GiB"},
+	} {
+		assertEqual(t, humanBytes(tc.n), tc.want)
+	}
+}
+
+func TestAppHashStable(t *testing.T) {
+	var hashes [2][]string
+	for i := range hashes {
+		stdout, _, err := runTestApp(t, []string{"--out", "-", filepath.Join("testdata", "k8s.otlp")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+		if err != nil {
+			t.Fatalf("read csv: %v\n%s\n", err, stdout)
+		}
+		col := slices.Index(records[0], "sha256")
+		for _, record := range records[1:] {
+			hashes[i] = append(hashes[i], record[col])
+		}
+	}
+	assertEqual(t, hashes[0], hashes[1])
+	if hashes[0][0] == hashes[0][1] {
+		t.Errorf("baseline and split-by-process have the same hash %s", hashes[0][0])
+	}
+}
+
+func TestProfileSizesDeterministic(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "k8s.otlp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Decode twice so that the payloads don't share any state.
+	var encoded [2][][]byte
+	var sizes [2][]profileSize
+	for i := range encoded {
+		payloads, err := unmarshalOTLP(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, payload := range payloads {
+			size, b, err := profileSizes(payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoded[i] = append(encoded[i], b)
+			sizes[i] = append(sizes[i], size)
+		}
+	}
+	for i := range encoded[0] {
+		if !bytes.Equal(encoded[0][i], encoded[1][i]) {
+			t.Errorf("payload %d: marshaled bytes differ between runs", i)
+		}
+	}
+	if !slices.Equal(sizes[0], sizes[1]) {
+		t.Errorf("sizes differ between runs: %v != %v", sizes[0], sizes[1])
+	}
+}
+
+func TestAppGlob(t *testing.T) {
+	stdout, _, err := runTestApp(t, []string{"--out", "-", filepath.Join("testdata", "*.otlp")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v\n%s\n", err, stdout)
+	}
+	assertEqual(t, len(records), 1+2*len(strategies))
+
+	pattern := filepath.Join("testdata", "*.missing")
+	_, _, err = runTestApp(t, []string{"--out", "-", pattern})
+	if err == nil || !strings.Contains(err.Error(), pattern) {
+		t.Errorf("got error %v, want error naming %q", err, pattern)
+	}
+}
+
+func TestAppStdout(t *testing.T) {
+	stdout, _, err := runTestApp(t, []string{"--out", "-", filepath.Join("testdata", "k8s.otlp")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v\n%s\n", err, stdout)
+	}
+	assertEqual(t, records[0], summaryColumns())
+	assertEqual(t, len(records), 1+len(strategies))
+	if _, err := os.Stat("-"); err == nil {
+		t.Errorf("unexpected output directory %q", "-")
+	}
+}
+
+func TestAppParquet(t *testing.T) {
+	outDir := filepath.Join(t.TempDir(), "out")
+	if _, _, err := runTestApp(t, []string{"--out", outDir, "--out-format", "parquet", filepath.Join("testdata", "k8s.otlp")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "summary.csv")); err == nil {
+		t.Error("unexpected summary.csv")
+	}
+
+	f, err := os.Open(filepath.Join(outDir, "summary.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := parquet.OpenFile(f, info.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, file.NumRows(), int64(len(strategies)))
+	for _, col := range summaryColumns() {
+		leaf, ok := file.Schema().Lookup(col)
+		if !ok {
+			t.Errorf("missing column %q", col)
+			continue
+		}
+		want := parquet.Int64
+		switch {
+		case col == "file", col == "encoding", col == "proto_version", col == "sha256", col == "conformance":
+			want = parquet.ByteArray
+		case col == "lossy", col == "limited", col == "time_normalized":
+			want = parquet.Boolean
+		case strings.HasSuffix(col, "_change_pct"), col == "bloat_score":
+			want = parquet.Double
+		}
+		if got := leaf.Node.Type().Kind(); got != want {
+			t.Errorf("column %q: got kind %v, want %v", col, got, want)
+		}
+	}
+
+	if _, _, err := runTestApp(t, []string{"--out", outDir, "--out-format", "xml", filepath.Join("testdata", "k8s.otlp")}); err == nil {
+		t.Error("expected error for unknown --out-format")
+	}
+}
+
+func TestAppRepeat(t *testing.T) {
+	outDir := filepath.Join(t.TempDir(), "out")
+	if _, _, err := runTestApp(t, []string{"--out", outDir, "--repeat", "3", filepath.Join("testdata", "k8s.otlp")}); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filepath.Join(outDir, "repeat.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, records[0], []string{"file", "encoding", "column", "runs", "min", "mean", "max"})
+	assertEqual(t, len(records), 1+len(strategies)*len(sizeColumns))
+	for _, record := range records[1:] {
+		// All strategies are deterministic, so there must be no spread.
+		if record[4] != record[6] {
+			t.Errorf("%s %s %s: min %s != max %s", record[0], record[1], record[2], record[4], record[6])
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(outDir, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, m.Repeat, 3)
+
+	if _, _, err := runTestApp(t, []string{"--out", "-", "--repeat", "2", filepath.Join("testdata", "k8s.otlp")}); err == nil {
+		t.Error("expected error for --repeat with --out=-")
+	}
+}
+
+func TestAppExcludeStrategy(t *testing.T) {
+	input := filepath.Join("testdata", "k8s.otlp")
+	stdout, _, err := runTestApp(t, []string{"--out", "-", "--exclude-strategy", "resource-attr-dict", "--exclude-strategy", "canonicalize", input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got []string
+	for _, s := range strategies {
+		if s.name != "resource-attr-dict" && s.name != "canonicalize" {
+			want = append(want, s.name)
+		}
+	}
+	for _, record := range records[1:] {
+		got = append(got, record[1])
+	}
+	assertEqual(t, got, want)
+
+	for _, tc := range []struct {
+		exclude string
+		wantErr string
+	}{
+		{exclude: "baseline", wantErr: "can't exclude baseline"},
+		{exclude: "no-such-strategy", wantErr: "unknown strategy"},
+		{exclude: "split-by-process", wantErr: "resource-attr-dict is based on split-by-process"},
+	} {
+		_, _, err := runTestApp(t, []string{"--out", "-", "--exclude-strategy", tc.exclude, input})
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("--exclude-strategy %s: got error %v, want error containing %q", tc.exclude, err, tc.wantErr)
+		}
+	}
+}
+
+func TestAppLimit(t *testing.T) {
+	input := filepath.Join("testdata", "k8s.otlp")
+	for _, tc := range []struct {
+		limit        string
+		wantPayloads string
+		wantLimited  string
+	}{
+		{limit: "1", wantPayloads: "1", wantLimited: "true"},
+		{limit: "2", wantPayloads: "2", wantLimited: "false"},
+		{limit: "0", wantPayloads: "2", wantLimited: "false"},
+	} {
+		stdout, _, err := runTestApp(t, []string{"--out", "-", "--limit", tc.limit, input})
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		limited := slices.Index(records[0], "limited")
+		for _, record := range records[1:] {
+			if record[4] != tc.wantPayloads || record[limited] != tc.wantLimited {
+				t.Errorf("--limit %s: %s: got %s payloads, limited %s, want %s, %s", tc.limit, record[1], record[4], record[limited], tc.wantPayloads, tc.wantLimited)
+			}
+		}
+	}
+
+	if _, _, err := runTestApp(t, []string{"--out", "-", "--limit", "-1", input}); err == nil {
+		t.Error("expected error for negative --limit")
+	}
+	if _, _, err := runTestApp(t, []string{"--out", t.TempDir(), "--limit", "1", "--merge", input}); err == nil {
+		t.Error("expected error for --limit with --merge")
+	}
+}
+
+func TestAppMemStats(t *testing.T) {
+	outDir := filepath.Join(t.TempDir(), "out")
+	if _, _, err := runTestApp(t, []string{"--out", outDir, "--mem-stats", filepath.Join("testdata", "k8s.otlp")}); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filepath.Join(outDir, "memstats.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, records[0], []string{"file", "encoding", "alloc_bytes", "allocs"})
+	assertEqual(t, len(records), 1+len(strategies))
+	for i, record := range records[1:] {
+		assertEqual(t, record[1], strategies[i].name)
+		// split-by-process clones the dictionary, so it must allocate.
+		if record[1] == "split-by-process" && record[2] == "0" {
+			t.Errorf("%s: expected allocations", record[1])
+		}
+	}
+
+	if _, _, err := runTestApp(t, []string{"--out", "-", "--mem-stats", filepath.Join("testdata", "k8s.otlp")}); err == nil {
+		t.Error("expected error for --mem-stats with --out=-")
+	}
+}
+
+func TestAppTimingRuns(t *testing.T) {
+	outDir := filepath.Join(t.TempDir(), "out")
+	if _, _, err := runTestApp(t, []string{"--out", outDir, "--warmup", "1", "--timing-runs", "3", filepath.Join("testdata", "k8s.otlp")}); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filepath.Join(outDir, "timing.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, records[0], []string{"file", "encoding", "codec", "runs", "median_ns", "p95_ns"})
+	assertEqual(t, len(records), 1+len(strategies)*len(timedCodecs))
+	for i, record := range records[1:] {
+		assertEqual(t, record[1], strategies[i/len(timedCodecs)].name)
+		assertEqual(t, record[2], timedCodecs[i%len(timedCodecs)].name)
+		assertEqual(t, record[3], "3")
+		median, _ := strconv.ParseInt(record[4], 10, 64)
+		p95, _ := strconv.ParseInt(record[5], 10, 64)
+		if median <= 0 || p95 < median {
+			t.Errorf("%s %s: got median %d ns, p95 %d ns", record[1], record[2], median, p95)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"--out", "-", "--timing-runs", "3"},
+		{"--out", outDir, "--warmup", "1"},
+		{"--out", outDir, "--timing-runs", "-1"},
+	} {
+		if _, _, err := runTestApp(t, append(args, filepath.Join("testdata", "k8s.otlp"))); err == nil {
+			t.Errorf("%v: expected error", args)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	durations := []time.Duration{5, 1, 4, 2, 3}
+	assertEqual(t, percentile(durations, 50), time.Duration(3))
+	assertEqual(t, percentile(durations, 95), time.Duration(5))
+	assertEqual(t, percentile(durations[:1], 95), time.Duration(5))
+	assertEqual(t, percentile(nil, 50), time.Duration(0))
+}
+
+func TestAppJSONOut(t *testing.T) {
+	input := filepath.Join("testdata", "k8s.otlp")
+	data, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloads, err := unmarshalOTLP(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jsonDir := filepath.Join(t.TempDir(), "json")
+	if _, _, err := runTestApp(t, []string{"--out", "-", "--json-out", jsonDir, input}); err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := os.ReadFile(filepath.Join(jsonDir, "k8s.otlp.0.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req jsonRequest
+	if err := json.Unmarshal(resolved, &req); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, len(req.ResourceProfiles), len(payloads[0].ResourceProfiles))
+	if strings.Contains(string(resolved), "Strindex") {
+		t.Error("resolved JSON contains string table indices")
+	}
+
+	if _, _, err := runTestApp(t, []string{"--out", "-", "--json-out", jsonDir, "--json-indices", input}); err != nil {
+		t.Fatal(err)
+	}
+	indexed, err := os.ReadFile(filepath.Join(jsonDir, "k8s.otlp.0.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &cprofiles.ExportProfilesServiceRequest{}
+	if err := protojson.Unmarshal(indexed, got); err != nil {
+		t.Fatal(err)
+	}
+	// Unknown fields can't be represented in JSON.
+	if diff := cmp.Diff(payloads[0], got, protocmp.Transform(), protocmp.IgnoreUnknown()); diff != "" {
+		t.Errorf("--json-indices output does not round trip (-want +got):\n%s", diff)
+	}
+}
+
+func TestStripTimestamps(t *testing.T) {
+	data := &cprofiles.ExportProfilesServiceRequest{
+		Dictionary: &profiles.ProfilesDictionary{},
+		ResourceProfiles: []*profiles.ResourceProfiles{{
+			ScopeProfiles: []*profiles.ScopeProfiles{{
+				Profiles: []*profiles.Profile{{
+					Samples: []*profiles.Sample{
+						{StackIndex: 1, Values: []int64{1, 2}, TimestampsUnixNano: []uint64{10, 20}},
+						{StackIndex: 2, AttributeIndices: []int32{1}, TimestampsUnixNano: []uint64{10, 20, 30}},
+						{StackIndex: 1, Values: []int64{4}, TimestampsUnixNano: []uint64{30}},
+						{StackIndex: 2, AttributeIndices: []int32{2}, Values: []int64{5}, TimestampsUnixNano: []uint64{30}},
+					},
+				}},
+			}},
+		}},
+	}
+	want := []*profiles.Sample{
+		{StackIndex: 1, Values: []int64{7}},
+		{StackIndex: 2, AttributeIndices: []int32{1}, Values: []int64{3}},
+		{StackIndex: 2, AttributeIndices: []int32{2}, Values: []int64{5}},
+	}
+	original := proto.Clone(data)
+
+	got, err := stripTimestamps(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, got.ResourceProfiles[0].ScopeProfiles[0].Profiles[0].Samples, want)
+	assertEqual(t, data, original)
+}
+
+func TestSortResources(t *testing.T) {
+	resourceProfiles := func(values ...string) []*profiles.ResourceProfiles {
+		var rps []*profiles.ResourceProfiles
+		for _, v := range values {
+			rps = append(rps, &profiles.ResourceProfiles{
+				Resource: &resource.Resource{Attributes: []*common.KeyValue{{
+					Key:   "process.pid",
+					Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: v}},
+				}}},
+			})
+		}
+		return rps
+	}
+	data := &cprofiles.ExportProfilesServiceRequest{
+		Dictionary:       &profiles.ProfilesDictionary{},
+		ResourceProfiles: resourceProfiles("3", "1", "2", "1"),
+	}
+	input := proto.Clone(data)
+
+	got, err := sortResources(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, got.ResourceProfiles, resourceProfiles("1", "1", "2", "3"))
+	assertEqual(t, data, input)
+}
+
+func TestInternAttrValues(t *testing.T) {
+	data := &cprofiles.ExportProfilesServiceRequest{
+		Dictionary: &profiles.ProfilesDictionary{
+			StringTable: []string{"", "thread.name", "main"},
+			AttributeTable: []*profiles.KeyValueAndUnit{
+				{},
+				{KeyStrindex: 1, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "main"}}},
+				{KeyStrindex: 1, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "worker"}}},
+				{KeyStrindex: 1, Value: &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: 1}}},
+				{KeyStrindex: 1, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "worker"}}},
+			},
+		},
+	}
+	input := proto.Clone(data)
+
+	got, err := internAttrValues(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, got.Dictionary.StringTable, []string{"", "thread.name", "main", "worker"})
+	attrs := got.Dictionary.AttributeTable
+	assertEqual(t, attrs[1].Value.GetStringRef(), int32(2))
+	assertEqual(t, attrs[2].Value.GetStringRef(), int32(3))
+	assertEqual(t, attrs[3].Value.GetIntValue(), int64(1))
+	// A string added by an earlier attribute is reused.
+	assertEqual(t, attrs[4].Value.GetStringRef(), int32(3))
+	if !proto.Equal(data, input) {
+		t.Error("internAttrValues modified its input")
+	}
+}
+
+func TestAggregateSamples(t *testing.T) {
+	got := aggregateSamples([]*profiles.Sample{
+		{StackIndex: 1, AttributeIndices: []int32{1, 2}, Values: []int64{3}},
+		{StackIndex: 1, AttributeIndices: []int32{2, 1}, Values: []int64{4}},
+		{StackIndex: 1, AttributeIndices: []int32{1}, TimestampsUnixNano: []uint64{1, 2}},
+		{StackIndex: 1, AttributeIndices: []int32{1, 2}, LinkIndex: 1, Values: []int64{5}},
+	})
+	assertEqual(t, got, []*profiles.Sample{
+		{StackIndex: 1, AttributeIndices: []int32{1, 2}, Values: []int64{7}},
+		{StackIndex: 1, AttributeIndices: []int32{1}, Values: []int64{2}},
+		{StackIndex: 1, AttributeIndices: []int32{1, 2}, LinkIndex: 1, Values: []int64{5}},
+	})
+}
+
+func TestNilResource(t *testing.T) {
+	data := createTestProfilesData([]testSample{{
+		processAttrs: map[string]string{"process.executable.name": "foo"},
+	}})
+	data.ResourceProfiles[0].Resource = nil
+
+	byProcess, err := splitByProcess(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, len(byProcess.ResourceProfiles), 1)
+	assertEqual(t, len(byProcess.ResourceProfiles[0].Resource.Attributes), 1)
+
+	resourceAttrDict, err := useResourceAttrDict(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, len(resourceAttrDict.ResourceProfiles[0].Resource.Attributes), 0)
+
+	var buf bytes.Buffer
+	printProfile(&buf, data, 1000)
+	if !strings.HasPrefix(buf.String(), "Resource: \n") {
+		t.Errorf("printProfile(): got %q, want empty resource", buf.String())
+	}
+}
+
+func TestAppDumpSamples(t *testing.T) {
+	input := filepath.Join("testdata", "k8s.otlp")
+	outDir := filepath.Join(t.TempDir(), "out")
+	if _, _, err := runTestApp(t, []string{"--out", outDir, "--dump-samples", "1", input}); err != nil {
+		t.Fatal(err)
+	}
+	dump, err := os.ReadFile(filepath.Join(outDir, "k8s.otlp.baseline.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(dump), "more samples omitted)\n") {
+		t.Errorf("baseline dump does not report omitted samples:\n%s", dump)
+	}
+
+	if _, _, err := runTestApp(t, []string{"--out", outDir, "--dump-samples", "0", input}); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := filepath.Glob(filepath.Join(outDir, "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, len(matches), 0)
+}
+
+func TestAppEmit(t *testing.T) {
+	input := filepath.Join("testdata", "k8s.otlp")
+	outDir := filepath.Join(t.TempDir(), "out")
+	if _, _, err := runTestApp(t, []string{"--out", outDir, "--emit", "split-by-process", input}); err != nil {
+		t.Fatal(err)
+	}
+	emitted, err := os.ReadFile(filepath.Join(outDir, "k8s.otlp.split-by-process.otlp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := unmarshalOTLP(emitted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloads, err := unmarshalOTLP(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []*cprofiles.ExportProfilesServiceRequest
+	for _, payload := range payloads {
+		split, err := splitByProcess(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, split)
+	}
+	assertEqual(t, got, want)
+
+	if _, _, err := runTestApp(t, []string{"--out", outDir, "--emit", "nope", input}); err == nil {
+		t.Error("expected error for unknown strategy")
+	}
+}
+
+func TestAppEmitCompressed(t *testing.T) {
+	outDir := filepath.Join(t.TempDir(), "out")
+	if _, _, err := runTestApp(t, []string{"--out", outDir, "--emit-compressed", filepath.Join("testdata", "k8s.otlp")}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filepath.Join(outDir, "summary.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The files hold exactly the measured bytes, and decompress to the
+	// measured uncompressed size.
+	for _, record := range records[1:] {
+		encoding, uncompressed, gzipped, zstded := record[1], record[5], record[6], record[7]
+		gz, err := os.ReadFile(filepath.Join(outDir, "k8s.otlp."+encoding+".gz"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		zst, err := os.ReadFile(filepath.Join(outDir, "k8s.otlp."+encoding+".zst"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, strconv.Itoa(len(gz)), gzipped)
+		assertEqual(t, strconv.Itoa(len(zst)), zstded)
+		data, err := decompressInput(gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, strconv.Itoa(len(data)), uncompressed)
+	}
+
+	if _, _, err := runTestApp(t, []string{"--out", "-", "--emit-compressed", filepath.Join("testdata", "k8s.otlp")}); err == nil {
+		t.Error("expected error for --emit-compressed without output directory")
+	}
+}
+
+func TestAppSkipsPanickingFile(t *testing.T) {
+	// The strategies don't panic on any known input, so inject one that
+	// does for payloads marked by a string.
+	orig := strategies
+	t.Cleanup(func() { strategies = orig })
+	strategies = append(slices.Clone(orig), strategy{
+		name: "panic",
+		transform: func(req *cprofiles.ExportProfilesServiceRequest) (*cprofiles.ExportProfilesServiceRequest, error) {
+			if slices.Contains(req.GetDictionary().GetStringTable(), "panic") {
+				panic("boom")
+			}
+			return req, nil
+		},
+	})
+
+	bad := minimalRequest()
+	bad.Dictionary.StringTable = append(bad.Dictionary.StringTable, "panic")
+	data, err := proto.Marshal(bad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	badPath := filepath.Join(t.TempDir(), "bad.otlp")
+	if err := os.WriteFile(badPath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	good := filepath.Join("testdata", "k8s.otlp")
+
+	outDir := filepath.Join(t.TempDir(), "out")
+	_, stderr, err := runTestApp(t, []string{"--out", outDir, badPath, good})
+	if err == nil || err.Error() != "1 of 2 files skipped after a panic" {
+		t.Errorf("got error %v, want 1 of 2 files skipped", err)
+	}
+	if !strings.Contains(stderr, badPath+": skipped after panic: boom") {
+		t.Errorf("missing skip message in stderr:\n%s", stderr)
+	}
+
+	// The file after the skipped one is still measured.
+	f, err := os.Open(filepath.Join(outDir, "summary.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, len(records), 1+len(strategies))
+	for _, record := range records[1:] {
+		assertEqual(t, record[0], good)
+	}
+
+	manifestData, err := os.ReadFile(filepath.Join(outDir, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m manifest
+	if err := json.Unmarshal(manifestData, &m); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(m.Skipped, []skippedFile{{File: badPath, Error: "boom"}}) {
+		t.Errorf("got skipped %v, want %s", m.Skipped, badPath)
+	}
+}
+
+func TestAppEmptyProfiles(t *testing.T) {
+	req := minimalRequest()
+	sp := req.ResourceProfiles[0].ScopeProfiles[0]
+	sp.Profiles = append(sp.Profiles, &profiles.Profile{TimeUnixNano: 100, DurationNano: 10})
+	data, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "empty.otlp")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, stderr, err := runTestApp(t, []string{"--out", "-", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "warning: " + path + ": 1 of 2 profiles have no samples"; !strings.Contains(stderr, want) {
+		t.Errorf("got stderr %q, want %q", stderr, want)
+	}
+
+	_, stderr, err = runTestApp(t, []string{"--out", "-", filepath.Join("testdata", "k8s.otlp")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stderr, "have no samples") {
+		t.Errorf("got stderr %q, want no warning without empty profiles", stderr)
+	}
 }
 
 type testSample struct {
@@ -316,19 +1058,19 @@ func createTestProfilesDataWithMixedResourceAttrs(resourceAttrsList []resourceAt
 
 func createTestProfilesDataWithPreDictifiedAttrs(resourceAttrsList []resourceAttrs) *cprofiles.ExportProfilesServiceRequest {
 	data := createTestProfilesDataWithResourceAttrs(resourceAttrsList)
-	dict := data.Dictionary
+	strs := newStringIndex(data.Dictionary)
 
 	// Pre-dictify the first attribute
 	if len(data.ResourceProfiles) > 0 && len(data.ResourceProfiles[0].Resource.Attributes) > 0 {
 		attr := data.ResourceProfiles[0].Resource.Attributes[0]
 		if attr.Key != "" {
-			attr.KeyRef = dictStrIndex(attr.Key, dict)
+			attr.KeyRef = strs.index(attr.Key)
 			attr.Key = ""
 		}
 		if attr.Value.GetStringValue() != "" {
 			attr.Value = &common.AnyValue{
 				Value: &common.AnyValue_StringRef{
-					StringRef: dictStrIndex(attr.Value.GetStringValue(), dict),
+					StringRef: strs.index(attr.Value.GetStringValue()),
 				},
 			}
 		}
@@ -358,10 +1100,9 @@ func assertEqual(t *testing.T, got, want any) {
 func TestSplitByProcess(t *testing.T) {
 	// Test with manually constructed data to achieve higher coverage
 	testCases := []struct {
-		name        string
-		input       *cprofiles.ExportProfilesServiceRequest
-		expectPanic bool
-		panicMsg    string
+		name    string
+		input   *cprofiles.ExportProfilesServiceRequest
+		wantErr string
 	}{
 		{
 			name: "basic split by process",
@@ -371,20 +1112,18 @@ func TestSplitByProcess(t *testing.T) {
 			}),
 		},
 		{
-			name: "process attribute with unit (should panic)",
+			name: "process attribute with unit (should fail)",
 			input: createTestProfilesDataWithUnit([]testSample{
 				{processAttrs: map[string]string{"process.pid": "123"}, otherAttrs: map[string]string{"thread.id": "456"}},
 			}),
-			expectPanic: true,
-			panicMsg:    "process attribute with unit is not supported",
+			wantErr: "process attribute with unit is not supported",
 		},
 		{
-			name: "profile with original payload (should panic)",
+			name: "profile with original payload (should fail)",
 			input: createTestProfilesDataWithOriginalPayload([]testSample{
 				{processAttrs: map[string]string{"process.pid": "123"}, otherAttrs: map[string]string{"thread.id": "456"}},
 			}),
-			expectPanic: true,
-			panicMsg:    "splitting a profile with an original payload is not supported",
+			wantErr: "splitting a profile with an original payload is not supported",
 		},
 		{
 			name: "multiple processes with same resource attributes",
@@ -398,30 +1137,19 @@ func TestSplitByProcess(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			if tc.expectPanic {
-				defer func() {
-					if r := recover(); r != nil {
-						if panicMsg, ok := r.(string); ok && panicMsg == tc.panicMsg {
-							// Expected panic
-							return
-						}
-						t.Errorf("unexpected panic: %v", r)
-					} else {
-						t.Errorf("expected panic with message %q but no panic occurred", tc.panicMsg)
-					}
-				}()
-			}
-
 			// Count total samples before splitting
 			originalSampleCount := countSamples(tc.input)
 
-			result := splitByProcess(tc.input)
-			if result == nil {
-				if !tc.expectPanic {
-					t.Fatal("splitByProcess returned nil")
+			result, err := splitByProcess(tc.input)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("expected error %q, got %v", tc.wantErr, err)
 				}
 				return
 			}
+			if err != nil {
+				t.Fatal(err)
+			}
 
 			// Verify dictionary is preserved
 			if result.Dictionary == nil {
@@ -471,9 +1199,9 @@ func TestSplitByProcess(t *testing.T) {
 		// Count total samples before splitting
 		originalSampleCount := countSamples(gh733Profile)
 
-		result := splitByProcess(gh733Profile)
-		if result == nil {
-			t.Fatal("splitByProcess returned nil")
+		result, err := splitByProcess(gh733Profile)
+		if err != nil {
+			t.Fatal(err)
 		}
 
 		// Verify dictionary is preserved
@@ -670,6 +1398,27 @@ func TestScaleSamples(t *testing.T) {
 	}
 }
 
+func TestEstimateScaledSize(t *testing.T) {
+	data := createTestProfilesData([]testSample{
+		{processAttrs: map[string]string{"process.pid": "123"}},
+		{processAttrs: map[string]string{"process.pid": "456"}},
+	})
+	estimate := estimateScaledSize(data, 10)
+	scaleSamples(data, 10)
+	actual := proto.Size(data)
+	// The estimate ignores the growth of the enclosing length prefixes.
+	if estimate > actual || actual-estimate > 16 {
+		t.Errorf("estimateScaledSize() = %d, want close to %d", estimate, actual)
+	}
+}
+
+func TestAppSamplesTooLarge(t *testing.T) {
+	_, _, err := runTestApp(t, []string{"--out", t.TempDir(), "--samples", "1000000000", filepath.Join("testdata", "k8s.otlp")})
+	if err == nil || !strings.Contains(err.Error(), "k8s.otlp: scaling samples by 1000000000") {
+		t.Errorf("got error %v, want scaling error", err)
+	}
+}
+
 func TestUseResourceAttrDict(t *testing.T) {
 	// Test with manually constructed data to achieve higher coverage
 	testCases := []struct {
@@ -708,9 +1457,9 @@ func TestUseResourceAttrDict(t *testing.T) {
 			// Count original dictionary size
 			originalDictSize := len(tc.input.Dictionary.StringTable)
 
-			result := useResourceAttrDict(tc.input)
-			if result == nil {
-				t.Fatal("useResourceAttrDict returned nil")
+			result, err := useResourceAttrDict(tc.input)
+			if err != nil {
+				t.Fatal(err)
 			}
 
 			// Verify dictionary exists and has grown or stayed the same
@@ -826,9 +1575,9 @@ func TestUseResourceAttrDict(t *testing.T) {
 		// Count original dictionary size
 		originalDictSize := len(originalProfile.Dictionary.StringTable)
 
-		result := useResourceAttrDict(originalProfile)
-		if result == nil {
-			t.Fatal("useResourceAttrDict returned nil")
+		result, err := useResourceAttrDict(originalProfile)
+		if err != nil {
+			t.Fatal(err)
 		}
 
 		// Verify dictionary exists and has grown or stayed the same
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"text/tabwriter"
+)
+
+// mapVersions decodes every payload in data, which is in the given framing,
+// with proto version a, copies it into a message of version b by copyFields
+// and writes the marshaled sizes of both to out, with the fields that could not
+// be mapped, which include renamed fields. The size difference is what the
+// schema change between the versions does to the wire format of the same
+// logical profile.
+func mapVersions(out io.Writer, file string, data []byte, framing string, a, b protoVersion) error {
+	payloads, err := unmarshalFramed(data, framing, a.newRequest)
+	if err != nil {
+		return fmt.Errorf("unmarshal %s profile: %w", a.name, err)
+	}
+	var aSize, bSize profileSize
+	dropped := map[string]int{}
+	for _, payload := range payloads {
+		mapped := b.newRequest()
+		copyFields(mapped.ProtoReflect(), payload.ProtoReflect(), nil, func(path, reason string) {
+			dropped[pathOrRoot(path)+": "+reason]++
+		})
+		sizes, _, err := profileSizes(payload)
+		if err != nil {
+			return fmt.Errorf("calculate %s sizes: %w", a.name, err)
+		}
+		aSize = aSize.Add(sizes)
+		if sizes, _, err = profileSizes(mapped); err != nil {
+			return fmt.Errorf("calculate %s sizes: %w", b.name, err)
+		}
+		bSize = bSize.Add(sizes)
+	}
+
+	fmt.Fprintf(out, "%s: %s mapped to %s:\n", file, a.name, b.name)
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "  version\tuncompressed\t\tgzip\t\tzstd\t\n")
+	fmt.Fprintf(tw, "  %s\t%s\t\t%s\t\t%s\t\n", a.name, humanBytes(aSize.uncompressed), humanBytes(aSize.gzip6), humanBytes(aSize.zstd3))
+	fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\t%s\t%s\n", b.name,
+		humanBytes(bSize.uncompressed), percentChange(aSize.uncompressed, bSize.uncompressed),
+		humanBytes(bSize.gzip6), percentChange(aSize.gzip6, bSize.gzip6),
+		humanBytes(bSize.zstd3), percentChange(aSize.zstd3, bSize.zstd3))
+	tw.Flush()
+	if len(dropped) > 0 {
+		fmt.Fprintf(out, "  not mapped, the sizes of %s are without them:\n", b.name)
+		for _, path := range slices.Sorted(maps.Keys(dropped)) {
+			fmt.Fprintf(out, "    %s (count: %d)\n", path, dropped[path])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	common "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/common/v1"
+	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
+	resource "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/resource/v1"
+	upstreamcprofiles "go.opentelemetry.io/proto/otlp/collector/profiles/v1development"
+	upstreamcommon "go.opentelemetry.io/proto/otlp/common/v1"
+	upstreamprofiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
+	upstreamresource "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCopyFields(t *testing.T) {
+	src := &cprofiles.ExportProfilesServiceRequest{
+		Dictionary: &profiles.ProfilesDictionary{StringTable: []string{"", "cpu", "host.name"}},
+		ResourceProfiles: []*profiles.ResourceProfiles{{
+			Resource: &resource.Resource{Attributes: []*common.KeyValue{
+				{Key: "service.name", Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "api"}}},
+				{KeyRef: 2, Value: &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: 1}}},
+			}},
+			ScopeProfiles: []*profiles.ScopeProfiles{{
+				Profiles: []*profiles.Profile{{
+					SampleType: &profiles.ValueType{TypeStrindex: 1},
+					Samples:    []*profiles.Sample{{StackIndex: 1, AttributeIndices: []int32{2, 3}, LinkIndex: 4, Values: []int64{5}}},
+				}},
+			}},
+		}},
+	}
+
+	dst := &upstreamcprofiles.ExportProfilesServiceRequest{}
+	dropped := map[string]int{}
+	drop := func(path, reason string) { dropped[path+": "+reason]++ }
+	copyFields(dst.ProtoReflect(), src.ProtoReflect(), nil, drop)
+
+	want := &upstreamcprofiles.ExportProfilesServiceRequest{
+		Dictionary: &upstreamprofiles.ProfilesDictionary{StringTable: []string{"", "cpu", "host.name"}},
+		ResourceProfiles: []*upstreamprofiles.ResourceProfiles{{
+			Resource: &upstreamresource.Resource{Attributes: []*upstreamcommon.KeyValue{
+				{Key: "service.name", Value: &upstreamcommon.AnyValue{Value: &upstreamcommon.AnyValue_StringValue{StringValue: "api"}}},
+				{Value: &upstreamcommon.AnyValue{Value: &upstreamcommon.AnyValue_IntValue{IntValue: 1}}},
+			}},
+			ScopeProfiles: []*upstreamprofiles.ScopeProfiles{{
+				Profiles: []*upstreamprofiles.Profile{{
+					SampleType: &upstreamprofiles.ValueType{TypeStrindex: 1},
+					// The fields are renumbered in gh733, but keep their
+					// values as they are matched by name.
+					Samples: []*upstreamprofiles.Sample{{StackIndex: 1, AttributeIndices: []int32{2, 3}, LinkIndex: 4, Values: []int64{5}}},
+				}},
+			}},
+		}},
+	}
+	assertEqual(t, dst, want)
+	assertEqual(t, dropped, map[string]int{"resource_profiles.resource.attributes.key_ref: no such field": 1})
+
+	// With the rename, key_ref is copied too.
+	dst = &upstreamcprofiles.ExportProfilesServiceRequest{}
+	clear(dropped)
+	copyFields(dst.ProtoReflect(), src.ProtoReflect(), upstreamFieldNames, drop)
+	want.ResourceProfiles[0].Resource.Attributes[1].KeyStrindex = 2
+	assertEqual(t, dst, want)
+	assertEqual(t, dropped, map[string]int{})
+
+	// Copying into the same version is a copy.
+	same := &cprofiles.ExportProfilesServiceRequest{}
+	copyFields(same.ProtoReflect(), src.ProtoReflect(), nil, drop)
+	if !proto.Equal(same, src) {
+		t.Errorf("mapping into the same version: got %v, want %v", same, src)
+	}
+}
+
+func TestAppMapVersions(t *testing.T) {
+	stdout, _, err := runTestApp(t, []string{"--map-versions", "gh733,upstream", filepath.Join("testdata", "k8s.otlp")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"gh733 mapped to upstream", "  gh733 ", "  upstream "} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("got report:\n%s\nwant it to contain %q", stdout, want)
+		}
+	}
+
+	_, _, err = runTestApp(t, []string{"--map-versions", "gh733", filepath.Join("testdata", "k8s.otlp")})
+	if err == nil || !strings.Contains(err.Error(), "--map-versions needs exactly two versions") {
+		t.Errorf("got error %v, want two versions error", err)
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	common "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/common/v1"
+	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
+	"google.golang.org/protobuf/proto"
+)
+
+// mergedFilename is the file name the combined payload is reported as with
+// --merge.
+const mergedFilename = "merged"
+
+// mergeFiles reads all files in the given framing and returns their payloads
+// combined into one encoded payload, see mergeRequests.
+func mergeFiles(files []string, outDir string, toStdout bool, framing string) ([]byte, error) {
+	var reqs []*cprofiles.ExportProfilesServiceRequest
+	for _, file := range files {
+		data, err := readInput(file, outDir, toStdout)
+		if err != nil {
+			return nil, err
+		}
+		payloads, err := unmarshalFormat(data, framing)
+		if err != nil {
+			return nil, fmt.Errorf("%s: unmarshal gh733 profile: %w", file, err)
+		}
+		reqs = append(reqs, payloads...)
+	}
+	data, err := marshalOptions.Marshal(mergeRequests(reqs))
+	if err != nil {
+		return nil, fmt.Errorf("marshal merged payload: %w", err)
+	}
+	return data, nil
+}
+
+// mergeRequests concatenates the resource profiles of reqs into one request,
+// as a collector batching several exports would. The dictionaries are merged
+// into one that stores identical entries once, and all references are
+// rewritten to point into it. Out of range references are mapped to index 0.
+func mergeRequests(reqs []*cprofiles.ExportProfilesServiceRequest) *cprofiles.ExportProfilesServiceRequest {
+	m := newDictMerger()
+	merged := &cprofiles.ExportProfilesServiceRequest{}
+	for _, req := range reqs {
+		r := m.add(req.GetDictionary())
+		for _, rp := range req.ResourceProfiles {
+			rp = proto.Clone(rp).(*profiles.ResourceProfiles)
+			r.keyValues(rp.GetResource().GetAttributes())
+			for _, sp := range rp.ScopeProfiles {
+				r.keyValues(sp.GetScope().GetAttributes())
+				for _, p := range sp.Profiles {
+					r.profile(p)
+				}
+			}
+			merged.ResourceProfiles = append(merged.ResourceProfiles, rp)
+		}
+	}
+	merged.Dictionary = m.dict
+	return merged
+}
+
+// dictMerger builds a dictionary from the entries of several dictionaries.
+// Entries are deduplicated by their encoding after their references have
+// been rewritten.
+type dictMerger struct {
+	dict       *profiles.ProfilesDictionary
+	strings    map[string]int32
+	attributes map[string]int32
+	mappings   map[string]int32
+	functions  map[string]int32
+	locations  map[string]int32
+	links      map[string]int32
+	stacks     map[string]int32
+}
+
+func newDictMerger() *dictMerger {
+	m := &dictMerger{
+		dict:       &profiles.ProfilesDictionary{},
+		strings:    map[string]int32{},
+		attributes: map[string]int32{},
+		mappings:   map[string]int32{},
+		functions:  map[string]int32{},
+		locations:  map[string]int32{},
+		links:      map[string]int32{},
+		stacks:     map[string]int32{},
+	}
+	// The zero values at index 0 are required by the dictionary conventions.
+	m.str("")
+	intern(&m.dict.AttributeTable, m.attributes, &profiles.KeyValueAndUnit{})
+	intern(&m.dict.MappingTable, m.mappings, &profiles.Mapping{})
+	intern(&m.dict.FunctionTable, m.functions, &profiles.Function{})
+	intern(&m.dict.LocationTable, m.locations, &profiles.Location{})
+	intern(&m.dict.LinkTable, m.links, &profiles.Link{})
+	intern(&m.dict.StackTable, m.stacks, &profiles.Stack{})
+	return m
+}
+
+func (m *dictMerger) str(s string) int32 {
+	if idx, ok := m.strings[s]; ok {
+		return idx
+	}
+	idx := int32(len(m.dict.StringTable))
+	m.dict.StringTable = append(m.dict.StringTable, s)
+	m.strings[s] = idx
+	return idx
+}
+
+// intern returns the index of entry in table, appending it if there is no
+// entry with the same encoding yet.
+func intern[T proto.Message](table *[]T, index map[string]int32, entry T) int32 {
+	key, err := marshalOptions.Marshal(entry)
+	if err != nil {
+		// Entries were decoded from protobuf, so they always encode.
+		panic(fmt.Sprintf("marshal dictionary entry: %v", err))
+	}
+	if idx, ok := index[string(key)]; ok {
+		return idx
+	}
+	idx := int32(len(*table))
+	*table = append(*table, entry)
+	index[string(key)] = idx
+	return idx
+}
+
+// add merges the entries of dict and returns the mapping from its indices to
+// the indices in the merged dictionary. Tables are merged in dependency
+// order, so that every entry's references are rewritten before it is
+// interned.
+func (m *dictMerger) add(dict *profiles.ProfilesDictionary) *dictRemap {
+	r := &dictRemap{}
+	for _, s := range dict.GetStringTable() {
+		r.strings = append(r.strings, m.str(s))
+	}
+	for _, attr := range dict.GetAttributeTable() {
+		attr = proto.Clone(attr).(*profiles.KeyValueAndUnit)
+		attr.KeyStrindex = remap(r.strings, attr.KeyStrindex)
+		attr.UnitStrindex = remap(r.strings, attr.UnitStrindex)
+		r.anyValue(attr.Value)
+		r.attributes = append(r.attributes, intern(&m.dict.AttributeTable, m.attributes, attr))
+	}
+	for _, mapping := range dict.GetMappingTable() {
+		mapping = proto.Clone(mapping).(*profiles.Mapping)
+		mapping.FilenameStrindex = remap(r.strings, mapping.FilenameStrindex)
+		remapAll(r.attributes, mapping.AttributeIndices)
+		r.mappings = append(r.mappings, intern(&m.dict.MappingTable, m.mappings, mapping))
+	}
+	for _, fn := range dict.GetFunctionTable() {
+		fn = proto.Clone(fn).(*profiles.Function)
+		fn.NameStrindex = remap(r.strings, fn.NameStrindex)
+		fn.SystemNameStrindex = remap(r.strings, fn.SystemNameStrindex)
+		fn.FilenameStrindex = remap(r.strings, fn.FilenameStrindex)
+		r.functions = append(r.functions, intern(&m.dict.FunctionTable, m.functions, fn))
+	}
+	for _, loc := range dict.GetLocationTable() {
+		loc = proto.Clone(loc).(*profiles.Location)
+		loc.MappingIndex = remap(r.mappings, loc.MappingIndex)
+		for _, line := range loc.Lines {
+			line.FunctionIndex = remap(r.functions, line.FunctionIndex)
+		}
+		remapAll(r.attributes, loc.AttributeIndices)
+		r.locations = append(r.locations, intern(&m.dict.LocationTable, m.locations, loc))
+	}
+	for _, link := range dict.GetLinkTable() {
+		r.links = append(r.links, intern(&m.dict.LinkTable, m.links, proto.Clone(link).(*profiles.Link)))
+	}
+	for _, stack := range dict.GetStackTable() {
+		stack = proto.Clone(stack).(*profiles.Stack)
+		remapAll(r.locations, stack.LocationIndices)
+		r.stacks = append(r.stacks, intern(&m.dict.StackTable, m.stacks, stack))
+	}
+	return r
+}
+
+// dictRemap maps the indices of one dictionary's tables to the indices of
+// the merged dictionary.
+type dictRemap struct {
+	strings    []int32
+	attributes []int32
+	mappings   []int32
+	functions  []int32
+	locations  []int32
+	links      []int32
+	stacks     []int32
+}
+
+func remap(table []int32, idx int32) int32 {
+	if idx < 0 || int(idx) >= len(table) {
+		return 0
+	}
+	return table[idx]
+}
+
+func remapAll(table []int32, indices []int32) {
+	for i, idx := range indices {
+		indices[i] = remap(table, idx)
+	}
+}
+
+// profile rewrites the references of p, which must be a copy.
+func (r *dictRemap) profile(p *profiles.Profile) {
+	for _, vt := range []*profiles.ValueType{p.SampleType, p.PeriodType} {
+		if vt != nil {
+			vt.TypeStrindex = remap(r.strings, vt.TypeStrindex)
+			vt.UnitStrindex = remap(r.strings, vt.UnitStrindex)
+		}
+	}
+	remapAll(r.attributes, p.AttributeIndices)
+	for _, s := range p.Samples {
+		s.StackIndex = remap(r.stacks, s.StackIndex)
+		s.LinkIndex = remap(r.links, s.LinkIndex)
+		remapAll(r.attributes, s.AttributeIndices)
+	}
+}
+
+// keyValues rewrites the string references of kvs, which must be a copy.
+func (r *dictRemap) keyValues(kvs []*common.KeyValue) {
+	for _, kv := range kvs {
+		if kv.KeyRef != 0 {
+			kv.KeyRef = remap(r.strings, kv.KeyRef)
+		}
+		r.anyValue(kv.Value)
+	}
+}
+
+func (r *dictRemap) anyValue(av *common.AnyValue) {
+	switch v := av.GetValue().(type) {
+	case *common.AnyValue_StringRef:
+		v.StringRef = remap(r.strings, v.StringRef)
+	case *common.AnyValue_ArrayValue:
+		for _, elem := range v.ArrayValue.GetValues() {
+			r.anyValue(elem)
+		}
+	case *common.AnyValue_KvlistValue:
+		r.keyValues(v.KvlistValue.GetValues())
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
+)
+
+func TestMergeRequests(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "k8s.otlp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloads, err := unmarshalOTLP(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &cprofiles.ExportProfilesServiceRequest{
+		Dictionary: &profiles.ProfilesDictionary{
+			StringTable:   []string{"", "cpu", "nanoseconds", "only.in.other"},
+			FunctionTable: []*profiles.Function{{}, {NameStrindex: 3}},
+			LocationTable: []*profiles.Location{{}, {Lines: []*profiles.Line{{FunctionIndex: 1}}}},
+			StackTable:    []*profiles.Stack{{}, {LocationIndices: []int32{1}}},
+		},
+		ResourceProfiles: []*profiles.ResourceProfiles{{
+			ScopeProfiles: []*profiles.ScopeProfiles{{
+				Profiles: []*profiles.Profile{{
+					SampleType: &profiles.ValueType{TypeStrindex: 1, UnitStrindex: 2},
+					Samples:    []*profiles.Sample{{StackIndex: 1, Values: []int64{1}}},
+				}},
+			}},
+		}},
+	}
+
+	merged := mergeRequests([]*cprofiles.ExportProfilesServiceRequest{payloads[0], other, payloads[0]})
+
+	// Merging a payload with itself must not grow the dictionary.
+	assertEqual(t, len(merged.Dictionary.StackTable), len(payloads[0].Dictionary.StackTable)+1)
+	assertEqual(t, len(merged.Dictionary.FunctionTable), len(payloads[0].Dictionary.FunctionTable)+1)
+
+	// References must resolve to the same values as in the inputs.
+	want := resolveRequest(payloads[0]).ResourceProfiles
+	want = append(want, resolveRequest(other).ResourceProfiles...)
+	want = append(want, resolveRequest(payloads[0]).ResourceProfiles...)
+	assertEqual(t, resolveRequest(merged).ResourceProfiles, want)
+}
+
+func TestAppMerge(t *testing.T) {
+	input := filepath.Join("testdata", "k8s.otlp")
+	stdout, _, err := runTestApp(t, []string{"--out", "-", "--merge", input, input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v\n%s\n", err, stdout)
+	}
+	assertEqual(t, len(records), 1+len(strategies))
+	assertEqual(t, records[1][0], mergedFilename)
+	assertEqual(t, records[1][4], "1")
+}
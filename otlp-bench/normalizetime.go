@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
+)
+
+// normalizeTime rebases the timestamps of every profile of the payloads in
+// data so that the profile starts at 0, see --normalize-time. The sample
+// timestamps keep their offset to the profile start. If a sample is
+// timestamped before its profile starts, the earliest sample timestamp
+// becomes 0 instead, so that no timestamp wraps around. The payloads are
+// returned re-encoded in the length-prefixed format.
+func normalizeTime(data []byte, framing string) ([]byte, error) {
+	payloads, err := unmarshalFormat(data, framing)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal gh733 profile: %w", err)
+	}
+	for _, payload := range payloads {
+		for _, rp := range payload.ResourceProfiles {
+			for _, sp := range rp.ScopeProfiles {
+				for _, p := range sp.Profiles {
+					rebaseProfile(p)
+				}
+			}
+		}
+	}
+	return marshalPayloads(payloads, true)
+}
+
+// rebaseProfile subtracts the start of p, or its earliest sample timestamp if
+// that is earlier, from the time of p and all its sample timestamps.
+func rebaseProfile(p *profiles.Profile) {
+	origin := p.TimeUnixNano
+	for _, s := range p.Samples {
+		if len(s.TimestampsUnixNano) > 0 {
+			origin = min(origin, slices.Min(s.TimestampsUnixNano))
+		}
+	}
+	p.TimeUnixNano -= origin
+	for _, s := range p.Samples {
+		for i := range s.TimestampsUnixNano {
+			s.TimestampsUnixNano[i] -= origin
+		}
+	}
+}
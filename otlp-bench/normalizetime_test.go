@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/csv"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
+)
+
+func TestRebaseProfile(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		p    *profiles.Profile
+		want *profiles.Profile
+	}{{
+		desc: "timestamps after start",
+		p: &profiles.Profile{TimeUnixNano: 1000, DurationNano: 50, Samples: []*profiles.Sample{
+			{TimestampsUnixNano: []uint64{1010, 1020}},
+			{Values: []int64{1}},
+		}},
+		want: &profiles.Profile{DurationNano: 50, Samples: []*profiles.Sample{
+			{TimestampsUnixNano: []uint64{10, 20}},
+			{Values: []int64{1}},
+		}},
+	}, {
+		desc: "timestamp before start",
+		p: &profiles.Profile{TimeUnixNano: 1000, Samples: []*profiles.Sample{
+			{TimestampsUnixNano: []uint64{990, 1020}},
+		}},
+		want: &profiles.Profile{TimeUnixNano: 10, Samples: []*profiles.Sample{
+			{TimestampsUnixNano: []uint64{0, 30}},
+		}},
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			rebaseProfile(tc.p)
+			assertEqual(t, tc.p, tc.want)
+		})
+	}
+}
+
+func TestAppNormalizeTime(t *testing.T) {
+	input := filepath.Join("testdata", "k8s.otlp")
+	stdout, _, err := runTestApp(t, []string{"--out", "-", "--normalize-time", input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	normalized := slices.Index(records[0], "time_normalized")
+	for _, record := range records[1:] {
+		if record[normalized] != "true" {
+			t.Errorf("%s: got time_normalized %s, want true", record[1], record[normalized])
+		}
+	}
+
+	outDir := t.TempDir()
+	stdout, _, err = runTestApp(t, []string{"--out", outDir, "--normalize-time", input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout, "time normalized by --normalize-time") {
+		t.Errorf("summary does not mark the normalized sizes:\n%s", stdout)
+	}
+
+	if _, _, err := runTestApp(t, []string{"--out", "-", "--normalize-time", "--proto-version", "upstream", input}); err == nil {
+		t.Error("expected error for --normalize-time with --proto-version=upstream")
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// outTemplateTimestamp is the format of {timestamp} in --out-template. It
+// sorts in chronological order and is valid in file names on all platforms.
+const outTemplateTimestamp = "20060102-150405"
+
+var placeholderRe = regexp.MustCompile(`\{[^{}]*\}`)
+
+// expandOutTemplate returns template with {timestamp} replaced by now in UTC
+// and {label} by label, see --out-template.
+func expandOutTemplate(template, label string, now time.Time) (string, error) {
+	var err error
+	dir := placeholderRe.ReplaceAllStringFunc(template, func(placeholder string) string {
+		switch placeholder {
+		case "{timestamp}":
+			return now.UTC().Format(outTemplateTimestamp)
+		case "{label}":
+			if label == "" {
+				err = errors.New("--out-template uses {label}, but --label is not set")
+			}
+			return label
+		default:
+			err = fmt.Errorf("unknown placeholder %s in --out-template, must be {timestamp} or {label}", placeholder)
+			return placeholder
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	if strings.ContainsRune(label, filepath.Separator) {
+		return "", fmt.Errorf("--label %q must not contain %q", label, filepath.Separator)
+	}
+	return dir, nil
+}
+
+// createUniqueDir creates dir, or dir-2, dir-3 and so on if it exists, so
+// that runs never overwrite the results of an earlier run. It returns the
+// created directory.
+func createUniqueDir(dir string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return "", err
+	}
+	created := dir
+	for n := 2; ; n++ {
+		err := os.Mkdir(created, 0o755)
+		if err == nil {
+			return created, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", err
+		}
+		created = fmt.Sprintf("%s-%d", dir, n)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpandOutTemplate(t *testing.T) {
+	now := time.Date(2026, 3, 4, 5, 6, 7, 0, time.FixedZone("CET", 3600))
+	got, err := expandOutTemplate("results/{timestamp}-{label}", "split", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, got, "results/20260304-040607-split")
+
+	for _, tc := range []struct {
+		template string
+		label    string
+	}{
+		{template: "results/{label}"},
+		{template: "results/{run}", label: "split"},
+		{template: "results/{label}", label: "a" + string(filepath.Separator) + "b"},
+	} {
+		if _, err := expandOutTemplate(tc.template, tc.label, now); err == nil {
+			t.Errorf("expandOutTemplate(%q, %q): expected error", tc.template, tc.label)
+		}
+	}
+}
+
+func TestAppOutTemplate(t *testing.T) {
+	template := filepath.Join(t.TempDir(), "results", "{label}")
+	input := filepath.Join("testdata", "k8s.otlp")
+	for _, dir := range []string{"baseline", "baseline-2"} {
+		if _, _, err := runTestApp(t, []string{"--out-template", template, "--label", "baseline", input}); err != nil {
+			t.Fatal(err)
+		}
+		outDir := filepath.Join(filepath.Dir(template), dir)
+		if _, err := os.Stat(filepath.Join(outDir, "summary.csv")); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(filepath.Join(outDir, "manifest.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, m.Label, "baseline")
+	}
+
+	if _, _, err := runTestApp(t, []string{"--out", t.TempDir(), "--out-template", template, "--label", "x", input}); err == nil {
+		t.Error("expected error for --out with --out-template")
+	}
+	if _, _, err := runTestApp(t, []string{"--out", t.TempDir(), "--label", "x", input}); err == nil {
+		t.Error("expected error for --label without --out-template")
+	}
+}
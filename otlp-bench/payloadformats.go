@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"maps"
+	"slices"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+)
+
+// payloadFormats tallies the original payloads of profiles by their
+// original_payload_format, see --profile-format-breakdown.
+type payloadFormats struct {
+	// formats maps a format, "" if unset, to its profiles and their total
+	// original_payload bytes.
+	formats map[string]*payloadFormat
+	// total is the encoded size of all payloads, which the original
+	// payloads are a part of.
+	total int
+}
+
+type payloadFormat struct {
+	profiles int
+	bytes    int
+}
+
+// add tallies the profiles of data that have an original payload or format.
+func (f *payloadFormats) add(data *cprofiles.ExportProfilesServiceRequest) {
+	if f.formats == nil {
+		f.formats = map[string]*payloadFormat{}
+	}
+	f.total += marshalOptions.Size(data)
+	for _, rp := range data.GetResourceProfiles() {
+		for _, sp := range rp.GetScopeProfiles() {
+			for _, p := range sp.GetProfiles() {
+				if len(p.GetOriginalPayload()) == 0 && p.GetOriginalPayloadFormat() == "" {
+					continue
+				}
+				pf := f.formats[p.GetOriginalPayloadFormat()]
+				if pf == nil {
+					pf = &payloadFormat{}
+					f.formats[p.GetOriginalPayloadFormat()] = pf
+				}
+				pf.profiles++
+				pf.bytes += len(p.GetOriginalPayload())
+			}
+		}
+	}
+}
+
+// writePayloadFormats writes a row per format of f for file, sorted by
+// format, with the fraction of the payload bytes that are original payloads
+// of the format.
+func writePayloadFormats(csvWriter *csv.Writer, file string, f *payloadFormats) error {
+	for _, format := range slices.Sorted(maps.Keys(f.formats)) {
+		pf := f.formats[format]
+		fraction := 0.0
+		if f.total > 0 {
+			fraction = float64(pf.bytes) / float64(f.total)
+		}
+		if err := csvWriter.Write([]string{
+			file,
+			format,
+			fmt.Sprintf("%d", pf.profiles),
+			fmt.Sprintf("%d", pf.bytes),
+			fmt.Sprintf("%.4f", fraction),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestAppProfileFormatBreakdown(t *testing.T) {
+	req := minimalRequest()
+	sp := req.ResourceProfiles[0].ScopeProfiles[0]
+	template := sp.Profiles[0]
+	for _, p := range []struct {
+		format  string
+		payload string
+	}{{"pprof", "abcd"}, {"jfr", "abcdefgh"}, {"pprof", "ab"}} {
+		prof := proto.CloneOf(template)
+		prof.OriginalPayloadFormat = p.format
+		prof.OriginalPayload = []byte(p.payload)
+		sp.Profiles = append(sp.Profiles, prof)
+	}
+	data, err := marshalOptions.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := filepath.Join(t.TempDir(), "original.otlp")
+	if err := os.WriteFile(input, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outDir := filepath.Join(t.TempDir(), "out")
+	// split-by-process rejects profiles with an original payload, so the
+	// sizes can't be measured, but the breakdown is written before.
+	_, _, err = runTestApp(t, []string{"--out", outDir, "--profile-format-breakdown", input})
+	if err == nil || !strings.Contains(err.Error(), "original payload is not supported") {
+		t.Fatalf("got error %v, want split-by-process to reject the original payloads", err)
+	}
+	f, err := os.Open(filepath.Join(outDir, "payload_formats.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fraction := func(bytes int) string {
+		return fmt.Sprintf("%.4f", float64(bytes)/float64(len(data)))
+	}
+	assertEqual(t, records, [][]string{
+		{"file", "format", "profiles", "bytes", "payload_fraction"},
+		{input, "jfr", "1", "8", fraction(8)},
+		{input, "pprof", "2", "6", fraction(6)},
+	})
+
+	// Profiles without an original payload are not counted.
+	var empty payloadFormats
+	empty.add(minimalRequest())
+	assertEqual(t, len(empty.formats), 0)
+
+	if _, _, err := runTestApp(t, []string{"--out", "-", "--profile-format-breakdown", input}); err == nil {
+		t.Error("expected error for --profile-format-breakdown with --out=-")
+	}
+}
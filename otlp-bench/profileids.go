@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+)
+
+// profileIDs records where every non-empty profile_id of the input occurs,
+// see --profile-ids-unique. Duplicates usually are retransmits or an
+// exporter that doesn't generate a new ID for every profile.
+type profileIDs struct {
+	locations map[string][]string
+	// order are the IDs in the order of their first occurrence.
+	order []string
+}
+
+// add records the profile IDs of payloads, which were read from file.
+func (p *profileIDs) add(file string, payloads []*cprofiles.ExportProfilesServiceRequest) {
+	if p.locations == nil {
+		p.locations = map[string][]string{}
+	}
+	for i, payload := range payloads {
+		for j, rp := range payload.GetResourceProfiles() {
+			for k, sp := range rp.GetScopeProfiles() {
+				for l, prof := range sp.GetProfiles() {
+					if len(prof.GetProfileId()) == 0 {
+						continue
+					}
+					id := hex.EncodeToString(prof.GetProfileId())
+					if _, ok := p.locations[id]; !ok {
+						p.order = append(p.order, id)
+					}
+					p.locations[id] = append(p.locations[id], fmt.Sprintf("%s: payload[%d].resource_profiles[%d].scope_profiles[%d].profiles[%d]", file, i, j, k, l))
+				}
+			}
+		}
+	}
+}
+
+// writeDuplicates writes a warning for every profile ID that occurs more
+// than once, with where it occurs. It returns the number of duplicated IDs.
+func (p *profileIDs) writeDuplicates(out io.Writer) int {
+	duplicates := 0
+	for _, id := range p.order {
+		locations := p.locations[id]
+		if len(locations) < 2 {
+			continue
+		}
+		duplicates++
+		fmt.Fprintf(out, "warning: profile_id %s occurs %d times:\n  %s\n", id, len(locations), strings.Join(locations, "\n  "))
+	}
+	return duplicates
+}
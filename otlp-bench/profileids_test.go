@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestProfileIDs(t *testing.T) {
+	withIDs := func(ids ...string) *cprofiles.ExportProfilesServiceRequest {
+		req := minimalRequest()
+		sp := req.ResourceProfiles[0].ScopeProfiles[0]
+		template := sp.Profiles[0]
+		sp.Profiles = nil
+		for _, id := range ids {
+			prof := proto.CloneOf(template)
+			prof.ProfileId = []byte(id)
+			sp.Profiles = append(sp.Profiles, prof)
+		}
+		return req
+	}
+	var ids profileIDs
+	ids.add("a.otlp", []*cprofiles.ExportProfilesServiceRequest{withIDs("\x01", "", "\x02"), withIDs("\x03", "")})
+	ids.add("b.otlp", []*cprofiles.ExportProfilesServiceRequest{withIDs("", "\x02", "\x01", "\x01")})
+
+	var out strings.Builder
+	assertEqual(t, ids.writeDuplicates(&out), 2)
+	assertEqual(t, out.String(), `warning: profile_id 01 occurs 3 times:
+  a.otlp: payload[0].resource_profiles[0].scope_profiles[0].profiles[0]
+  b.otlp: payload[0].resource_profiles[0].scope_profiles[0].profiles[2]
+  b.otlp: payload[0].resource_profiles[0].scope_profiles[0].profiles[3]
+warning: profile_id 02 occurs 2 times:
+  a.otlp: payload[0].resource_profiles[0].scope_profiles[0].profiles[2]
+  b.otlp: payload[0].resource_profiles[0].scope_profiles[0].profiles[1]
+`)
+}
+
+func TestAppProfileIDsUnique(t *testing.T) {
+	req := minimalRequest()
+	req.ResourceProfiles[0].ScopeProfiles[0].Profiles[0].ProfileId = []byte{0xab, 0xcd}
+	data, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	var files []string
+	for _, name := range []string{"a.otlp", "b.otlp"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+
+	_, stderr, err := runTestApp(t, append([]string{"--out", "-", "--profile-ids-unique"}, files...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stderr, "warning: profile_id abcd occurs 2 times") {
+		t.Errorf("got stderr %q, want a warning about the duplicate profile ID", stderr)
+	}
+	_, stderr, err = runTestApp(t, []string{"--out", "-", "--profile-ids-unique", files[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stderr, "all profile IDs are unique") {
+		t.Errorf("got stderr %q, want all profile IDs to be unique", stderr)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
+	"google.golang.org/protobuf/proto"
+)
+
+// splitByProfileID returns a copy of data in which every scope profiles holds
+// the profiles of a single profile ID, so that the logical profiles a producer
+// interleaved in one scope are de-interleaved, as a collector routing or
+// batching by profile ID would. The profiles of an ID within a scope are
+// merged into one if they only differ in their samples and time range, see
+// mergeableProfiles. The dictionary is shared with data.
+func splitByProfileID(data *cprofiles.ExportProfilesServiceRequest) (*cprofiles.ExportProfilesServiceRequest, error) {
+	newProfile := &cprofiles.ExportProfilesServiceRequest{
+		Dictionary:       data.Dictionary,
+		ResourceProfiles: make([]*profiles.ResourceProfiles, len(data.ResourceProfiles)),
+	}
+	for ri, rp := range data.ResourceProfiles {
+		newRp := &profiles.ResourceProfiles{
+			Resource:  rp.Resource,
+			SchemaUrl: rp.SchemaUrl,
+		}
+		newProfile.ResourceProfiles[ri] = newRp
+		for _, sp := range rp.ScopeProfiles {
+			scopeIdx := map[string]*profiles.ScopeProfiles{}
+			for _, p := range sp.Profiles {
+				idHash := hash(string(p.ProfileId))
+				newSp, ok := scopeIdx[idHash]
+				if !ok {
+					newSp = &profiles.ScopeProfiles{
+						Scope:     sp.Scope,
+						SchemaUrl: sp.SchemaUrl,
+					}
+					scopeIdx[idHash] = newSp
+					newRp.ScopeProfiles = append(newRp.ScopeProfiles, newSp)
+				}
+				if n := len(newSp.Profiles); n > 0 && mergeableProfiles(newSp.Profiles[n-1], p) {
+					mergeProfile(newSp.Profiles[n-1], p)
+					continue
+				}
+				newSp.Profiles = append(newSp.Profiles, proto.CloneOf(p))
+			}
+		}
+	}
+	return newProfile, nil
+}
+
+// mergeableProfiles reports whether the samples of b can be appended to a:
+// neither has an original payload and all fields but the samples and the time
+// range are equal.
+func mergeableProfiles(a, b *profiles.Profile) bool {
+	if a.OriginalPayload != nil || b.OriginalPayload != nil {
+		return false
+	}
+	headerOf := func(p *profiles.Profile) *profiles.Profile {
+		return &profiles.Profile{
+			SampleType:             p.SampleType,
+			PeriodType:             p.PeriodType,
+			Period:                 p.Period,
+			ProfileId:              p.ProfileId,
+			DroppedAttributesCount: p.DroppedAttributesCount,
+			OriginalPayloadFormat:  p.OriginalPayloadFormat,
+			AttributeIndices:       p.AttributeIndices,
+		}
+	}
+	return proto.Equal(headerOf(a), headerOf(b))
+}
+
+// mergeProfile appends the samples of p to dst, which must be a copy, and
+// widens the time range of dst to cover that of p.
+func mergeProfile(dst, p *profiles.Profile) {
+	dst.Samples = append(dst.Samples, p.Samples...)
+	start := min(dst.TimeUnixNano, p.TimeUnixNano)
+	end := max(dst.TimeUnixNano+dst.DurationNano, p.TimeUnixNano+p.DurationNano)
+	dst.TimeUnixNano, dst.DurationNano = start, end-start
+}
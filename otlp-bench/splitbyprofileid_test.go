@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSplitByProfileID(t *testing.T) {
+	data := minimalRequest()
+	sp := data.ResourceProfiles[0].ScopeProfiles[0]
+	idA, idB := []byte{0xa}, []byte{0xb}
+	sp.Profiles = []*profiles.Profile{
+		{ProfileId: idA, TimeUnixNano: 100, DurationNano: 10, Samples: []*profiles.Sample{{Values: []int64{1}}}},
+		{ProfileId: idB, TimeUnixNano: 100, DurationNano: 10, Samples: []*profiles.Sample{{Values: []int64{2}}}},
+		{ProfileId: idA, TimeUnixNano: 120, DurationNano: 10, Samples: []*profiles.Sample{{Values: []int64{3}}}},
+		// A different period keeps it from being merged into the first.
+		{ProfileId: idA, Period: 5, Samples: []*profiles.Sample{{Values: []int64{4}}}},
+	}
+	input := proto.Clone(data).(*cprofiles.ExportProfilesServiceRequest)
+
+	got, err := splitByProfileID(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, data, input)
+
+	want := proto.Clone(input).(*cprofiles.ExportProfilesServiceRequest)
+	want.ResourceProfiles[0].ScopeProfiles = []*profiles.ScopeProfiles{{
+		Profiles: []*profiles.Profile{
+			{ProfileId: idA, TimeUnixNano: 100, DurationNano: 30, Samples: []*profiles.Sample{{Values: []int64{1}}, {Values: []int64{3}}}},
+			{ProfileId: idA, Period: 5, Samples: []*profiles.Sample{{Values: []int64{4}}}},
+		},
+	}, {
+		Profiles: []*profiles.Profile{
+			{ProfileId: idB, TimeUnixNano: 100, DurationNano: 10, Samples: []*profiles.Sample{{Values: []int64{2}}}},
+		},
+	}}
+	assertEqual(t, got, want)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	common "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/common/v1"
+)
+
+// maxStringDisplayLen is the length at which strings are truncated in
+// strings.txt.
+const maxStringDisplayLen = 120
+
+// stringStat is a string table entry with the number of references to it.
+type stringStat struct {
+	index int
+	value string
+	refs  int
+}
+
+// stringStats returns the string table entries of data with the number of
+// references to each of them from the dictionary and the resource profiles.
+func stringStats(data *cprofiles.ExportProfilesServiceRequest) []stringStat {
+	dict := data.GetDictionary()
+	stats := make([]stringStat, len(dict.GetStringTable()))
+	for i, s := range dict.GetStringTable() {
+		stats[i] = stringStat{index: i, value: s}
+	}
+	// References to the empty string at index 0 mean "unset" and are not
+	// counted.
+	ref := func(idx int32) {
+		if idx > 0 && int(idx) < len(stats) {
+			stats[idx].refs++
+		}
+	}
+	var refKeyValues func(attrs []*common.KeyValue)
+	var refAnyValue func(av *common.AnyValue)
+	refKeyValues = func(attrs []*common.KeyValue) {
+		for _, kv := range attrs {
+			if kv.GetKeyRef() != 0 {
+				ref(kv.GetKeyRef())
+			}
+			refAnyValue(kv.GetValue())
+		}
+	}
+	refAnyValue = func(av *common.AnyValue) {
+		switch v := av.GetValue().(type) {
+		case *common.AnyValue_StringRef:
+			ref(v.StringRef)
+		case *common.AnyValue_ArrayValue:
+			for _, elem := range v.ArrayValue.GetValues() {
+				refAnyValue(elem)
+			}
+		case *common.AnyValue_KvlistValue:
+			refKeyValues(v.KvlistValue.GetValues())
+		}
+	}
+
+	for _, m := range dict.GetMappingTable() {
+		ref(m.GetFilenameStrindex())
+	}
+	for _, f := range dict.GetFunctionTable() {
+		ref(f.GetNameStrindex())
+		ref(f.GetSystemNameStrindex())
+		ref(f.GetFilenameStrindex())
+	}
+	for _, attr := range dict.GetAttributeTable() {
+		ref(attr.GetKeyStrindex())
+		ref(attr.GetUnitStrindex())
+		refAnyValue(attr.GetValue())
+	}
+	for _, rp := range data.GetResourceProfiles() {
+		refKeyValues(rp.GetResource().GetAttributes())
+		for _, sp := range rp.GetScopeProfiles() {
+			refKeyValues(sp.GetScope().GetAttributes())
+			for _, p := range sp.GetProfiles() {
+				ref(p.GetSampleType().GetTypeStrindex())
+				ref(p.GetSampleType().GetUnitStrindex())
+				ref(p.GetPeriodType().GetTypeStrindex())
+				ref(p.GetPeriodType().GetUnitStrindex())
+			}
+		}
+	}
+	return stats
+}
+
+// writeTopStrings writes the n most referenced and the n longest strings of
+// every payload to strings.txt in outDir.
+func writeTopStrings(outDir, file string, payloads []*cprofiles.ExportProfilesServiceRequest, n int) error {
+	outPath := filepath.Join(outDir, "strings.txt")
+	f, err := os.OpenFile(outPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open file %q: %w", outPath, err)
+	}
+	defer f.Close()
+	for i, payload := range payloads {
+		printTopStrings(f, fmt.Sprintf("%s payload[%d]", file, i), stringStats(payload), n)
+	}
+	return nil
+}
+
+func printTopStrings(out io.Writer, title string, stats []stringStat, n int) {
+	fmt.Fprintf(out, "%s: %d strings\n", title, len(stats))
+
+	// Sort stably by index first so that ties are listed in table order.
+	byRefs := slices.Clone(stats)
+	slices.SortStableFunc(byRefs, func(a, b stringStat) int { return cmp.Compare(b.refs, a.refs) })
+	fmt.Fprintf(out, "  most referenced:\n")
+	for _, s := range byRefs[:min(n, len(byRefs))] {
+		fmt.Fprintf(out, "    refs=%d len=%d index=%d %s\n", s.refs, len(s.value), s.index, displayString(s.value))
+	}
+
+	byLen := slices.Clone(stats)
+	slices.SortStableFunc(byLen, func(a, b stringStat) int { return cmp.Compare(len(b.value), len(a.value)) })
+	fmt.Fprintf(out, "  longest:\n")
+	for _, s := range byLen[:min(n, len(byLen))] {
+		fmt.Fprintf(out, "    len=%d refs=%d index=%d %s\n", len(s.value), s.refs, s.index, displayString(s.value))
+	}
+}
+
+// displayString quotes s, truncating it to maxStringDisplayLen bytes.
+func displayString(s string) string {
+	if len(s) <= maxStringDisplayLen {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%q...", s[:maxStringDisplayLen])
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	common "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/common/v1"
+	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
+	resource "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/resource/v1"
+)
+
+func TestStringStats(t *testing.T) {
+	data := &cprofiles.ExportProfilesServiceRequest{
+		Dictionary: &profiles.ProfilesDictionary{
+			StringTable:   []string{"", "main", "main.go", "service.name", strings.Repeat("x", 200)},
+			FunctionTable: []*profiles.Function{{}, {NameStrindex: 1, FilenameStrindex: 2}, {NameStrindex: 1, FilenameStrindex: 2}},
+		},
+		ResourceProfiles: []*profiles.ResourceProfiles{{
+			Resource: &resource.Resource{
+				Attributes: []*common.KeyValue{{
+					KeyRef: 3,
+					Value:  &common.AnyValue{Value: &common.AnyValue_StringRef{StringRef: 4}},
+				}},
+			},
+		}},
+	}
+	stats := stringStats(data)
+	var refs []int
+	for _, s := range stats {
+		refs = append(refs, s.refs)
+	}
+	assertEqual(t, refs, []int{0, 2, 2, 1, 1})
+
+	var buf bytes.Buffer
+	printTopStrings(&buf, "test", stats, 1)
+	want := "test: 5 strings\n" +
+		"  most referenced:\n" +
+		"    refs=2 len=4 index=1 \"main\"\n" +
+		"  longest:\n" +
+		"    len=200 refs=1 index=4 \"" + strings.Repeat("x", maxStringDisplayLen) + "\"...\n"
+	assertEqual(t, buf.String(), want)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	common "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/common/v1"
+	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
+	"google.golang.org/protobuf/proto"
+)
+
+// stripAttributes returns a copy of data without the attributes whose key is
+// in keys, wherever they occur: in the resource and scope attributes and in
+// the attribute_indices of profiles, samples, locations and mappings.
+// Attribute table entries and strings that are no longer referenced are
+// dropped from the dictionary, so the size difference to the baseline is the
+// full cost of the attributes.
+func stripAttributes(data *cprofiles.ExportProfilesServiceRequest, keys map[string]bool) (*cprofiles.ExportProfilesServiceRequest, error) {
+	if data.Dictionary == nil {
+		return nil, errMissingDictionary
+	}
+	out := proto.Clone(data).(*cprofiles.ExportProfilesServiceRequest)
+	dict := out.Dictionary
+
+	stripIndex := func(idx int32) bool {
+		attr := at(dict.AttributeTable, idx)
+		return attr != nil && keys[dictString(dict, attr.KeyStrindex)]
+	}
+	stripKeyValue := func(kv *common.KeyValue) bool {
+		key := kv.Key
+		if kv.KeyRef != 0 {
+			key = dictString(dict, kv.KeyRef)
+		}
+		return keys[key]
+	}
+	for _, rp := range out.ResourceProfiles {
+		if rp.Resource != nil {
+			rp.Resource.Attributes = slices.DeleteFunc(rp.Resource.Attributes, stripKeyValue)
+		}
+		for _, sp := range rp.ScopeProfiles {
+			if sp.Scope != nil {
+				sp.Scope.Attributes = slices.DeleteFunc(sp.Scope.Attributes, stripKeyValue)
+			}
+			for _, p := range sp.Profiles {
+				p.AttributeIndices = slices.DeleteFunc(p.AttributeIndices, stripIndex)
+				for _, s := range p.Samples {
+					s.AttributeIndices = slices.DeleteFunc(s.AttributeIndices, stripIndex)
+				}
+			}
+		}
+	}
+	for _, loc := range dict.LocationTable {
+		loc.AttributeIndices = slices.DeleteFunc(loc.AttributeIndices, stripIndex)
+	}
+	for _, m := range dict.MappingTable {
+		m.AttributeIndices = slices.DeleteFunc(m.AttributeIndices, stripIndex)
+	}
+
+	if err := pruneDictionary(out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// pruneDictionary drops the attribute table entries and strings of data that
+// are not referenced and rewrites the references to the remaining ones. The
+// zero value entries at index 0 are kept.
+func pruneDictionary(data *cprofiles.ExportProfilesServiceRequest) error {
+	dict := data.Dictionary
+	attrUsed := make([]bool, len(dict.AttributeTable))
+	if len(attrUsed) > 0 {
+		attrUsed[0] = true
+	}
+	var err error
+	markAttrs := func(indices []int32) {
+		for _, idx := range indices {
+			if idx < 0 || int(idx) >= len(attrUsed) {
+				err = fmt.Errorf("attribute index %d is out of range", idx)
+				continue
+			}
+			attrUsed[idx] = true
+		}
+	}
+	for _, rp := range data.ResourceProfiles {
+		for _, sp := range rp.ScopeProfiles {
+			for _, p := range sp.Profiles {
+				markAttrs(p.AttributeIndices)
+				for _, s := range p.Samples {
+					markAttrs(s.AttributeIndices)
+				}
+			}
+		}
+	}
+	for _, loc := range dict.LocationTable {
+		markAttrs(loc.AttributeIndices)
+	}
+	for _, m := range dict.MappingTable {
+		markAttrs(m.AttributeIndices)
+	}
+	if err != nil {
+		return err
+	}
+
+	keep := &reachability{
+		attributes: attrUsed,
+		strings:    make([]bool, len(dict.StringTable)),
+		mappings:   allEntries(len(dict.MappingTable)),
+		functions:  allEntries(len(dict.FunctionTable)),
+		locations:  allEntries(len(dict.LocationTable)),
+		links:      allEntries(len(dict.LinkTable)),
+		stacks:     allEntries(len(dict.StackTable)),
+	}
+	// The string references are counted without the dropped attributes, so
+	// that strings only used by them are unreferenced.
+	kept, _ := keepEntries(dict.AttributeTable, attrUsed)
+	view := &cprofiles.ExportProfilesServiceRequest{
+		Dictionary: &profiles.ProfilesDictionary{
+			MappingTable:   dict.MappingTable,
+			FunctionTable:  dict.FunctionTable,
+			StringTable:    dict.StringTable,
+			AttributeTable: kept,
+		},
+		ResourceProfiles: data.ResourceProfiles,
+	}
+	for i, stat := range stringStats(view) {
+		keep.strings[i] = i == 0 || stat.refs > 0
+	}
+	compactDictionary(data, keep)
+	return nil
+}
+
+// compactDictionary drops the dictionary entries of data that are not marked
+// in keep and rewrites all references to the remaining ones. References to
+// dropped entries become 0.
+func compactDictionary(data *cprofiles.ExportProfilesServiceRequest, keep *reachability) {
+	dict := data.Dictionary
+	r := &dictRemap{}
+	dict.StringTable, r.strings = keepEntries(dict.StringTable, keep.strings)
+	dict.AttributeTable, r.attributes = keepEntries(dict.AttributeTable, keep.attributes)
+	dict.MappingTable, r.mappings = keepEntries(dict.MappingTable, keep.mappings)
+	dict.FunctionTable, r.functions = keepEntries(dict.FunctionTable, keep.functions)
+	dict.LocationTable, r.locations = keepEntries(dict.LocationTable, keep.locations)
+	dict.LinkTable, r.links = keepEntries(dict.LinkTable, keep.links)
+	dict.StackTable, r.stacks = keepEntries(dict.StackTable, keep.stacks)
+	r.apply(data)
+}
+
+// apply rewrites all references in data, whose dictionary tables must
+// already be in their new order.
+func (r *dictRemap) apply(data *cprofiles.ExportProfilesServiceRequest) {
+	dict := data.Dictionary
+	for _, m := range dict.MappingTable {
+		m.FilenameStrindex = remap(r.strings, m.FilenameStrindex)
+		remapAll(r.attributes, m.AttributeIndices)
+	}
+	for _, fn := range dict.FunctionTable {
+		fn.NameStrindex = remap(r.strings, fn.NameStrindex)
+		fn.SystemNameStrindex = remap(r.strings, fn.SystemNameStrindex)
+		fn.FilenameStrindex = remap(r.strings, fn.FilenameStrindex)
+	}
+	for _, loc := range dict.LocationTable {
+		loc.MappingIndex = remap(r.mappings, loc.MappingIndex)
+		for _, line := range loc.Lines {
+			line.FunctionIndex = remap(r.functions, line.FunctionIndex)
+		}
+		remapAll(r.attributes, loc.AttributeIndices)
+	}
+	for _, stack := range dict.StackTable {
+		remapAll(r.locations, stack.LocationIndices)
+	}
+	for _, attr := range dict.AttributeTable {
+		attr.KeyStrindex = remap(r.strings, attr.KeyStrindex)
+		attr.UnitStrindex = remap(r.strings, attr.UnitStrindex)
+		r.anyValue(attr.Value)
+	}
+	for _, rp := range data.ResourceProfiles {
+		r.keyValues(rp.GetResource().GetAttributes())
+		for _, sp := range rp.ScopeProfiles {
+			r.keyValues(sp.GetScope().GetAttributes())
+			for _, p := range sp.Profiles {
+				r.profile(p)
+			}
+		}
+	}
+}
+
+// keepEntries returns the entries of table that are kept and the mapping
+// from old to new indices. Dropped entries map to 0.
+func keepEntries[T any](table []T, keep []bool) ([]T, []int32) {
+	var kept []T
+	indices := make([]int32, len(table))
+	for i, entry := range table {
+		if keep[i] {
+			indices[i] = int32(len(kept))
+			kept = append(kept, entry)
+		}
+	}
+	return kept, indices
+}
+
+// allEntries returns a keep mask for a table of n entries that keeps all of
+// them.
+func allEntries(n int) []bool {
+	keep := make([]bool, n)
+	for i := range keep {
+		keep[i] = true
+	}
+	return keep
+}
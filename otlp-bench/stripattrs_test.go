@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/csv"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	common "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/common/v1"
+	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
+	resource "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestStripAttributes(t *testing.T) {
+	data := &cprofiles.ExportProfilesServiceRequest{
+		Dictionary: &profiles.ProfilesDictionary{
+			StringTable: []string{"", "thread.name", "main", "k8s.pod.uid", "abc", "cpu"},
+			AttributeTable: []*profiles.KeyValueAndUnit{
+				{},
+				{KeyStrindex: 1, Value: &common.AnyValue{Value: &common.AnyValue_StringRef{StringRef: 2}}},
+				{KeyStrindex: 3, Value: &common.AnyValue{Value: &common.AnyValue_StringRef{StringRef: 4}}},
+			},
+		},
+		ResourceProfiles: []*profiles.ResourceProfiles{{
+			Resource: &resource.Resource{Attributes: []*common.KeyValue{
+				{KeyRef: 3, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "abc"}}},
+				{Key: "service.name", Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "api"}}},
+			}},
+			ScopeProfiles: []*profiles.ScopeProfiles{{
+				Profiles: []*profiles.Profile{{
+					SampleType: &profiles.ValueType{TypeStrindex: 5},
+					Samples:    []*profiles.Sample{{Values: []int64{1}, AttributeIndices: []int32{2, 1}}},
+				}},
+			}},
+		}},
+	}
+	input := proto.Clone(data)
+
+	got, err := stripAttributes(data, map[string]bool{"k8s.pod.uid": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &cprofiles.ExportProfilesServiceRequest{
+		Dictionary: &profiles.ProfilesDictionary{
+			StringTable: []string{"", "thread.name", "main", "cpu"},
+			AttributeTable: []*profiles.KeyValueAndUnit{
+				{},
+				{KeyStrindex: 1, Value: &common.AnyValue{Value: &common.AnyValue_StringRef{StringRef: 2}}},
+			},
+		},
+		ResourceProfiles: []*profiles.ResourceProfiles{{
+			Resource: &resource.Resource{Attributes: []*common.KeyValue{
+				{Key: "service.name", Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "api"}}},
+			}},
+			ScopeProfiles: []*profiles.ScopeProfiles{{
+				Profiles: []*profiles.Profile{{
+					SampleType: &profiles.ValueType{TypeStrindex: 3},
+					Samples:    []*profiles.Sample{{Values: []int64{1}, AttributeIndices: []int32{1}}},
+				}},
+			}},
+		}},
+	}
+	assertEqual(t, got, want)
+	assertEqual(t, data, input)
+}
+
+func TestAppStripKeys(t *testing.T) {
+	stdout, _, err := runTestApp(t, []string{"--out", "-", "--strip-keys", "thread.name", filepath.Join("testdata", "k8s.otlp")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, len(records), 1+len(strategies)+1)
+	last := records[len(records)-1]
+	assertEqual(t, last[1:4], []string{"strip-attrs", benchProtoVersion, "true"})
+
+	if _, _, err := runTestApp(t, []string{"--out", "-", "--proto-version", "upstream", "--strip-keys", "thread.name", filepath.Join("testdata", "k8s.otlp")}); err == nil {
+		t.Error("expected error for --strip-keys with a proto version the strategies can't transform")
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// outFormats are the supported --out-format values.
+var outFormats = []string{"csv", "parquet"}
+
+// summaryColumns returns the column names of the summary, in order.
+func summaryColumns() []string {
+	columns := append([]string{"file", "encoding", "proto_version", "lossy", "payloads"}, sizeColumns...)
+	columns = append(columns, "sha256")
+	columns = append(columns, changeColumns...)
+	columns = append(columns, savedColumns...)
+	return append(columns, "bloat_score", "limited", "time_normalized", "conformance")
+}
+
+// summaryWriter writes the summary of a benchmark run, one row per file and
+// encoding. The size changes are relative to baseline, the size of the
+// baseline encoding of the file.
+type summaryWriter interface {
+	WriteRow(file string, es encodingSize, baseline profileSize, payloads int) error
+	// Close flushes buffered rows. It does not close the underlying writer.
+	Close() error
+}
+
+// newSummaryWriter returns a summaryWriter for format writing to w.
+func newSummaryWriter(w io.Writer, format string) (summaryWriter, error) {
+	switch format {
+	case "csv":
+		csvWriter := csv.NewWriter(w)
+		if err := csvWriter.Write(summaryColumns()); err != nil {
+			return nil, fmt.Errorf("write header row: %w", err)
+		}
+		return &csvSummaryWriter{w: csvWriter}, nil
+	case "parquet":
+		return newParquetSummaryWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q, must be one of %s", format, strings.Join(outFormats, ", "))
+	}
+}
+
+// summaryFilename returns the name of the summary file for format.
+func summaryFilename(format string) string {
+	return "summary." + format
+}
+
+type csvSummaryWriter struct {
+	w *csv.Writer
+}
+
+func (s *csvSummaryWriter) WriteRow(file string, es encodingSize, baseline profileSize, payloads int) error {
+	if err := writeRow(s.w, file, es, baseline, payloads); err != nil {
+		return err
+	}
+	// Flush after every row so that partial results survive a failure.
+	s.w.Flush()
+	return s.w.Error()
+}
+
+func (s *csvSummaryWriter) Close() error {
+	s.w.Flush()
+	if err := s.w.Error(); err != nil {
+		return fmt.Errorf("flush csv: %w", err)
+	}
+	return nil
+}
+
+// parquetSummaryWriter writes the summary columns with their natural types,
+// so that sizes load as integers rather than strings.
+type parquetSummaryWriter struct {
+	w *parquet.Writer
+}
+
+func newParquetSummaryWriter(w io.Writer) *parquetSummaryWriter {
+	group := parquet.Group{
+		"file":          parquet.String(),
+		"encoding":      parquet.String(),
+		"proto_version": parquet.String(),
+		"lossy":         parquet.Leaf(parquet.BooleanType),
+		"payloads":      parquet.Int(64),
+		"sha256":        parquet.String(),
+	}
+	for _, col := range sizeColumns {
+		group[col] = parquet.Int(64)
+	}
+	for i, col := range changeColumns {
+		if i%2 == 0 {
+			group[col] = parquet.Int(64)
+		} else {
+			// The percentage is null if the baseline size is zero.
+			group[col] = parquet.Optional(parquet.Leaf(parquet.DoubleType))
+		}
+	}
+	for _, col := range savedColumns {
+		group[col] = parquet.Int(64)
+	}
+	// The score is null if it is not computed for the proto version or the
+	// dictionaries are empty.
+	group["bloat_score"] = parquet.Optional(parquet.Leaf(parquet.DoubleType))
+	group["limited"] = parquet.Leaf(parquet.BooleanType)
+	group["time_normalized"] = parquet.Leaf(parquet.BooleanType)
+	// The profcheck result is null for strategies that aren't verified.
+	group["conformance"] = parquet.Optional(parquet.String())
+	schema := parquet.NewSchema("summary", group)
+	return &parquetSummaryWriter{w: parquet.NewWriter(w, schema)}
+}
+
+func (s *parquetSummaryWriter) WriteRow(file string, es encodingSize, baseline profileSize, payloads int) error {
+	row := map[string]any{
+		"file":            file,
+		"encoding":        es.encoding,
+		"proto_version":   es.protoVersion,
+		"lossy":           es.lossy,
+		"payloads":        int64(payloads),
+		"sha256":          es.sha256,
+		"limited":         es.limited,
+		"time_normalized": es.timeNormalized,
+	}
+	for i, v := range es.size.values() {
+		row[sizeColumns[i]] = int64(v)
+	}
+	for i, c := range sizeChanges(baseline, es.size) {
+		row[changeColumns[2*i]] = int64(c.delta)
+		if !math.IsNaN(c.pct) {
+			row[changeColumns[2*i+1]] = c.pct
+		}
+	}
+	for i, v := range es.size.saved() {
+		row[savedColumns[i]] = int64(v)
+	}
+	if score := es.bloat.score(); !math.IsNaN(score) {
+		row["bloat_score"] = score
+	}
+	if result := es.conformance.result(); result != "" {
+		row["conformance"] = result
+	}
+	if err := s.w.Write(row); err != nil {
+		return fmt.Errorf("write parquet row: %w", err)
+	}
+	return nil
+}
+
+func (s *parquetSummaryWriter) Close() error {
+	if err := s.w.Close(); err != nil {
+		return fmt.Errorf("close parquet writer: %w", err)
+	}
+	return nil
+}
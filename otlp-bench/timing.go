@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// timedCodecs are the compression codecs timed with --timing-runs, in the
+// order of their sizeColumns.
+var timedCodecs = []struct {
+	name     string
+	compress func([]byte) ([]byte, error)
+}{
+	{name: "gzip_6", compress: gzipCompress},
+	{name: "zstd_3", compress: func(data []byte) ([]byte, error) {
+		return zstdEncoder.EncodeAll(data, nil), nil
+	}},
+}
+
+// codecTimings holds the duration of every timed run of each codec in
+// timedCodecs, summed over all payloads of a file.
+type codecTimings [][]time.Duration
+
+// add compresses data warmup times with every codec and then runs times,
+// adding the duration of each timed run to t. The codecs run one after the
+// other, unlike in encodePayload, so that they don't compete for the CPU.
+func (t *codecTimings) add(data []byte, warmup, runs int) error {
+	if *t == nil {
+		*t = make(codecTimings, len(timedCodecs))
+		for i := range *t {
+			(*t)[i] = make([]time.Duration, runs)
+		}
+	}
+	for i, codec := range timedCodecs {
+		for range warmup {
+			if _, err := codec.compress(data); err != nil {
+				return fmt.Errorf("compress %s: %w", codec.name, err)
+			}
+		}
+		for run := range runs {
+			start := time.Now()
+			if _, err := codec.compress(data); err != nil {
+				return fmt.Errorf("compress %s: %w", codec.name, err)
+			}
+			(*t)[i][run] += time.Since(start)
+		}
+	}
+	return nil
+}
+
+// percentile returns the p-th percentile of durations by the nearest-rank
+// method, or 0 if there are none.
+func percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// writeTimingRows writes the median and p95 compression time of every codec
+// for every encoding of file.
+func writeTimingRows(csvWriter *csv.Writer, file string, sizes []encodingSize) error {
+	for _, es := range sizes {
+		for i, runs := range es.timings {
+			if err := csvWriter.Write([]string{
+				file,
+				es.encoding,
+				timedCodecs[i].name,
+				fmt.Sprintf("%d", len(runs)),
+				fmt.Sprintf("%d", percentile(runs, 50).Nanoseconds()),
+				fmt.Sprintf("%d", percentile(runs, 95).Nanoseconds()),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
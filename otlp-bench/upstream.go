@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	upstreamprofiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// upstreamFieldNames maps gh733 field names to the names of the
+// corresponding upstream fields where they differ.
+var upstreamFieldNames = map[protoreflect.Name]protoreflect.Name{
+	"key_ref":    "key_strindex",
+	"string_ref": "string_value_strindex",
+}
+
+// toUpstream converts a gh733 request to the upstream ProfilesData message.
+// Fields are matched by name rather than number because some fields were
+// renumbered between the versions. Unknown fields are dropped, as by any
+// conversion, but other fields that can't be converted are an error rather
+// than silently lost.
+func toUpstream(req *cprofiles.ExportProfilesServiceRequest) (*upstreamprofiles.ProfilesData, error) {
+	data := &upstreamprofiles.ProfilesData{}
+	var err error
+	copyFields(data.ProtoReflect(), req.ProtoReflect(), upstreamFieldNames, func(path, reason string) {
+		if err == nil && reason != unknownFields {
+			err = fmt.Errorf("%s: %s", pathOrRoot(path), reason)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// copyFields copies the populated fields of src into dst, a message of
+// another proto version, matching fields by name rather than number so that
+// renumbered fields keep their values. renames maps the names of src fields
+// to the names of the dst fields where they differ. Fields that are missing in
+// dst or whose type differs, maps and unknown fields are not copied; drop is
+// called for each with its path without list indices, e.g.
+// "resource_profiles.resource.attributes.key_ref", and the reason.
+func copyFields(dst, src protoreflect.Message, renames map[protoreflect.Name]protoreflect.Name, drop func(path, reason string)) {
+	copyFieldsAt(dst, src, "", renames, drop)
+}
+
+// unknownFields is the reason copyFields drops the unknown fields of a
+// message.
+const unknownFields = "unknown fields"
+
+func copyFieldsAt(dst, src protoreflect.Message, path string, renames map[protoreflect.Name]protoreflect.Name, drop func(path, reason string)) {
+	if len(src.GetUnknown()) > 0 {
+		drop(path, unknownFields)
+	}
+	dstFields := dst.Descriptor().Fields()
+	src.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		fieldPath := string(fd.Name())
+		if path != "" {
+			fieldPath = path + "." + fieldPath
+		}
+		name := fd.Name()
+		if renamed, ok := renames[name]; ok {
+			name = renamed
+		}
+		dfd := dstFields.ByName(name)
+		switch {
+		case dfd == nil:
+			drop(fieldPath, "no such field")
+			return true
+		case dfd.Kind() != fd.Kind() || dfd.IsList() != fd.IsList() || dfd.IsMap() != fd.IsMap():
+			drop(fieldPath, "type differs")
+			return true
+		case fd.IsMap():
+			// The profiles protos have no maps, so they are not copied
+			// rather than handling their key and value types.
+			drop(fieldPath, "maps are not mapped")
+			return true
+		}
+
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			srcList, dstList := v.List(), dst.Mutable(dfd).List()
+			for i := range srcList.Len() {
+				elem := dstList.NewElement()
+				copyFieldsAt(elem.Message(), srcList.Get(i).Message(), fieldPath, renames, drop)
+				dstList.Append(elem)
+			}
+		case fd.IsList():
+			srcList, dstList := v.List(), dst.Mutable(dfd).List()
+			for i := range srcList.Len() {
+				dstList.Append(srcList.Get(i))
+			}
+		case fd.Message() != nil:
+			copyFieldsAt(dst.Mutable(dfd).Message(), v.Message(), fieldPath, renames, drop)
+		default:
+			// Scalars and enums, whose numbers are kept, are the same in
+			// every version.
+			dst.Set(dfd, v)
+		}
+		return true
+	})
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "<root>"
+	}
+	return path
+}
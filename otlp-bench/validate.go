@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/open-telemetry/sig-profiling/profcheck"
+	"github.com/urfave/cli/v3"
+)
+
+// defaultChecker runs the checks of validate without --strict.
+var defaultChecker = profcheck.ConformanceChecker{CheckSampleTimestampShape: true}
+
+func (a *App) validateCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "validate",
+		Usage:     "run profcheck conformance checks on gh733 profiles",
+		ArgsUsage: "file [file ...]",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "strict",
+				Usage: "enable all optional conformance checks",
+			},
+		},
+		Arguments: []cli.Argument{
+			&cli.StringArgs{
+				Name:      "file",
+				UsageText: "OTLP profile file to validate",
+				Min:       1,
+				Max:       -1,
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			checker := defaultChecker
+			if cmd.Bool("strict") {
+				checker = profcheck.StrictConformanceChecker()
+			}
+			return a.validate(checker, cmd.StringArgs("file")...)
+		},
+	}
+}
+
+// validate checks every payload of every file and reports pass/fail per file.
+// All files are checked even if an earlier one fails.
+func (a *App) validate(checker profcheck.ConformanceChecker, files ...string) error {
+	files, err := expandGlobs(files)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, file := range files {
+		if err := a.validateFile(checker, file); err != nil {
+			fmt.Fprintf(a.Stdout, "%s: FAIL: %v\n", file, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(a.Stdout, "%s: PASS\n", file)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed validation", failed, len(files))
+	}
+	return nil
+}
+
+func (a *App) validateFile(checker profcheck.ConformanceChecker, file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+	data, err = decompressInput(data)
+	if err != nil {
+		return err
+	}
+	payloads, err := unmarshalOTLP(data)
+	if err != nil {
+		return fmt.Errorf("unmarshal gh733 profile: %w", err)
+	}
+
+	var failed bool
+	for i, payload := range payloads {
+		upstream, err := toUpstream(payload)
+		if err != nil {
+			return fmt.Errorf("payload[%d]: convert to upstream: %w", i, err)
+		}
+		for _, f := range checker.Report(upstream) {
+			fmt.Fprintf(a.Stdout, "%s: payload[%d]: %s\n", file, i, f)
+			if f.Severity == profcheck.SeverityError {
+				failed = true
+			}
+		}
+	}
+	if failed {
+		return fmt.Errorf("conformance checks failed")
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	common "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/common/v1"
+	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
+	upstreamcommon "go.opentelemetry.io/proto/otlp/common/v1"
+	upstreamprofiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
+	"google.golang.org/protobuf/proto"
+)
+
+// minimalRequest returns a small request that passes the default
+// conformance checks.
+func minimalRequest() *cprofiles.ExportProfilesServiceRequest {
+	return &cprofiles.ExportProfilesServiceRequest{
+		Dictionary: &profiles.ProfilesDictionary{
+			MappingTable:  []*profiles.Mapping{{}},
+			LocationTable: []*profiles.Location{{}},
+			FunctionTable: []*profiles.Function{{}},
+			LinkTable:     []*profiles.Link{{}},
+			StringTable:   []string{"", "thread.name", "main"},
+			AttributeTable: []*profiles.KeyValueAndUnit{
+				{},
+				{KeyStrindex: 1, Value: &common.AnyValue{Value: &common.AnyValue_StringRef{StringRef: 2}}},
+			},
+			StackTable: []*profiles.Stack{{}},
+		},
+		ResourceProfiles: []*profiles.ResourceProfiles{{
+			ScopeProfiles: []*profiles.ScopeProfiles{{
+				Profiles: []*profiles.Profile{{
+					TimeUnixNano: 100,
+					DurationNano: 10,
+					Samples: []*profiles.Sample{{
+						Values:           []int64{3},
+						AttributeIndices: []int32{1},
+					}},
+				}},
+			}},
+		}},
+	}
+}
+
+func TestToUpstream(t *testing.T) {
+	got, err := toUpstream(minimalRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sample := got.ResourceProfiles[0].ScopeProfiles[0].Profiles[0].Samples[0]
+	assertEqual(t, sample, &upstreamprofiles.Sample{Values: []int64{3}, AttributeIndices: []int32{1}})
+	assertEqual(t, got.Dictionary.AttributeTable[1].Value, &upstreamcommon.AnyValue{
+		Value: &upstreamcommon.AnyValue_StringValueStrindex{StringValueStrindex: 2},
+	})
+}
+
+func TestAppValidate(t *testing.T) {
+	data, err := proto.Marshal(minimalRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compressed bytes.Buffer
+	gw := gzip.NewWriter(&compressed)
+	gw.Write(data)
+	gw.Close()
+	valid := filepath.Join(t.TempDir(), "valid.otlp.gz")
+	if err := os.WriteFile(valid, compressed.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, err := runTestApp(t, []string{"validate", valid})
+	if err != nil {
+		t.Fatalf("validate: %v\n%s", err, stdout)
+	}
+	assertEqual(t, stdout, valid+": PASS\n")
+
+	invalid := filepath.Join("testdata", "k8s.otlp")
+	stdout, _, err = runTestApp(t, []string{"validate", valid, invalid})
+	if err == nil || err.Error() != "1 of 2 files failed validation" {
+		t.Errorf("validate: got error %v, want 1 of 2 files failed", err)
+	}
+	if !strings.Contains(stdout, invalid+": FAIL") {
+		t.Errorf("validate: missing FAIL for %s in\n%s", invalid, stdout)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math/bits"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+)
+
+// valueHistogram counts samples by the number of bits of their first value.
+// Index i holds the samples whose value needs i bits, negative values are
+// counted with 64 bits as that is how int64 varints encode them.
+type valueHistogram [65]int
+
+// add counts the first value of every sample in data. Samples without values
+// are skipped.
+func (h *valueHistogram) add(data *cprofiles.ExportProfilesServiceRequest) {
+	for _, rp := range data.GetResourceProfiles() {
+		for _, sp := range rp.GetScopeProfiles() {
+			for _, p := range sp.GetProfiles() {
+				for _, s := range p.GetSamples() {
+					if len(s.GetValues()) == 0 {
+						continue
+					}
+					h[bits.Len64(uint64(s.Values[0]))]++
+				}
+			}
+		}
+	}
+}
+
+// varintBytes returns the size of a varint with the given number of bits.
+func varintBytes(n int) int {
+	return max(1, (n+6)/7)
+}
+
+// writeValueHistogram writes a row per non-empty bucket of h for file.
+func writeValueHistogram(csvWriter *csv.Writer, file string, h *valueHistogram) error {
+	for n, samples := range h {
+		if samples == 0 {
+			continue
+		}
+		if err := csvWriter.Write([]string{file, fmt.Sprintf("%d", n), fmt.Sprintf("%d", varintBytes(n)), fmt.Sprintf("%d", samples)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
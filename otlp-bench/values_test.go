@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	profiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/profiles/v1development"
+)
+
+// valueHistogramRequest has samples with values of 0, 1, 7, 8 and 64 bits
+// and a sample without values.
+func valueHistogramRequest() *cprofiles.ExportProfilesServiceRequest {
+	return &cprofiles.ExportProfilesServiceRequest{
+		Dictionary: &profiles.ProfilesDictionary{StringTable: []string{""}},
+		ResourceProfiles: []*profiles.ResourceProfiles{{
+			ScopeProfiles: []*profiles.ScopeProfiles{{
+				Profiles: []*profiles.Profile{{
+					Samples: []*profiles.Sample{
+						{Values: []int64{0}},
+						{Values: []int64{1, 1 << 40}},
+						{Values: []int64{127}},
+						{Values: []int64{128}},
+						{Values: []int64{-1}},
+						{TimestampsUnixNano: []uint64{1}},
+					},
+				}},
+			}},
+		}},
+	}
+}
+
+func TestValueHistogram(t *testing.T) {
+	var h valueHistogram
+	h.add(valueHistogramRequest())
+	want := valueHistogram{}
+	want[0], want[1], want[7], want[8], want[64] = 1, 1, 1, 1, 1
+	assertEqual(t, h, want)
+
+	for _, tc := range []struct{ bits, want int }{{0, 1}, {7, 1}, {8, 2}, {64, 10}} {
+		assertEqual(t, varintBytes(tc.bits), tc.want)
+	}
+}
+
+func TestAppValueHistogram(t *testing.T) {
+	data, err := marshalOptions.Marshal(valueHistogramRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := filepath.Join(t.TempDir(), "values.otlp")
+	if err := os.WriteFile(input, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outDir := filepath.Join(t.TempDir(), "out")
+	if _, _, err := runTestApp(t, []string{"--out", outDir, "--value-histogram", input}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filepath.Join(outDir, "values.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, records, [][]string{
+		{"file", "bits", "varint_bytes", "samples"},
+		{input, "0", "1", "1"},
+		{input, "1", "1", "1"},
+		{input, "7", "1", "1"},
+		{input, "8", "2", "1"},
+		{input, "64", "10", "1"},
+	})
+
+	if _, _, err := runTestApp(t, []string{"--out", "-", "--value-histogram", input}); err == nil {
+		t.Error("expected error for --value-histogram with --out=-")
+	}
+}
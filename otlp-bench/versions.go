@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	cprofiles "github.com/open-telemetry/sig-profiling/otlp-bench/internal/otlpversions/gh733/opentelemetry/proto/collector/profiles/v1development"
+	upstreamcprofiles "go.opentelemetry.io/proto/otlp/collector/profiles/v1development"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// protoVersion is a generated version of the OTLP profiles proto that input
+// payloads can be decoded with.
+type protoVersion struct {
+	name string
+	// newRequest returns an empty ExportProfilesServiceRequest of this version.
+	newRequest func() proto.Message
+	// transforms reports whether the strategies can transform payloads of
+	// this version. They are written against the gh733 bindings, payloads of
+	// other versions are only measured as decoded, see measureDecoded.
+	transforms bool
+}
+
+// protoVersions lists the proto versions known to otlp-bench. Versions
+// generated by otlpgen live in internal/otlpversions, "upstream" is the
+// published go.opentelemetry.io/proto/otlp profiles module.
+var protoVersions = []protoVersion{
+	{
+		name:       "gh733",
+		newRequest: func() proto.Message { return &cprofiles.ExportProfilesServiceRequest{} },
+		transforms: true,
+	},
+	{
+		name:       "upstream",
+		newRequest: func() proto.Message { return &upstreamcprofiles.ExportProfilesServiceRequest{} },
+	},
+}
+
+// benchProtoVersion is the default proto version that payloads are decoded
+// and re-encoded with when measuring their size, see --proto-version. It is
+// recorded in the proto_version column of the summary.
+const benchProtoVersion = "gh733"
+
+func lookupProtoVersion(name string) (protoVersion, error) {
+	for _, v := range protoVersions {
+		if v.name == name {
+			return v, nil
+		}
+	}
+	var names []string
+	for _, v := range protoVersions {
+		names = append(names, v.name)
+	}
+	return protoVersion{}, fmt.Errorf("unknown proto version %q, must be one of %s", name, strings.Join(names, ", "))
+}
+
+// measureDecoded decodes the payloads in data, which is in the given framing,
+// with proto version v and measures their size when re-encoded, as the
+// baseline encoding. It is used for versions that the strategies can't
+// transform.
+func measureDecoded(data []byte, framing string, v protoVersion) ([]encodingSize, int, error) {
+	payloads, err := unmarshalFramed(data, framing, v.newRequest)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unmarshal %s profile: %w", v.name, err)
+	}
+	var stats profileSize
+	hash := sha256.New()
+	for _, payload := range payloads {
+		sizes, encoded, err := profileSizes(payload)
+		if err != nil {
+			return nil, 0, fmt.Errorf("calculate %s sizes: %w", v.name, err)
+		}
+		stats = stats.Add(sizes)
+		hash.Write(encoded)
+	}
+	return []encodingSize{{
+		encoding:     strategies[0].name,
+		protoVersion: v.name,
+		size:         stats,
+		sha256:       hex.EncodeToString(hash.Sum(nil)),
+	}}, len(payloads), nil
+}
+
+// compareVersions decodes every payload in data, which is in the given
+// framing, with proto versions a and b and writes a report of where the
+// decoded structures diverge to out.
+func compareVersions(out io.Writer, file string, data []byte, framing string, a, b protoVersion) error {
+	aPayloads, err := unmarshalFramed(data, framing, a.newRequest)
+	if err != nil {
+		return fmt.Errorf("unmarshal %s profile: %w", a.name, err)
+	}
+	bPayloads, err := unmarshalFramed(data, framing, b.newRequest)
+	if err != nil {
+		return fmt.Errorf("unmarshal %s profile: %w", b.name, err)
+	}
+	comparePayloads(out, file, a.name, b.name, aPayloads, bPayloads)
+	return nil
+}
+
+// comparePayloads writes a report of where the payloads decoded with proto
+// versions a and b diverge to out. Payloads are compared pairwise, a
+// different number of payloads is a divergence of its own.
+func comparePayloads(out io.Writer, file, a, b string, aPayloads, bPayloads []proto.Message) {
+	diffs := versionDiffs{a: a, b: b, counts: map[string]int{}}
+	if len(aPayloads) != len(bPayloads) {
+		diffs.add("", "%d payloads in %s but %d in %s", len(aPayloads), a, len(bPayloads), b)
+	}
+	for i := range min(len(aPayloads), len(bPayloads)) {
+		diffs.compare("", aPayloads[i].ProtoReflect(), bPayloads[i].ProtoReflect())
+	}
+
+	if len(diffs.counts) == 0 {
+		fmt.Fprintf(out, "%s: %s and %s decode to equivalent structures\n", file, a, b)
+		return
+	}
+	fmt.Fprintf(out, "%s: %s and %s diverge:\n", file, a, b)
+	for _, diff := range slices.Sorted(maps.Keys(diffs.counts)) {
+		fmt.Fprintf(out, "  %s (count: %d)\n", diff, diffs.counts[diff])
+	}
+}
+
+// versionDiffs collects the differences between two messages decoded from the
+// same bytes. Differences are keyed by their path without list indices so that
+// a divergence in e.g. every sample is reported once with a count.
+type versionDiffs struct {
+	a, b   string
+	counts map[string]int
+}
+
+func (d *versionDiffs) add(path, format string, args ...any) {
+	if path == "" {
+		path = "<root>"
+	}
+	d.counts[path+": "+fmt.Sprintf(format, args...)]++
+}
+
+func (d *versionDiffs) compare(path string, a, b protoreflect.Message) {
+	switch ua, ub := a.GetUnknown(), b.GetUnknown(); {
+	case len(ua) > 0 && len(ub) == 0:
+		d.add(path, "unknown fields only in %s", d.a)
+	case len(ua) == 0 && len(ub) > 0:
+		d.add(path, "unknown fields only in %s", d.b)
+	case !bytes.Equal(ua, ub):
+		d.add(path, "unknown fields differ")
+	}
+
+	aFields, bFields := a.Descriptor().Fields(), b.Descriptor().Fields()
+	numbers := map[protoreflect.FieldNumber]struct{}{}
+	for i := range aFields.Len() {
+		numbers[aFields.Get(i).Number()] = struct{}{}
+	}
+	for i := range bFields.Len() {
+		numbers[bFields.Get(i).Number()] = struct{}{}
+	}
+
+	for _, num := range slices.Sorted(maps.Keys(numbers)) {
+		fa, fb := aFields.ByNumber(num), bFields.ByNumber(num)
+		switch {
+		case fa == nil:
+			if b.Has(fb) {
+				d.add(path, "field %d (%s) is only known to %s", num, fb.Name(), d.b)
+			}
+			continue
+		case fb == nil:
+			if a.Has(fa) {
+				d.add(path, "field %d (%s) is only known to %s", num, fa.Name(), d.a)
+			}
+			continue
+		case !a.Has(fa) && !b.Has(fb):
+			continue
+		}
+
+		fieldPath := string(fa.Name())
+		if path != "" {
+			fieldPath = path + "." + fieldPath
+		}
+		if fa.Name() != fb.Name() {
+			d.add(path, "field %d is %s in %s but %s in %s", num, fa.Name(), d.a, fb.Name(), d.b)
+		}
+		if fa.Kind() != fb.Kind() || fa.Cardinality() != fb.Cardinality() || fa.IsMap() != fb.IsMap() {
+			d.add(fieldPath, "type %s %s in %s but %s %s in %s", fa.Cardinality(), fa.Kind(), d.a, fb.Cardinality(), fb.Kind(), d.b)
+			continue
+		}
+
+		va, vb := a.Get(fa), b.Get(fb)
+		switch {
+		case fa.IsMap():
+			if !va.Equal(vb) {
+				d.add(fieldPath, "values differ")
+			}
+		case fa.IsList():
+			la, lb := va.List(), vb.List()
+			if la.Len() != lb.Len() {
+				d.add(fieldPath, "length %d in %s but %d in %s", la.Len(), d.a, lb.Len(), d.b)
+				continue
+			}
+			for i := range la.Len() {
+				if fa.Message() != nil {
+					d.compare(fieldPath, la.Get(i).Message(), lb.Get(i).Message())
+				} else if !la.Get(i).Equal(lb.Get(i)) {
+					d.add(fieldPath, "values differ")
+				}
+			}
+		case fa.Message() != nil:
+			d.compare(fieldPath, va.Message(), vb.Message())
+		case !va.Equal(vb):
+			d.add(fieldPath, "values differ")
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "k8s.otlp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gh733, err := lookupProtoVersion("gh733")
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream, err := lookupProtoVersion("upstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("same version", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := compareVersions(&out, "k8s.otlp", data, formatAuto, gh733, gh733); err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, out.String(), "k8s.otlp: gh733 and gh733 decode to equivalent structures\n")
+	})
+
+	t.Run("renumbered sample fields", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := compareVersions(&out, "k8s.otlp", data, formatAuto, gh733, upstream); err != nil {
+			t.Fatal(err)
+		}
+		want := "resource_profiles.scope_profiles.profiles.samples: field 3 is attribute_indices in gh733 but link_index in upstream"
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("got report:\n%s\nwant it to contain %q", out.String(), want)
+		}
+	})
+
+	t.Run("framing", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := compareVersions(&out, "k8s.otlp", data, formatSingle, gh733, upstream); err == nil {
+			t.Errorf("got report:\n%s\nwant error decoding length-prefixed payloads as a single message", out.String())
+		}
+	})
+
+	t.Run("payload counts differ", func(t *testing.T) {
+		var out bytes.Buffer
+		aPayloads, err := unmarshalFramed(data, formatLengthPrefixed, gh733.newRequest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bPayloads := append(slices.Clone(aPayloads), aPayloads[0])
+		comparePayloads(&out, "k8s.otlp", "gh733", "upstream", aPayloads, bPayloads)
+		assertEqual(t, out.String(), fmt.Sprintf("k8s.otlp: gh733 and upstream diverge:\n  <root>: %d payloads in gh733 but %d in upstream (count: 1)\n", len(aPayloads), len(bPayloads)))
+		out.Reset()
+		comparePayloads(&out, "k8s.otlp", "gh733", "upstream", bPayloads, aPayloads)
+		assertEqual(t, out.String(), fmt.Sprintf("k8s.otlp: gh733 and upstream diverge:\n  <root>: %d payloads in gh733 but %d in upstream (count: 1)\n", len(bPayloads), len(aPayloads)))
+	})
+}
+
+func TestLookupProtoVersion(t *testing.T) {
+	if _, err := lookupProtoVersion("v0.0.0"); err == nil || !strings.Contains(err.Error(), `unknown proto version "v0.0.0"`) {
+		t.Errorf("got error %v, want unknown proto version error", err)
+	}
+}
+
+func TestAppProtoVersion(t *testing.T) {
+	input := filepath.Join("testdata", "k8s.otlp")
+	stdout, _, err := runTestApp(t, []string{"--out", "-", "--proto-version", "upstream", input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v\n%s\n", err, stdout)
+	}
+	// Only the baseline is measured for versions the strategies can't
+	// transform.
+	assertEqual(t, len(records), 2)
+	assertEqual(t, records[1][1], "baseline")
+	assertEqual(t, records[1][2], "upstream")
+
+	if _, _, err := runTestApp(t, []string{"--out", "-", "--proto-version", "upstream", "--merge", input}); err == nil {
+		t.Error("expected error for --merge with --proto-version=upstream")
+	}
+	if _, _, err := runTestApp(t, []string{"--out", "-", "--proto-version", "v0.0.0", input}); err == nil {
+		t.Error("expected error for unknown proto version")
+	}
+}
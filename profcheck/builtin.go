@@ -0,0 +1,400 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profcheck
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	profiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
+)
+
+// builtinCheck is an optional check of profcheck, registered by init.
+type builtinCheck struct {
+	name string
+	// enabled returns the field of c that enables the check.
+	enabled func(c *ConformanceChecker) *bool
+	// check returns the findings of the check, with the same paths as the
+	// findings of the required checks.
+	check func(c ConformanceChecker, data *profiles.ProfilesData) error
+}
+
+// builtinChecks are the built-in optional checks, in the order they run.
+var builtinChecks = []builtinCheck{
+	{"dictionary-duplicates", func(c *ConformanceChecker) *bool { return &c.CheckDictionaryDuplicates }, builtinDictionaryDuplicates},
+	{"sample-timestamp-shape", func(c *ConformanceChecker) *bool { return &c.CheckSampleTimestampShape }, builtinSampleTimestampShape},
+	{"dictionary-orphans", func(c *ConformanceChecker) *bool { return &c.CheckDictionaryOrphans }, builtinDictionaryOrphans},
+	{"semantic-attributes", func(c *ConformanceChecker) *bool { return &c.CheckSemanticAttributes }, builtinSemanticAttributes},
+	{"sample-type-set", func(c *ConformanceChecker) *bool { return &c.CheckSampleTypeSet }, builtinSampleTypeSet},
+	{"require-samples", func(c *ConformanceChecker) *bool { return &c.RequireSamples }, builtinRequireSamples},
+	{"payload-format", func(c *ConformanceChecker) *bool { return &c.CheckPayloadFormat }, builtinPayloadFormat},
+	{"zero-value-samples", func(c *ConformanceChecker) *bool { return &c.CheckZeroValueSamples }, builtinZeroValueSamples},
+	{"redundant-profile-attributes", func(c *ConformanceChecker) *bool { return &c.CheckRedundantProfileAttributes }, builtinRedundantProfileAttributes},
+	{"stack-plausibility", func(c *ConformanceChecker) *bool { return &c.CheckStackPlausibility }, builtinStackPlausibility},
+	{"address-range", func(c *ConformanceChecker) *bool { return &c.CheckAddressRange }, builtinAddressRange},
+	{"profile-duration", func(c *ConformanceChecker) *bool { return &c.CheckProfileDuration }, builtinProfileDuration},
+	{"link-consistency", func(c *ConformanceChecker) *bool { return &c.CheckLinkConsistency }, builtinLinkConsistency},
+	{"scope-unit-consistency", func(c *ConformanceChecker) *bool { return &c.CheckScopeUnitConsistency }, builtinScopeUnitConsistency},
+	{"sample-uniqueness", func(c *ConformanceChecker) *bool { return &c.CheckSampleUniqueness }, builtinSampleUniqueness},
+	{"mapping-filename-attributes", func(c *ConformanceChecker) *bool { return &c.CheckMappingFilenameAttributes }, builtinMappingFilenameAttributes},
+	{"zero-attribute-references", func(c *ConformanceChecker) *bool { return &c.CheckZeroAttributeReferences }, builtinZeroAttributeReferences},
+	{"line-numbers", func(c *ConformanceChecker) *bool { return &c.CheckLineNumbers }, builtinLineNumbers},
+	{"negative-values", func(c *ConformanceChecker) *bool { return &c.CheckNegativeValues }, builtinNegativeValues},
+	{"attribute-units", func(c *ConformanceChecker) *bool { return &c.CheckAttributeUnits }, builtinAttributeUnits},
+	{"dropped-attributes", func(c *ConformanceChecker) *bool { return &c.CheckDroppedAttributes }, builtinDroppedAttributes},
+	{"duplicate-timestamps", func(c *ConformanceChecker) *bool { return &c.CheckDuplicateTimestamps }, builtinDuplicateTimestamps},
+	{"timestamp-span", func(c *ConformanceChecker) *bool { return &c.CheckTimestampSpan }, builtinTimestampSpan},
+}
+
+func init() {
+	for _, b := range builtinChecks {
+		RegisterCheck(b.name, func(ctx *CheckContext) {
+			ctx.errs = errors.Join(ctx.errs, b.check(ctx.Checker, ctx.Data))
+		})
+	}
+}
+
+func builtinDictionaryDuplicates(_ ConformanceChecker, data *profiles.ProfilesData) error {
+	dict := data.Dictionary
+	errs := errors.Join(
+		prefixErrorf(checkStringDuplicates(dict.StringTable), "string_table"),
+		prefixErrorf(checkStackDuplicates(dict.StackTable), "stack_table"),
+	)
+	return prefixErrorf(errs, "dictionary")
+}
+
+func builtinSampleTimestampShape(_ ConformanceChecker, data *profiles.ProfilesData) error {
+	return forEachProfile(data, func(prof *profiles.Profile) error {
+		var expectedShape SampleShape
+		return profileSamples(prof, func(s *profiles.Sample) error {
+			return checkSampleShape(s, &expectedShape)
+		})
+	})
+}
+
+func builtinDictionaryOrphans(c ConformanceChecker, data *profiles.ProfilesData) error {
+	return prefixErrorf(c.checkDictionaryOrphans(data), "dictionary")
+}
+
+func builtinSemanticAttributes(_ ConformanceChecker, data *profiles.ProfilesData) error {
+	dict := data.Dictionary
+	errs := forEachResource(data, func(rp *profiles.ResourceProfiles) error {
+		return errors.Join(
+			prefixErrorf(checkSemanticKeyValues(rp.GetResource().GetAttributes(), dict), "resource.attributes"),
+			resourceScopes(rp, func(sp *profiles.ScopeProfiles) error {
+				return prefixErrorf(checkSemanticKeyValues(sp.GetScope().GetAttributes(), dict), "scope.attributes")
+			}),
+		)
+	})
+	return errors.Join(errs, forEachAttributeIndices(data, func(attrIndices []int32) error {
+		var errs error
+		for pos, attrIdx := range attrIndices {
+			attr := entry(dict.AttributeTable, attrIdx)
+			if attr == nil {
+				continue
+			}
+			if key, ok := lookupString(dict, attr.KeyStrindex); ok {
+				errs = errors.Join(errs, prefixErrorf(checkSemanticValue(key, attr.Value), "[%d].value", pos))
+			}
+		}
+		return errs
+	}))
+}
+
+// checkSemanticKeyValues checks the values of resource and scope attributes
+// with checkSemanticValue. Attributes with out of range keys are skipped,
+// checkKeyValues reports them.
+func checkSemanticKeyValues(attrs []*common.KeyValue, dict *profiles.ProfilesDictionary) error {
+	var errs error
+	for pos, kv := range attrs {
+		key := kv.Key
+		if kv.KeyStrindex != 0 {
+			var ok bool
+			if key, ok = lookupString(dict, kv.KeyStrindex); !ok {
+				continue
+			}
+		}
+		errs = errors.Join(errs, prefixErrorf(checkSemanticValue(key, kv.Value), "[%d].value", pos))
+	}
+	return errs
+}
+
+func builtinSampleTypeSet(c ConformanceChecker, data *profiles.ProfilesData) error {
+	dict := data.Dictionary
+	return forEachProfile(data, func(prof *profiles.Profile) error {
+		// An invalid sample type is reported by checkProfile.
+		if c.checkValueType(prof.SampleType, dict) != nil || (len(prof.Samples) == 0 && !c.RequireSamples) {
+			return nil
+		}
+		return prefixErrorf(checkValueTypeSet(prof.SampleType, dict), "sample_type")
+	})
+}
+
+func builtinRequireSamples(_ ConformanceChecker, data *profiles.ProfilesData) error {
+	return forEachProfile(data, func(prof *profiles.Profile) error {
+		if len(prof.Samples) == 0 {
+			return errors.New("profile has no samples")
+		}
+		return nil
+	})
+}
+
+func builtinPayloadFormat(c ConformanceChecker, data *profiles.ProfilesData) error {
+	return forEachProfile(data, func(prof *profiles.Profile) error {
+		return prefixErrorf(c.checkPayloadFormat(prof), "original_payload_format")
+	})
+}
+
+func builtinZeroValueSamples(_ ConformanceChecker, data *profiles.ProfilesData) error {
+	return forEachProfile(data, func(prof *profiles.Profile) error {
+		return profileSamples(prof, func(s *profiles.Sample) error {
+			if len(s.Values) > 0 && !slices.ContainsFunc(s.Values, func(v int64) bool { return v != 0 }) {
+				return warnf("values: all values are zero")
+			}
+			return nil
+		})
+	})
+}
+
+func builtinRedundantProfileAttributes(c ConformanceChecker, data *profiles.ProfilesData) error {
+	dict := data.Dictionary
+	return forEachResource(data, func(rp *profiles.ResourceProfiles) error {
+		resourceAttrs := resolveKeyValues(rp.GetResource().GetAttributes(), dict)
+		return resourceScopes(rp, func(sp *profiles.ScopeProfiles) error {
+			return scopeProfiles(sp, func(prof *profiles.Profile) error {
+				// Invalid attribute indices are reported by checkProfile.
+				if c.checkAttributeIndices(prof.AttributeIndices, dict) != nil {
+					return nil
+				}
+				return prefixErrorf(checkRedundantAttributes(prof.AttributeIndices, dict, resourceAttrs), "attribute_indices")
+			})
+		})
+	})
+}
+
+func builtinStackPlausibility(_ ConformanceChecker, data *profiles.ProfilesData) error {
+	return prefixErrorf(checkStackPlausibility(data.Dictionary), "dictionary")
+}
+
+func builtinAddressRange(_ ConformanceChecker, data *profiles.ProfilesData) error {
+	dict := data.Dictionary
+	var errs error
+	for locIdx, loc := range dict.LocationTable {
+		if loc.Address == 0 || loc.MappingIndex == 0 {
+			continue
+		}
+		m := entry(dict.MappingTable, loc.MappingIndex)
+		if m != nil && m.MemoryStart < m.MemoryLimit && (loc.Address < m.MemoryStart || loc.Address >= m.MemoryLimit) {
+			errs = errors.Join(errs, fmt.Errorf("[%d]: address %016x is outside of mapping_table[%d] [%016x, %016x)", locIdx, loc.Address, loc.MappingIndex, m.MemoryStart, m.MemoryLimit))
+		}
+	}
+	return prefixErrorf(prefixErrorf(errs, "location_table"), "dictionary")
+}
+
+func builtinProfileDuration(c ConformanceChecker, data *profiles.ProfilesData) error {
+	return forEachProfile(data, func(prof *profiles.Profile) error {
+		return prefixErrorf(c.checkProfileDuration(prof.DurationNano), "duration_nano")
+	})
+}
+
+func builtinLinkConsistency(_ ConformanceChecker, data *profiles.ProfilesData) error {
+	return forEachProfile(data, func(prof *profiles.Profile) error {
+		return profileSamples(prof, func(s *profiles.Sample) error {
+			return checkLinkConsistency(s, data.Dictionary)
+		})
+	})
+}
+
+func builtinScopeUnitConsistency(_ ConformanceChecker, data *profiles.ProfilesData) error {
+	return forEachScope(data, func(sp *profiles.ScopeProfiles) error {
+		return checkScopeUnits(sp.Profiles, data.Dictionary)
+	})
+}
+
+func builtinSampleUniqueness(_ ConformanceChecker, data *profiles.ProfilesData) error {
+	return forEachProfile(data, func(prof *profiles.Profile) error {
+		return checkSampleUniqueness(prof.Samples)
+	})
+}
+
+func builtinMappingFilenameAttributes(c ConformanceChecker, data *profiles.ProfilesData) error {
+	dict := data.Dictionary
+	var errs error
+	for idx, m := range dict.MappingTable {
+		// Invalid attribute indices are reported by checkMappingTable.
+		if c.checkAttributeIndices(m.AttributeIndices, dict) != nil {
+			continue
+		}
+		errs = errors.Join(errs, prefixErrorf(checkFilenameAttributes(m, dict), "[%d].attribute_indices", idx))
+	}
+	return prefixErrorf(prefixErrorf(errs, "mapping_table"), "dictionary")
+}
+
+func builtinZeroAttributeReferences(_ ConformanceChecker, data *profiles.ProfilesData) error {
+	// Without attribute table, index 0 is out of range, which the required
+	// checks report.
+	if len(data.Dictionary.AttributeTable) == 0 {
+		return nil
+	}
+	return forEachAttributeIndices(data, func(attrIndices []int32) error {
+		var errs error
+		for pos, attrIdx := range attrIndices {
+			if attrIdx == 0 {
+				errs = errors.Join(errs, fmt.Errorf("[%d]: references the zero value attribute_table[0]", pos))
+			}
+		}
+		return errs
+	})
+}
+
+func builtinLineNumbers(c ConformanceChecker, data *profiles.ProfilesData) error {
+	dict := data.Dictionary
+	var locErrs, fnErrs error
+	for locIdx, loc := range dict.LocationTable {
+		for lineIdx, line := range loc.Lines {
+			// Negative lines are reported by checkLine.
+			if line.Line >= 0 {
+				locErrs = errors.Join(locErrs, prefixErrorf(prefixErrorf(c.checkLineNumber(line.Line), "line"), "[%d].line[%d]", locIdx, lineIdx))
+			}
+		}
+	}
+	for idx, fnc := range dict.FunctionTable {
+		if fnc.StartLine >= 0 {
+			fnErrs = errors.Join(fnErrs, prefixErrorf(c.checkLineNumber(fnc.StartLine), "[%d].start_line", idx))
+		}
+	}
+	return prefixErrorf(errors.Join(prefixErrorf(locErrs, "location_table"), prefixErrorf(fnErrs, "function_table")), "dictionary")
+}
+
+func builtinNegativeValues(c ConformanceChecker, data *profiles.ProfilesData) error {
+	return forEachProfile(data, func(prof *profiles.Profile) error {
+		return c.checkNegativeValues(prof, data.Dictionary)
+	})
+}
+
+func builtinAttributeUnits(c ConformanceChecker, data *profiles.ProfilesData) error {
+	dict := data.Dictionary
+	var errs error
+	for pos, kvu := range dict.AttributeTable {
+		if unit, ok := lookupString(dict, kvu.UnitStrindex); ok {
+			errs = errors.Join(errs, prefixErrorf(c.checkUnit(unit), "[%d].unit_strindex", pos))
+		}
+	}
+	return prefixErrorf(prefixErrorf(errs, "attribute_table"), "dictionary")
+}
+
+func builtinDroppedAttributes(c ConformanceChecker, data *profiles.ProfilesData) error {
+	return forEachResource(data, func(rp *profiles.ResourceProfiles) error {
+		return errors.Join(
+			prefixErrorf(c.checkDroppedAttributes(rp.GetResource().GetDroppedAttributesCount()), "resource.dropped_attributes_count"),
+			resourceScopes(rp, func(sp *profiles.ScopeProfiles) error {
+				return errors.Join(
+					prefixErrorf(c.checkDroppedAttributes(sp.GetScope().GetDroppedAttributesCount()), "scope.dropped_attributes_count"),
+					scopeProfiles(sp, func(prof *profiles.Profile) error {
+						return prefixErrorf(c.checkDroppedAttributes(prof.DroppedAttributesCount), "dropped_attributes_count")
+					}),
+				)
+			}),
+		)
+	})
+}
+
+func builtinDuplicateTimestamps(_ ConformanceChecker, data *profiles.ProfilesData) error {
+	return forEachProfile(data, func(prof *profiles.Profile) error {
+		return profileSamples(prof, func(s *profiles.Sample) error {
+			return checkDuplicateTimestamps(s.TimestampsUnixNano)
+		})
+	})
+}
+
+func builtinTimestampSpan(_ ConformanceChecker, data *profiles.ProfilesData) error {
+	return forEachProfile(data, checkTimestampSpan)
+}
+
+// forEachResource calls fn with every resource profiles of data and joins the
+// errors it returns, prefixed with the path of the resource profiles.
+func forEachResource(data *profiles.ProfilesData, fn func(rp *profiles.ResourceProfiles) error) error {
+	var errs error
+	for i, rp := range data.ResourceProfiles {
+		errs = errors.Join(errs, prefixErrorf(fn(rp), "resource_profiles[%d]", i))
+	}
+	return errs
+}
+
+// forEachScope is forEachResource for every scope profiles of data.
+func forEachScope(data *profiles.ProfilesData, fn func(sp *profiles.ScopeProfiles) error) error {
+	return forEachResource(data, func(rp *profiles.ResourceProfiles) error {
+		return resourceScopes(rp, fn)
+	})
+}
+
+// forEachProfile is forEachResource for every profile of data.
+func forEachProfile(data *profiles.ProfilesData, fn func(prof *profiles.Profile) error) error {
+	return forEachScope(data, func(sp *profiles.ScopeProfiles) error {
+		return scopeProfiles(sp, fn)
+	})
+}
+
+// resourceScopes is forEachResource for the scope profiles of rp.
+func resourceScopes(rp *profiles.ResourceProfiles, fn func(sp *profiles.ScopeProfiles) error) error {
+	var errs error
+	for i, sp := range rp.ScopeProfiles {
+		errs = errors.Join(errs, prefixErrorf(fn(sp), "scope_profiles[%d]", i))
+	}
+	return errs
+}
+
+// scopeProfiles is forEachResource for the profiles of sp.
+func scopeProfiles(sp *profiles.ScopeProfiles, fn func(prof *profiles.Profile) error) error {
+	var errs error
+	for i, prof := range sp.Profiles {
+		errs = errors.Join(errs, prefixErrorf(fn(prof), "profile[%d]", i))
+	}
+	return errs
+}
+
+// profileSamples is forEachResource for the samples of prof.
+func profileSamples(prof *profiles.Profile, fn func(s *profiles.Sample) error) error {
+	var errs error
+	for i, s := range prof.Samples {
+		errs = errors.Join(errs, prefixErrorf(fn(s), "sample[%d]", i))
+	}
+	return errs
+}
+
+// forEachAttributeIndices calls fn with the attribute_indices of every
+// profile, sample, mapping and location of data, and joins the errors it
+// returns prefixed with the path of the attribute_indices.
+func forEachAttributeIndices(data *profiles.ProfilesData, fn func(attrIndices []int32) error) error {
+	errs := forEachProfile(data, func(prof *profiles.Profile) error {
+		return errors.Join(
+			prefixErrorf(fn(prof.AttributeIndices), "attribute_indices"),
+			profileSamples(prof, func(s *profiles.Sample) error {
+				return prefixErrorf(fn(s.AttributeIndices), "attribute_indices")
+			}),
+		)
+	})
+	var mappingErrs, locErrs error
+	for idx, m := range data.Dictionary.MappingTable {
+		mappingErrs = errors.Join(mappingErrs, prefixErrorf(fn(m.AttributeIndices), "[%d].attribute_indices", idx))
+	}
+	for idx, loc := range data.Dictionary.LocationTable {
+		locErrs = errors.Join(locErrs, prefixErrorf(fn(loc.AttributeIndices), "[%d].attribute_indices", idx))
+	}
+	dictErrs := errors.Join(prefixErrorf(mappingErrs, "mapping_table"), prefixErrorf(locErrs, "location_table"))
+	return errors.Join(errs, prefixErrorf(dictErrs, "dictionary"))
+}
@@ -17,25 +17,222 @@
 package profcheck
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
+	common "go.opentelemetry.io/proto/otlp/common/v1"
 	profiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
 	"google.golang.org/protobuf/proto"
 )
 
 // ConformanceChecker encapsulates OpenTelemetry Profiles signal checks for
 // conformance of the given proto to the signal requirements and conventions.
+// The yaml tags allow loading a checker from a configuration file.
+//
+// The checks every producer must pass always run. The optional checks are
+// registered checks, see RegisterCheck, that each Check* field and
+// RequireSamples enables. A built-in check is registered under the yaml tag
+// of its field without the check_ prefix and with dashes, e.g.
+// "dictionary-duplicates" for CheckDictionaryDuplicates.
 type ConformanceChecker struct {
-	CheckDictionaryDuplicates bool
-	CheckSampleTimestampShape bool
-	CheckDictionaryOrphans    bool
+	CheckDictionaryDuplicates bool `yaml:"check_dictionary_duplicates"`
+	CheckSampleTimestampShape bool `yaml:"check_sample_timestamp_shape"`
+	CheckDictionaryOrphans    bool `yaml:"check_dictionary_orphans"`
+	// CheckSemanticAttributes verifies that attributes with well-known
+	// semantic convention keys have values of the expected type. A mismatch
+	// usually means an index was resolved against the wrong dictionary.
+	CheckSemanticAttributes bool `yaml:"check_semantic_attributes"`
+	// CheckSampleTypeSet requires every profile to declare a sample_type
+	// whose type and unit are not the empty string, whether referenced at
+	// index 0 or at any other index resolving to it. Profiles without value
+	// semantics, e.g. timestamp-only profiles, may legitimately leave it unset.
+	CheckSampleTypeSet bool `yaml:"check_sample_type_set"`
+	// RequireSamples rejects profiles without samples. When it is not set,
+	// profiles without samples are valid and are exempt from the checks on
+	// sample values such as CheckSampleTypeSet.
+	RequireSamples bool `yaml:"require_samples"`
+	// CheckPayloadFormat requires original_payload_format to be set if
+	// original_payload is, and warns about formats that are not in
+	// AllowedPayloadFormats.
+	CheckPayloadFormat bool `yaml:"check_payload_format"`
+	// CheckZeroValueSamples warns about samples whose values are all zero. Such
+	// samples contribute nothing and usually indicate an accounting bug in
+	// the producer, e.g. a counter reset leaking into the output.
+	CheckZeroValueSamples bool `yaml:"check_zero_value_samples"`
+	// CheckRedundantProfileAttributes warns about profile attributes that
+	// repeat a resource attribute with the same key and value, which wastes
+	// space and is usually an exporter mistake.
+	CheckRedundantProfileAttributes bool `yaml:"check_redundant_profile_attributes"`
+	// CheckStackPlausibility warns about stacks that are unlikely to come
+	// from a correct unwind, i.e. consecutive frames in distinct mappings
+	// that overlap in memory. It is a heuristic aimed at catching corrupt
+	// unwinds.
+	CheckStackPlausibility bool `yaml:"check_stack_plausibility"`
+	// CheckAddressRange requires the address of a location to lie within
+	// the [memory_start, memory_limit) range of its mapping. Locations
+	// without an address or mapping, and mappings without a memory range,
+	// are exempt.
+	CheckAddressRange bool `yaml:"check_address_range"`
+	// CheckProfileDuration warns about profiles whose duration_nano exceeds
+	// MaxProfileDuration, which usually means the producer set it in the
+	// wrong unit, e.g. seconds instead of nanoseconds.
+	CheckProfileDuration bool `yaml:"check_profile_duration"`
+	// MaxProfileDuration is the longest plausible profile duration. If zero,
+	// DefaultMaxProfileDuration is used.
+	MaxProfileDuration time.Duration `yaml:"max_profile_duration"`
+	// CheckLinkConsistency warns about samples that reference a link but
+	// carry no span correlation attributes, or the other way around, which
+	// usually means trace correlation is only half wired up.
+	CheckLinkConsistency bool `yaml:"check_link_consistency"`
+	// CheckScopeUnitConsistency warns about scopes whose profiles declare
+	// sample types with different units, which often means unrelated
+	// profiles were put under one scope.
+	CheckScopeUnitConsistency bool `yaml:"check_scope_unit_consistency"`
+	// CheckSampleUniqueness rejects profiles with several samples that have
+	// the same stack_index, link_index and set of attribute_indices. Such
+	// samples should be merged into one by the producer, see
+	// https://github.com/open-telemetry/opentelemetry-proto/issues/706.
+	CheckSampleUniqueness bool `yaml:"check_sample_uniqueness"`
+	// CheckMappingFilenameAttributes warns about mappings with an attribute
+	// whose value repeats the filename, e.g. process.executable.path, which
+	// duplicates information that filename_strindex already carries.
+	CheckMappingFilenameAttributes bool `yaml:"check_mapping_filename_attributes"`
+	// CheckZeroAttributeReferences rejects attribute_indices entries that
+	// reference the zero value attribute at index 0. Such a reference adds
+	// an empty attribute and should be omitted instead.
+	CheckZeroAttributeReferences bool `yaml:"check_zero_attribute_references"`
+	// CheckLineNumbers warns about function start lines and location line
+	// numbers above MaxLineNumber, which usually means a byte offset was
+	// stored instead of a line number.
+	CheckLineNumbers bool `yaml:"check_line_numbers"`
+	// MaxLineNumber is the largest plausible line number. If zero,
+	// DefaultMaxLineNumber is used.
+	MaxLineNumber int64 `yaml:"max_line_number"`
+	// CheckNegativeValues rejects negative sample values in profiles whose
+	// sample type is in CumulativeSampleTypes. Cumulative counters can't
+	// decrease, so a negative value usually is an overflow in the producer.
+	CheckNegativeValues bool `yaml:"check_negative_values"`
+	// CheckAttributeUnits warns about attribute table entries whose unit is
+	// not empty and not in KnownUnits, which catches typos such as
+	// "nanosecond" for "ns".
+	CheckAttributeUnits bool `yaml:"check_attribute_units"`
+	// CheckDroppedAttributes warns about resources, scopes and profiles whose
+	// dropped_attributes_count exceeds MaxDroppedAttributes. Such counts
+	// usually come from a counter that isn't reset between exports.
+	CheckDroppedAttributes bool `yaml:"check_dropped_attributes"`
+	// MaxDroppedAttributes is the largest plausible
+	// dropped_attributes_count. If zero, DefaultMaxDroppedAttributes is used.
+	MaxDroppedAttributes uint32 `yaml:"max_dropped_attributes"`
+	// CheckDuplicateTimestamps warns about samples with runs of equal
+	// consecutive timestamps. They may be several hits in one nanosecond,
+	// but also a producer that reuses a stale clock reading.
+	CheckDuplicateTimestamps bool `yaml:"check_duplicate_timestamps"`
+	// CheckTimestampSpan warns about profiles whose sample timestamps span
+	// more than duration_nano. The timestamps outside of the profile time
+	// range are rejected one by one anyway, but the span points at the
+	// likely cause: a duration that is too short or in the wrong unit.
+	CheckTimestampSpan bool `yaml:"check_timestamp_span"`
+	// AllowedPayloadFormats are the known original_payload_format values.
+	// If nil, DefaultPayloadFormats is used.
+	AllowedPayloadFormats []string `yaml:"allowed_payload_formats"`
+	// CumulativeSampleTypes are the sample type names whose values must not
+	// be negative. If nil, DefaultCumulativeSampleTypes is used.
+	CumulativeSampleTypes []string `yaml:"cumulative_sample_types"`
+	// KnownUnits are the units accepted by CheckAttributeUnits. If nil,
+	// DefaultKnownUnits is used.
+	KnownUnits []string `yaml:"known_units"`
+	// Checks are the names of registered checks to run in addition to the
+	// built-in checks enabled by the fields above, e.g. custom checks or
+	// built-in checks by name.
+	Checks []string `yaml:"checks"`
+	// DisabledChecks are the names of registered checks not to run even if
+	// they are enabled by a field or Checks, e.g. to replace a built-in check
+	// by a custom one.
+	DisabledChecks []string `yaml:"disabled_checks"`
+}
+
+// DefaultPayloadFormats are the original_payload_format values known by
+// default.
+var DefaultPayloadFormats = []string{"pprof", "jfr", "perf"}
+
+// DefaultCumulativeSampleTypes are the sample types checked by
+// CheckNegativeValues by default, the counters of the common pprof profiles.
+var DefaultCumulativeSampleTypes = []string{"cpu", "wall", "samples", "alloc_objects", "alloc_space", "contentions", "delay"}
+
+// DefaultKnownUnits are the units known by default: the UCUM codes for time,
+// sizes and dimensionless values used by OpenTelemetry semantic conventions,
+// and the unit names of pprof sample types.
+var DefaultKnownUnits = []string{
+	"ns", "us", "ms", "s", "min", "h", "d",
+	"By", "KiBy", "MiBy", "GiBy", "bit", "1", "%", "Hz",
+	"nanoseconds", "microseconds", "milliseconds", "seconds", "bytes", "count",
+}
+
+// DefaultMaxProfileDuration is the default MaxProfileDuration.
+const DefaultMaxProfileDuration = 24 * time.Hour
+
+// DefaultMaxLineNumber is the default MaxLineNumber.
+const DefaultMaxLineNumber = 10_000_000
+
+// DefaultMaxDroppedAttributes is the default MaxDroppedAttributes.
+const DefaultMaxDroppedAttributes = 100_000
+
+// StrictConformanceChecker returns a checker with every optional check
+// enabled. The zero ConformanceChecker only runs the checks every producer
+// must pass, e.g. it accepts a profile without samples or sample type; each
+// additional check is opt-in so that existing producers keep passing as checks
+// are added.
+func StrictConformanceChecker() ConformanceChecker {
+	return ConformanceChecker{
+		CheckDictionaryDuplicates:       true,
+		CheckSampleTimestampShape:       true,
+		CheckDictionaryOrphans:          true,
+		CheckSemanticAttributes:         true,
+		CheckSampleTypeSet:              true,
+		RequireSamples:                  true,
+		CheckPayloadFormat:              true,
+		CheckZeroValueSamples:           true,
+		CheckRedundantProfileAttributes: true,
+		CheckStackPlausibility:          true,
+		CheckAddressRange:               true,
+		CheckProfileDuration:            true,
+		CheckLinkConsistency:            true,
+		CheckScopeUnitConsistency:       true,
+		CheckSampleUniqueness:           true,
+		CheckMappingFilenameAttributes:  true,
+		CheckZeroAttributeReferences:    true,
+		CheckLineNumbers:                true,
+		CheckNegativeValues:             true,
+		CheckAttributeUnits:             true,
+		CheckDroppedAttributes:          true,
+		CheckDuplicateTimestamps:        true,
+		CheckTimestampSpan:              true,
+	}
 }
 
-func (c ConformanceChecker) Check(data *profiles.ProfilesData) error {
+// check runs the required checks, then the active registered checks, see
+// ActiveChecks. The returned error joins all findings, including warnings.
+func (c ConformanceChecker) check(data *profiles.ProfilesData) error {
 	dict := data.Dictionary
 	if len(data.ResourceProfiles) == 0 {
-		return errors.New("resource profiles are empty")
+		return inCheck("structure", errors.New("resource profiles are empty"))
+	}
+	// Every reference is resolved against the dictionary, without it there
+	// is nothing else to check.
+	if dict == nil {
+		return inCheck("structure", errors.New("dictionary is missing"))
+	}
+	// Protobuf decoding never produces nil entries, but programmatically
+	// built payloads can. The other checks dereference table entries, so
+	// report them on their own.
+	if err := checkNilEntries(dict); err != nil {
+		return prefixErrorf(inCheck("nil-entries", err), "dictionary")
 	}
 	var errs error
 	for i, rp := range data.ResourceProfiles {
@@ -46,18 +243,22 @@ func (c ConformanceChecker) Check(data *profiles.ProfilesData) error {
 	if err := c.checkDictionary(dict); err != nil {
 		errs = errors.Join(errs, prefixErrorf(err, "dictionary"))
 	}
-	if c.CheckDictionaryOrphans {
-		if err := c.checkDictionaryOrphans(data); err != nil {
-			errs = errors.Join(errs, prefixErrorf(err, "dictionary"))
-		}
+	if err := c.runRegisteredChecks(data); err != nil {
+		errs = errors.Join(errs, err)
 	}
 	return errs
 }
 
 func (c ConformanceChecker) checkResourceProfiles(rp *profiles.ResourceProfiles, dict *profiles.ProfilesDictionary) error {
 	var errs error
+	if err := c.checkKeyValues(rp.GetResource().GetAttributes(), dict); err != nil {
+		errs = errors.Join(errs, prefixErrorf(err, "resource.attributes"))
+	}
+	if err := checkEntityRefs(rp.GetResource().GetEntityRefs(), rp.GetResource().GetAttributes(), dict); err != nil {
+		errs = errors.Join(errs, prefixErrorf(inCheck("entity-refs", err), "resource.entity_refs"))
+	}
 	if len(rp.ScopeProfiles) == 0 {
-		errs = errors.Join(errs, errors.New("resource profiles has no scope profiles"))
+		errs = errors.Join(errs, inCheck("structure", errors.New("resource profiles has no scope profiles")))
 	}
 	for i, sp := range rp.ScopeProfiles {
 		if err := c.checkScopeProfiles(sp, dict); err != nil {
@@ -69,8 +270,11 @@ func (c ConformanceChecker) checkResourceProfiles(rp *profiles.ResourceProfiles,
 
 func (c ConformanceChecker) checkScopeProfiles(sp *profiles.ScopeProfiles, dict *profiles.ProfilesDictionary) error {
 	var errs error
+	if err := c.checkKeyValues(sp.GetScope().GetAttributes(), dict); err != nil {
+		errs = errors.Join(errs, prefixErrorf(err, "scope.attributes"))
+	}
 	if len(sp.Profiles) == 0 {
-		errs = errors.Join(errs, errors.New("scope profiles has no profiles"))
+		errs = errors.Join(errs, inCheck("structure", errors.New("scope profiles has no profiles")))
 	}
 	for i, profile := range sp.Profiles {
 		if err := c.checkProfile(profile, dict); err != nil {
@@ -80,6 +284,29 @@ func (c ConformanceChecker) checkScopeProfiles(sp *profiles.ScopeProfiles, dict
 	return errs
 }
 
+// checkScopeUnits warns about profiles whose sample type unit differs from
+// the first unit declared in the scope. Unset and invalid units are skipped,
+// they are reported by other checks.
+func checkScopeUnits(profs []*profiles.Profile, dict *profiles.ProfilesDictionary) error {
+	var errs error
+	firstUnit, firstIdx := "", -1
+	for i, prof := range profs {
+		idx := prof.GetSampleType().GetUnitStrindex()
+		if idx <= 0 || int(idx) >= len(dict.StringTable) || dict.StringTable[idx] == "" {
+			continue
+		}
+		unit := dict.StringTable[idx]
+		switch {
+		case firstIdx < 0:
+			firstUnit, firstIdx = unit, i
+		case unit != firstUnit:
+			errs = errors.Join(errs, warnf("profile[%d]: sample_type unit %q differs from unit %q of profile[%d] in the same scope", i, unit, firstUnit, firstIdx))
+		}
+	}
+	return errs
+}
+
+// checkProfile runs the required checks on prof and its samples.
 func (c ConformanceChecker) checkProfile(prof *profiles.Profile, dict *profiles.ProfilesDictionary) error {
 	var errs error
 	if err := c.checkAttributeIndices(prof.AttributeIndices, dict); err != nil {
@@ -91,19 +318,106 @@ func (c ConformanceChecker) checkProfile(prof *profiles.Profile, dict *profiles.
 	if err := c.checkValueType(prof.PeriodType, dict); err != nil {
 		errs = errors.Join(errs, prefixErrorf(err, "period_type"))
 	}
-	var expectedShape SampleShape
+	// Without time_unix_nano every timestamp would be reported as outside of
+	// the profile time range, so report the cause once instead.
+	if prof.TimeUnixNano == 0 && slices.ContainsFunc(prof.Samples, func(s *profiles.Sample) bool { return len(s.TimestampsUnixNano) > 0 }) {
+		errs = errors.Join(errs, inCheck("time-range", errors.New("profile has timestamped samples but time_unix_nano is unset")))
+	}
 	for i, s := range prof.Samples {
-		err := c.checkSample(s, prof.TimeUnixNano, prof.TimeUnixNano+prof.DurationNano, dict, &expectedShape)
+		err := c.checkSample(s, prof.TimeUnixNano, prof.TimeUnixNano+prof.DurationNano, dict)
 		if err != nil {
 			errs = errors.Join(errs, prefixErrorf(err, "sample[%d]", i))
 		}
-		// TODO: Check uniqueness of samples?
-		// Key: {stack_index, sorted(attribute_indices), link_index}
-		// Related: https://github.com/open-telemetry/opentelemetry-proto/issues/706.
 	}
 	return errs
 }
 
+// sampleKey identifies a sample within a profile. attrs are the sorted
+// attribute_indices, formatted so that the key is comparable.
+type sampleKey struct {
+	stack, link int32
+	attrs       string
+}
+
+// checkSampleUniqueness reports samples with the same key as an earlier
+// sample of the profile.
+func checkSampleUniqueness(samples []*profiles.Sample) error {
+	var errs error
+	seen := make(map[sampleKey]int, len(samples))
+	for i, s := range samples {
+		attrs := slices.Clone(s.AttributeIndices)
+		slices.Sort(attrs)
+		key := sampleKey{stack: s.StackIndex, link: s.LinkIndex, attrs: fmt.Sprint(attrs)}
+		if j, ok := seen[key]; ok {
+			errs = errors.Join(errs, fmt.Errorf("sample[%d]: same stack_index, link_index and attribute_indices as sample[%d]", i, j))
+			continue
+		}
+		seen[key] = i
+	}
+	return errs
+}
+
+// checkNegativeValues rejects the negative values of prof if its sample type
+// is cumulative. An unset or invalid sample type is skipped, it is reported
+// by other checks.
+func (c ConformanceChecker) checkNegativeValues(prof *profiles.Profile, dict *profiles.ProfilesDictionary) error {
+	idx := prof.GetSampleType().GetTypeStrindex()
+	if idx <= 0 || int(idx) >= len(dict.StringTable) {
+		return nil
+	}
+	sampleType := dict.StringTable[idx]
+	cumulative := c.CumulativeSampleTypes
+	if cumulative == nil {
+		cumulative = DefaultCumulativeSampleTypes
+	}
+	if !slices.Contains(cumulative, sampleType) {
+		return nil
+	}
+	var errs error
+	for i, s := range prof.Samples {
+		for j, v := range s.Values {
+			if v < 0 {
+				errs = errors.Join(errs, prefixErrorf(fmt.Errorf("%d < 0, %q values are cumulative", v, sampleType), "sample[%d].values[%d]", i, j))
+			}
+		}
+	}
+	return errs
+}
+
+func (c ConformanceChecker) checkPayloadFormat(prof *profiles.Profile) error {
+	format := prof.GetOriginalPayloadFormat()
+	if format == "" {
+		if len(prof.GetOriginalPayload()) > 0 {
+			return errors.New("must be set if original_payload is set")
+		}
+		return nil
+	}
+	allowed := c.AllowedPayloadFormats
+	if allowed == nil {
+		allowed = DefaultPayloadFormats
+	}
+	if !slices.Contains(allowed, format) {
+		return warnf("unknown format %q, known formats are %s", format, strings.Join(allowed, ", "))
+	}
+	return nil
+}
+
+// checkProfileDuration warns if durationNano exceeds MaxProfileDuration.
+func (c ConformanceChecker) checkProfileDuration(durationNano uint64) error {
+	maxDuration := c.MaxProfileDuration
+	if maxDuration == 0 {
+		maxDuration = DefaultMaxProfileDuration
+	}
+	if durationNano <= uint64(maxDuration) {
+		return nil
+	}
+	// Durations beyond ~292 years don't fit a time.Duration.
+	if durationNano > math.MaxInt64 {
+		return warnf("%d ns exceeds the maximum of %s, wrong unit?", durationNano, maxDuration)
+	}
+	return warnf("%s exceeds the maximum of %s, wrong unit?", time.Duration(durationNano), maxDuration)
+}
+
 // SampleShape represents the values vs timestamps combination of sample data.
 type SampleShape int
 
@@ -128,27 +442,45 @@ func (s SampleShape) String() string {
 	}
 }
 
-func (c ConformanceChecker) checkSample(s *profiles.Sample, startUnixNano uint64, endUnixNano uint64, dict *profiles.ProfilesDictionary, expectedShape *SampleShape) error {
+func (c ConformanceChecker) checkSample(s *profiles.Sample, startUnixNano uint64, endUnixNano uint64, dict *profiles.ProfilesDictionary) error {
 	var errs error
 	if err := c.checkIndex(len(dict.StackTable), s.StackIndex); err != nil {
 		errs = errors.Join(errs, prefixErrorf(err, "stack_index"))
 	}
+	// Sample attribute_indices are only checked against the whole attribute
+	// table. The schema has no set of attributes a profile declares for its
+	// samples: profile.attribute_indices are the attributes of the profile
+	// itself, and samples may reference any entry of the shared dictionary.
 	if err := c.checkAttributeIndices(s.AttributeIndices, dict); err != nil {
 		errs = errors.Join(errs, prefixErrorf(err, "attribute_indices"))
 	}
 	if err := c.checkIndex(len(dict.LinkTable), s.LinkIndex); err != nil {
 		errs = errors.Join(errs, prefixErrorf(err, "link_index"))
 	}
-	for i, tsUnixNano := range s.TimestampsUnixNano {
-		if tsUnixNano < startUnixNano || tsUnixNano >= endUnixNano {
-			errs = errors.Join(errs, fmt.Errorf("timestamps_unix_nano[%d]=%d is outside profile time range [%d, %d)", i, tsUnixNano, startUnixNano, endUnixNano))
+	// An unset start time is reported once by checkProfile instead.
+	if startUnixNano != 0 {
+		for i, tsUnixNano := range s.TimestampsUnixNano {
+			if tsUnixNano < startUnixNano || tsUnixNano >= endUnixNano {
+				errs = errors.Join(errs, inCheck("time-range", fmt.Errorf("timestamps_unix_nano[%d]=%d is outside profile time range [%d, %d)", i, tsUnixNano, startUnixNano, endUnixNano)))
+			}
 		}
 	}
 
-	if !c.CheckSampleTimestampShape {
-		return errs
+	// A profile has a single sample_type, so without timestamps a sample has
+	// exactly one value. Values paired with timestamps_unix_nano are checked
+	// by checkSampleShape. This is a schema rule rather than a shape
+	// consistency check, so it is not optional.
+	if len(s.TimestampsUnixNano) == 0 && len(s.Values) > 1 {
+		errs = errors.Join(errs, inCheck("sample-values", fmt.Errorf("values (len=%d) must contain a single element if timestamps_unix_nano is not set", len(s.Values))))
 	}
+	return errs
+}
 
+// checkSampleShape verifies that s has values, timestamps or both, as
+// parallel arrays, and the same shape as the earlier samples of its profile,
+// recorded in expectedShape.
+func checkSampleShape(s *profiles.Sample, expectedShape *SampleShape) error {
+	var errs error
 	var shape SampleShape
 	if hasValues, hasTimestamps := len(s.Values) > 0, len(s.TimestampsUnixNano) > 0; hasValues && hasTimestamps {
 		if len(s.Values) != len(s.TimestampsUnixNano) {
@@ -156,9 +488,6 @@ func (c ConformanceChecker) checkSample(s *profiles.Sample, startUnixNano uint64
 		}
 		shape = SampleShapeBoth
 	} else if hasValues {
-		if len(s.Values) != 1 {
-			errs = errors.Join(errs, fmt.Errorf("values (len=%d) must contain a single element if timestamps_unix_nano is not set", len(s.Values)))
-		}
 		shape = SampleShapeValuesOnly
 	} else if hasTimestamps {
 		shape = SampleShapeTimestampsOnly
@@ -176,38 +505,34 @@ func (c ConformanceChecker) checkSample(s *profiles.Sample, startUnixNano uint64
 	return errs
 }
 
+// checkDictionary checks the dictionary tables concurrently, as they are
+// independent of each other. The errors are joined in the order of the tables
+// below, regardless of which check finishes first.
 func (c ConformanceChecker) checkDictionary(dict *profiles.ProfilesDictionary) error {
-	var errs error
-
-	if err := c.checkMappingTable(dict.GetMappingTable(), dict); err != nil {
-		errs = errors.Join(errs, prefixErrorf(err, "mapping_table"))
-	}
-
-	if err := c.checkLocationTable(dict.GetLocationTable(), dict); err != nil {
-		errs = errors.Join(errs, prefixErrorf(err, "location_table"))
-	}
-
-	if err := c.checkFunctionTable(dict.GetFunctionTable(), dict); err != nil {
-		errs = errors.Join(errs, prefixErrorf(err, "function_table"))
+	tables := []struct {
+		name  string
+		check func() error
+	}{
+		{"mapping_table", func() error { return c.checkMappingTable(dict.GetMappingTable(), dict) }},
+		{"location_table", func() error { return c.checkLocationTable(dict.GetLocationTable(), dict) }},
+		{"function_table", func() error { return c.checkFunctionTable(dict.GetFunctionTable(), dict) }},
+		{"link_table", func() error { return c.checkLinkTable(dict.GetLinkTable()) }},
+		{"string_table", func() error { return c.checkStringTable(dict.GetStringTable()) }},
+		{"attribute_table", func() error { return c.checkAttributeTable(dict.GetAttributeTable(), dict.GetStringTable()) }},
+		{"stack_table", func() error { return c.checkStackTable(dict.GetStackTable(), len(dict.GetLocationTable())) }},
 	}
 
-	if err := c.checkLinkTable(dict.GetLinkTable()); err != nil {
-		errs = errors.Join(errs, prefixErrorf(err, "link_table"))
-	}
-
-	if err := c.checkStringTable(dict.GetStringTable()); err != nil {
-		errs = errors.Join(errs, prefixErrorf(err, "string_table"))
-	}
-
-	if err := c.checkAttributeTable(dict.GetAttributeTable(), len(dict.GetStringTable())); err != nil {
-		errs = errors.Join(errs, prefixErrorf(err, "attribute_table"))
-	}
-
-	if err := c.checkStackTable(dict.GetStackTable(), len(dict.GetLocationTable())); err != nil {
-		errs = errors.Join(errs, prefixErrorf(err, "stack_table"))
+	results := make([]error, len(tables))
+	var wg sync.WaitGroup
+	for i, table := range tables {
+		wg.Go(func() {
+			if err := table.check(); err != nil {
+				results[i] = prefixErrorf(err, "%s", table.name)
+			}
+		})
 	}
-
-	return errs
+	wg.Wait()
+	return errors.Join(results...)
 }
 
 func (c ConformanceChecker) checkValueType(valueType *profiles.ValueType, dict *profiles.ProfilesDictionary) error {
@@ -221,6 +546,54 @@ func (c ConformanceChecker) checkValueType(valueType *profiles.ValueType, dict *
 	return errs
 }
 
+// checkLinkConsistency warns if exactly one of the link and the span
+// correlation attributes of s is set. Invalid indices are skipped, they are
+// reported by the index checks.
+func checkLinkConsistency(s *profiles.Sample, dict *profiles.ProfilesDictionary) error {
+	corrPos, corrKey := -1, ""
+	for pos, attrIdx := range s.AttributeIndices {
+		if attrIdx < 0 || int(attrIdx) >= len(dict.AttributeTable) {
+			continue
+		}
+		keyIdx := dict.AttributeTable[attrIdx].KeyStrindex
+		if keyIdx < 0 || int(keyIdx) >= len(dict.StringTable) {
+			continue
+		}
+		if key := dict.StringTable[keyIdx]; linkAttributeKeys[key] {
+			corrPos, corrKey = pos, key
+			break
+		}
+	}
+	switch {
+	case s.LinkIndex != 0 && corrPos < 0:
+		return warnf("link_index: sample references a link but has no span correlation attributes")
+	case s.LinkIndex == 0 && corrPos >= 0:
+		return warnf("attribute_indices[%d]: %q correlates the sample with a span but link_index is unset", corrPos, corrKey)
+	}
+	return nil
+}
+
+// checkValueTypeSet verifies that neither the type nor the unit of valueType
+// resolves to the empty string. The indices must have been range checked.
+func checkValueTypeSet(valueType *profiles.ValueType, dict *profiles.ProfilesDictionary) error {
+	var errs error
+	for _, field := range []struct {
+		name string
+		idx  int32
+	}{
+		{"type_strindex", valueType.GetTypeStrindex()},
+		{"unit_strindex", valueType.GetUnitStrindex()},
+	} {
+		switch {
+		case field.idx == 0:
+			errs = errors.Join(errs, fmt.Errorf("%s: must not reference the empty string", field.name))
+		case dict.StringTable[field.idx] == "":
+			errs = errors.Join(errs, fmt.Errorf("%s: %d resolves to the empty string, the field is effectively unset", field.name, field.idx))
+		}
+	}
+	return errs
+}
+
 func (c ConformanceChecker) checkMappingTable(mappingTable []*profiles.Mapping, dict *profiles.ProfilesDictionary) error {
 	var errs error
 	if err := checkZeroVal(mappingTable); err != nil {
@@ -234,13 +607,40 @@ func (c ConformanceChecker) checkMappingTable(mappingTable []*profiles.Mapping,
 			errs = errors.Join(errs, prefixErrorf(err, "[%d].attribute_indices", idx))
 		}
 		if !(m.MemoryStart == 0 && m.MemoryLimit == 0) && !(m.MemoryStart < m.MemoryLimit) {
-			errs = errors.Join(errs, fmt.Errorf("[%d]: memory_start=%016x, memory_limit=%016x: must be both zero or start < limit", idx, m.MemoryStart, m.MemoryLimit))
+			errs = errors.Join(errs, inCheck("memory-range", fmt.Errorf("[%d]: memory_start=%016x, memory_limit=%016x: must be both zero or start < limit", idx, m.MemoryStart, m.MemoryLimit)))
 		}
 	}
 	// TODO: Add optional uniqueness check.
 	return errs
 }
 
+// checkFilenameAttributes warns about attributes of m whose string value
+// equals its filename. The attribute indices must have been checked by
+// checkAttributeIndices, an invalid or empty filename is skipped.
+func checkFilenameAttributes(m *profiles.Mapping, dict *profiles.ProfilesDictionary) error {
+	if m.FilenameStrindex <= 0 || int(m.FilenameStrindex) >= len(dict.StringTable) {
+		return nil
+	}
+	filename := dict.StringTable[m.FilenameStrindex]
+	if filename == "" {
+		return nil
+	}
+	var errs error
+	for pos, attrIdx := range m.AttributeIndices {
+		attr := dict.AttributeTable[attrIdx]
+		value, ok := resolveAnyValue(attr.Value, dict.StringTable)
+		if !ok || value.GetStringValue() != filename {
+			continue
+		}
+		key := ""
+		if attr.KeyStrindex >= 0 && int(attr.KeyStrindex) < len(dict.StringTable) {
+			key = dict.StringTable[attr.KeyStrindex]
+		}
+		errs = errors.Join(errs, warnf("[%d]: %q repeats the filename %q", pos, key, filename))
+	}
+	return errs
+}
+
 func (c ConformanceChecker) checkLocationTable(locTable []*profiles.Location, dict *profiles.ProfilesDictionary) error {
 	var errs error
 	if err := checkZeroVal(locTable); err != nil {
@@ -277,6 +677,75 @@ func (c ConformanceChecker) checkLine(line *profiles.Line, dict *profiles.Profil
 	return errs
 }
 
+// checkDuplicateTimestamps warns once about every run of equal consecutive
+// timestamps, at the index of its first repeat.
+func checkDuplicateTimestamps(timestamps []uint64) error {
+	var errs error
+	for i := 1; i < len(timestamps); i++ {
+		if timestamps[i] != timestamps[i-1] {
+			continue
+		}
+		run := i
+		for i+1 < len(timestamps) && timestamps[i+1] == timestamps[i] {
+			i++
+		}
+		errs = errors.Join(errs, warnf("timestamps_unix_nano[%d]=%d repeats the previous timestamp %d times", run, timestamps[run], i-run+1))
+	}
+	return errs
+}
+
+// checkTimestampSpan warns if the timestamps of the samples of prof span more
+// than its duration_nano.
+func checkTimestampSpan(prof *profiles.Profile) error {
+	var minTs, maxTs uint64
+	found := false
+	for _, s := range prof.Samples {
+		for _, ts := range s.TimestampsUnixNano {
+			if !found {
+				minTs, maxTs, found = ts, ts, true
+				continue
+			}
+			minTs, maxTs = min(minTs, ts), max(maxTs, ts)
+		}
+	}
+	if span := maxTs - minTs; span > prof.DurationNano {
+		return warnf("profile sample timestamp span (%s) exceeds declared duration (%s)", nanosString(span), nanosString(prof.DurationNano))
+	}
+	return nil
+}
+
+// nanosString formats nanos as a time.Duration if it fits.
+func nanosString(nanos uint64) string {
+	if nanos > math.MaxInt64 {
+		return fmt.Sprintf("%d ns", nanos)
+	}
+	return time.Duration(nanos).String()
+}
+
+// checkDroppedAttributes warns if count exceeds MaxDroppedAttributes.
+func (c ConformanceChecker) checkDroppedAttributes(count uint32) error {
+	maxDropped := c.MaxDroppedAttributes
+	if maxDropped == 0 {
+		maxDropped = DefaultMaxDroppedAttributes
+	}
+	if count <= maxDropped {
+		return nil
+	}
+	return warnf("%d exceeds the maximum of %d, counter not reset between exports?", count, maxDropped)
+}
+
+// checkLineNumber warns if line exceeds MaxLineNumber.
+func (c ConformanceChecker) checkLineNumber(line int64) error {
+	maxLine := c.MaxLineNumber
+	if maxLine == 0 {
+		maxLine = DefaultMaxLineNumber
+	}
+	if line <= maxLine {
+		return nil
+	}
+	return warnf("%d exceeds the maximum of %d, byte offset instead of line number?", line, maxLine)
+}
+
 func (c ConformanceChecker) checkFunctionTable(funcTable []*profiles.Function, dict *profiles.ProfilesDictionary) error {
 	var errs error
 	if err := checkZeroVal(funcTable); err != nil {
@@ -305,12 +774,16 @@ func (c ConformanceChecker) checkLinkTable(linkTable []*profiles.Link) error {
 	if err := checkZeroVal(linkTable); err != nil {
 		errs = errors.Join(errs, err)
 	}
-	for idx, link := range linkTable[1:] {
+	for idx, link := range linkTable {
+		// The zero value at index 0 is checked by checkZeroVal.
+		if idx == 0 {
+			continue
+		}
 		if gotLen, wantLen := len(link.TraceId), 16; gotLen != wantLen {
-			errs = errors.Join(errs, fmt.Errorf("len([%d].trace_id) == %d, want %d", idx, gotLen, wantLen))
+			errs = errors.Join(errs, inCheck("link-ids", fmt.Errorf("len([%d].trace_id) == %d, want %d", idx, gotLen, wantLen)))
 		}
 		if gotLen, wantLen := len(link.SpanId), 8; gotLen != wantLen {
-			errs = errors.Join(errs, fmt.Errorf("len([%d].span_id) == %d, want %d", idx, gotLen, wantLen))
+			errs = errors.Join(errs, inCheck("link-ids", fmt.Errorf("len([%d].span_id) == %d, want %d", idx, gotLen, wantLen)))
 		}
 	}
 	// TODO: Add optional uniqueness check.
@@ -319,35 +792,45 @@ func (c ConformanceChecker) checkLinkTable(linkTable []*profiles.Link) error {
 
 func (c ConformanceChecker) checkStringTable(strTable []string) error {
 	if len(strTable) == 0 {
-		return errors.New("empty string table, must have at least empty string")
+		return inCheck("zero-value", errEmptyTable)
 	}
 	if strTable[0] != "" {
-		return fmt.Errorf("must have empty string at index 0, got %q", strTable[0])
+		return zeroValueError(fmt.Sprintf("%q", strTable[0]))
+	}
+	return nil
+}
+
+// checkStringDuplicates reports strings that occur more than once in
+// strTable. A table without the zero value is skipped, checkStringTable
+// reports it.
+func checkStringDuplicates(strTable []string) error {
+	if len(strTable) == 0 || strTable[0] != "" {
+		return nil
 	}
 	var errs error
-	if c.CheckDictionaryDuplicates {
-		strIdxs := map[string]int{}
-		for idx, s := range strTable {
-			if origIdx, ok := strIdxs[s]; ok {
-				errs = errors.Join(errs, fmt.Errorf("duplicate string at index %d, orig index %d: %s", idx, origIdx, s))
-				continue
-			}
-			strIdxs[s] = idx
+	strIdxs := map[string]int{}
+	for idx, s := range strTable {
+		if origIdx, ok := strIdxs[s]; ok {
+			errs = errors.Join(errs, fmt.Errorf("duplicate string at index %d, orig index %d: %s", idx, origIdx, s))
+			continue
 		}
+		strIdxs[s] = idx
 	}
 	return errs
 }
 
-func (c ConformanceChecker) checkAttributeTable(attrTable []*profiles.KeyValueAndUnit, lenStrTable int) error {
+func (c ConformanceChecker) checkAttributeTable(attrTable []*profiles.KeyValueAndUnit, strTable []string) error {
 	var errs error
 	if err := checkAttributeTableZeroVal(attrTable); err != nil {
 		errs = errors.Join(errs, err)
 	}
 	for pos, kvu := range attrTable {
-		if err := c.checkIndex(lenStrTable, kvu.KeyStrindex); err != nil {
+		if err := c.checkIndex(len(strTable), kvu.KeyStrindex); err != nil {
 			errs = errors.Join(errs, prefixErrorf(err, "[%d].key_strindex", pos))
+		} else if err := c.checkAnyValueRefs(kvu.Value, strTable, []string{keyLink("", kvu.KeyStrindex, strTable)}); err != nil {
+			errs = errors.Join(errs, prefixErrorf(err, "[%d].value", pos))
 		}
-		if err := c.checkIndex(lenStrTable, kvu.UnitStrindex); err != nil {
+		if err := c.checkIndex(len(strTable), kvu.UnitStrindex); err != nil {
 			errs = errors.Join(errs, prefixErrorf(err, "[%d].unit_strindex", pos))
 		}
 	}
@@ -355,21 +838,28 @@ func (c ConformanceChecker) checkAttributeTable(attrTable []*profiles.KeyValueAn
 	return errs
 }
 
+// checkUnit warns if unit is set but not one of the known units.
+func (c ConformanceChecker) checkUnit(unit string) error {
+	known := c.KnownUnits
+	if known == nil {
+		known = DefaultKnownUnits
+	}
+	if unit != "" && !slices.Contains(known, unit) {
+		return warnf("%q is not a known unit", unit)
+	}
+	return nil
+}
+
 // checkAttributeTableZeroVal verifies that the AttributeTable meets Profiles
-// dictionary conventions: the slice is not empty and the first entry has zero
-// key and unit indices and the value field holds nil as value.
+// dictionary conventions like checkZeroVal, except that the first entry may
+// hold a value without its oneof set, which marshals like a nil value.
 func checkAttributeTableZeroVal(attrTable []*profiles.KeyValueAndUnit) error {
 	if len(attrTable) == 0 {
-		return errors.New("empty table, must have at least zero value entry")
+		return inCheck("zero-value", errEmptyTable)
 	}
 	first := attrTable[0]
-	if first.KeyStrindex != 0 || first.UnitStrindex != 0 {
-		return fmt.Errorf("first attribute must have zero key/unit indices, got KeyStrindex=%d, UnitStrindex=%d",
-			first.KeyStrindex, first.UnitStrindex)
-	}
-	value := first.GetValue()
-	if value != nil && value.Value != nil {
-		return fmt.Errorf("first attribute value must be nil, got %v", value)
+	if first.KeyStrindex != 0 || first.UnitStrindex != 0 || first.GetValue().GetValue() != nil {
+		return zeroValueError(fmt.Sprintf("{%v}", first))
 	}
 	return nil
 }
@@ -386,7 +876,95 @@ func (c ConformanceChecker) checkStackTable(stackTable []*profiles.Stack, lenLoc
 			}
 		}
 	}
-	// TODO: Add optional uniqueness check.
+	return errs
+}
+
+// checkStackDuplicates reports stacks with the same location indices as an
+// earlier stack of stackTable.
+func checkStackDuplicates(stackTable []*profiles.Stack) error {
+	var errs error
+	// Keying the map by the encoded indices hashes them once per stack
+	// instead of comparing the slices pairwise.
+	stackIdxs := map[string]int{}
+	var key []byte
+	for idx, stack := range stackTable {
+		key = key[:0]
+		for _, locIndex := range stack.LocationIndices {
+			key = binary.LittleEndian.AppendUint32(key, uint32(locIndex))
+		}
+		if origIdx, ok := stackIdxs[string(key)]; ok {
+			errs = errors.Join(errs, fmt.Errorf("duplicate stack at index %d, orig index %d: %v", idx, origIdx, stack.LocationIndices))
+			continue
+		}
+		stackIdxs[string(key)] = idx
+	}
+	return errs
+}
+
+// checkStackPlausibility warns about stacks whose frames don't fit the memory
+// layout described by the mapping table. Locations without a mapping, and
+// invalid indices, are skipped as they are either legitimate or reported by
+// other checks.
+func checkStackPlausibility(dict *profiles.ProfilesDictionary) error {
+	mappings, locs := dict.GetMappingTable(), dict.GetLocationTable()
+	// mappingOf returns the mapping of the location at locIdx if it has a
+	// memory range.
+	mappingOf := func(locIdx int32) (int32, *profiles.Mapping) {
+		if locIdx <= 0 || int(locIdx) >= len(locs) {
+			return 0, nil
+		}
+		mIdx := locs[locIdx].MappingIndex
+		if mIdx <= 0 || int(mIdx) >= len(mappings) {
+			return 0, nil
+		}
+		if m := mappings[mIdx]; m.MemoryStart < m.MemoryLimit {
+			return mIdx, m
+		}
+		return 0, nil
+	}
+
+	var errs error
+	for i, stack := range dict.GetStackTable() {
+		for j := 1; j < len(stack.LocationIndices); j++ {
+			prevIdx, prev := mappingOf(stack.LocationIndices[j-1])
+			curIdx, cur := mappingOf(stack.LocationIndices[j])
+			if prev == nil || cur == nil || prevIdx == curIdx {
+				continue
+			}
+			// Mappings of a single process can't overlap, so a stack moving
+			// between overlapping mappings mixes up address spaces.
+			if cur.MemoryStart < prev.MemoryLimit && prev.MemoryStart < cur.MemoryLimit {
+				errs = errors.Join(errs, warnf("stack_table[%d].location_indices[%d]: mapping_table[%d] overlaps mapping_table[%d] of the previous frame", i, j, curIdx, prevIdx))
+			}
+		}
+	}
+	return errs
+}
+
+// checkNilEntries reports nil entries in the dictionary tables, including the
+// lines of locations.
+func checkNilEntries(dict *profiles.ProfilesDictionary) error {
+	errs := errors.Join(
+		nilEntries("mapping_table", dict.GetMappingTable()),
+		nilEntries("location_table", dict.GetLocationTable()),
+		nilEntries("function_table", dict.GetFunctionTable()),
+		nilEntries("link_table", dict.GetLinkTable()),
+		nilEntries("attribute_table", dict.GetAttributeTable()),
+		nilEntries("stack_table", dict.GetStackTable()),
+	)
+	for i, loc := range dict.GetLocationTable() {
+		errs = errors.Join(errs, nilEntries(fmt.Sprintf("location_table[%d].line", i), loc.GetLines()))
+	}
+	return errs
+}
+
+func nilEntries[T any](name string, table []*T) error {
+	var errs error
+	for i, entry := range table {
+		if entry == nil {
+			errs = errors.Join(errs, fmt.Errorf("%s[%d] is nil", name, i))
+		}
+	}
 	return errs
 }
 
@@ -397,98 +975,33 @@ func checkZeroVal[T any, P interface {
 	proto.Message
 }](table []P) error {
 	if len(table) == 0 {
-		return errors.New("empty table, must have at least zero value entry")
+		return inCheck("zero-value", errEmptyTable)
 	}
 	var zeroVal P = new(T)
 	if !proto.Equal(table[0], zeroVal) {
-		return fmt.Errorf("must have zero value %#v at index 0, got %#v", zeroVal, table[0])
+		return zeroValueError(fmt.Sprintf("{%v}", table[0]))
 	}
 	return nil
 }
 
+// errEmptyTable is the error of a dictionary table without the zero value
+// entry.
+var errEmptyTable = errors.New("empty table, must have the zero value at index 0")
+
+// zeroValueError returns the error of a dictionary table whose entry at index
+// 0, formatted as got, is not the zero value. Every table reports it the same
+// way, so that the findings of the tables can be told apart by path only.
+func zeroValueError(got string) error {
+	return inCheck("zero-value", fmt.Errorf("must have the zero value at index 0, got %s", got))
+}
+
 // checkDictionaryOrphans verifies that every entry in every table of the
 // dictionary is referenced.
 func (c ConformanceChecker) checkDictionaryOrphans(data *profiles.ProfilesData) error {
 	dict := data.Dictionary
-
-	strRefs := make(map[int32]bool)
-	attrRefs := make(map[int32]bool)
-	mappingRefs := make(map[int32]bool)
-	funcRefs := make(map[int32]bool)
-	locRefs := make(map[int32]bool)
-	stackRefs := make(map[int32]bool)
-	linkRefs := make(map[int32]bool)
-
-	// Index 0 is the mandatory zero-value sentinel in every table and is always
-	// considered referenced.
-	strRefs[0] = true
-	attrRefs[0] = true
-	mappingRefs[0] = true
-	funcRefs[0] = true
-	locRefs[0] = true
-	stackRefs[0] = true
-	linkRefs[0] = true
-
-	// Collect references from all profiles.
-	for _, rp := range data.ResourceProfiles {
-		for _, sp := range rp.ScopeProfiles {
-			for _, prof := range sp.Profiles {
-				strRefs[prof.GetSampleType().GetTypeStrindex()] = true
-				strRefs[prof.GetSampleType().GetUnitStrindex()] = true
-				strRefs[prof.GetPeriodType().GetTypeStrindex()] = true
-				strRefs[prof.GetPeriodType().GetUnitStrindex()] = true
-				for _, idx := range prof.AttributeIndices {
-					attrRefs[idx] = true
-				}
-				for _, s := range prof.Samples {
-					stackRefs[s.StackIndex] = true
-					linkRefs[s.LinkIndex] = true
-					for _, idx := range s.AttributeIndices {
-						attrRefs[idx] = true
-					}
-				}
-			}
-		}
-	}
-
-	// Collect references from StackTable to LocationTable.
-	for _, stack := range dict.StackTable {
-		for _, idx := range stack.LocationIndices {
-			locRefs[idx] = true
-		}
-	}
-
-	// Collect references from LocationTable to MappingTable, FunctionTable, AttributeTable.
-	for _, loc := range dict.LocationTable {
-		mappingRefs[loc.MappingIndex] = true
-		for _, idx := range loc.AttributeIndices {
-			attrRefs[idx] = true
-		}
-		for _, line := range loc.Lines {
-			funcRefs[line.FunctionIndex] = true
-		}
-	}
-
-	// Collect references from MappingTable to StringTable, AttributeTable.
-	for _, m := range dict.MappingTable {
-		strRefs[m.FilenameStrindex] = true
-		for _, idx := range m.AttributeIndices {
-			attrRefs[idx] = true
-		}
-	}
-
-	// Collect references from FunctionTable to StringTable.
-	for _, fnc := range dict.FunctionTable {
-		strRefs[fnc.NameStrindex] = true
-		strRefs[fnc.SystemNameStrindex] = true
-		strRefs[fnc.FilenameStrindex] = true
-	}
-
-	// Collect references from AttributeTable to StringTable.
-	for _, kvu := range dict.AttributeTable {
-		strRefs[kvu.KeyStrindex] = true
-		strRefs[kvu.UnitStrindex] = true
-	}
+	refs := collectReferences(data)
+	strRefs, attrRefs, mappingRefs, funcRefs := refs.str, refs.attr, refs.mapping, refs.function
+	locRefs, stackRefs, linkRefs := refs.location, refs.stack, refs.link
 
 	var errs error
 	for idx := range dict.StringTable {
@@ -529,6 +1042,60 @@ func (c ConformanceChecker) checkDictionaryOrphans(data *profiles.ProfilesData)
 	return errs
 }
 
+// dictionaryRefs holds the indices of every dictionary table that are
+// referenced from the profiles or from other dictionary tables.
+type dictionaryRefs struct {
+	str, attr, mapping, function, location, stack, link map[int32]bool
+}
+
+func collectReferences(data *profiles.ProfilesData) dictionaryRefs {
+	refs := dictionaryRefs{
+		str:      make(map[int32]bool),
+		attr:     make(map[int32]bool),
+		mapping:  make(map[int32]bool),
+		function: make(map[int32]bool),
+		location: make(map[int32]bool),
+		stack:    make(map[int32]bool),
+		link:     make(map[int32]bool),
+	}
+	// Index 0 is the mandatory zero-value sentinel in every table and is always
+	// considered referenced.
+	mark := func(table map[int32]bool) func(*int32) {
+		table[0] = true
+		return func(idx *int32) { table[*idx] = true }
+	}
+	v := indexVisitor{
+		str:      mark(refs.str),
+		attr:     mark(refs.attr),
+		mapping:  mark(refs.mapping),
+		function: mark(refs.function),
+		location: mark(refs.location),
+		stack:    mark(refs.stack),
+		link:     mark(refs.link),
+	}
+
+	// Collect references from all profiles and from every dictionary entry,
+	// whether or not the entry itself is referenced.
+	v.profiles(data)
+	dict := data.GetDictionary()
+	for _, stack := range dict.GetStackTable() {
+		v.stackEntry(stack)
+	}
+	for _, loc := range dict.GetLocationTable() {
+		v.locationEntry(loc)
+	}
+	for _, m := range dict.GetMappingTable() {
+		v.mappingEntry(m)
+	}
+	for _, fnc := range dict.GetFunctionTable() {
+		v.functionEntry(fnc)
+	}
+	for _, kvu := range dict.GetAttributeTable() {
+		v.attributeEntry(kvu)
+	}
+	return refs
+}
+
 func (c ConformanceChecker) checkAttributeIndices(attrIndices []int32, dict *profiles.ProfilesDictionary) error {
 	var errs error
 	keys := map[string]int{}
@@ -544,7 +1111,7 @@ func (c ConformanceChecker) checkAttributeIndices(attrIndices []int32, dict *pro
 		}
 		key := dict.StringTable[attr.KeyStrindex]
 		if prevPos, ok := keys[key]; ok {
-			errs = errors.Join(errs, fmt.Errorf("[%d].key_strindex: duplicate key %q, previously seen at [%d].key_strindex", pos, key, prevPos))
+			errs = errors.Join(errs, inCheck("attribute-keys", fmt.Errorf("[%d].key_strindex: duplicate key %q, previously seen at [%d].key_strindex", pos, key, prevPos)))
 		} else {
 			keys[key] = pos
 		}
@@ -552,28 +1119,257 @@ func (c ConformanceChecker) checkAttributeIndices(attrIndices []int32, dict *pro
 	return errs
 }
 
+// checkKeyValues verifies resource and scope attributes, which may reference
+// the string table for their keys and string values, and detects duplicate
+// keys the same way checkAttributeIndices does.
+func (c ConformanceChecker) checkKeyValues(attrs []*common.KeyValue, dict *profiles.ProfilesDictionary) error {
+	var errs error
+	keys := map[string]int{}
+	for pos, kv := range attrs {
+		key := kv.Key
+		if kv.KeyStrindex != 0 {
+			if kv.Key != "" {
+				errs = errors.Join(errs, inCheck("attribute-keys", fmt.Errorf("[%d]: key %q and key_strindex %d must not both be set", pos, kv.Key, kv.KeyStrindex)))
+			}
+			if err := c.checkIndex(len(dict.StringTable), kv.KeyStrindex); err != nil {
+				errs = errors.Join(errs, prefixErrorf(err, "[%d].key_strindex", pos))
+				continue
+			}
+			key = dict.StringTable[kv.KeyStrindex]
+		}
+		if err := c.checkAnyValueRefs(kv.Value, dict.StringTable, []string{keyLink(kv.Key, kv.KeyStrindex, dict.StringTable)}); err != nil {
+			errs = errors.Join(errs, prefixErrorf(err, "[%d].value", pos))
+		}
+		if prevPos, ok := keys[key]; ok {
+			errs = errors.Join(errs, inCheck("attribute-keys", fmt.Errorf("[%d]: duplicate key %q, previously seen at [%d]", pos, key, prevPos)))
+		} else {
+			keys[key] = pos
+		}
+	}
+	return errs
+}
+
+// checkAnyValueRefs verifies the string table references of v, including
+// those of the keys and values nested in its arrays and key-value lists. chain
+// is the resolution of the references that lead to v, e.g. the key of its
+// attribute, and is reported with every dangling reference, so that producers
+// can follow it through their dictionary.
+func (c ConformanceChecker) checkAnyValueRefs(v *common.AnyValue, strTable []string, chain []string) error {
+	var errs error
+	switch v := v.GetValue().(type) {
+	case *common.AnyValue_StringValueStrindex:
+		if err := c.checkIndex(len(strTable), v.StringValueStrindex); err != nil {
+			errs = prefixErrorf(danglingRef(err, chain, fmt.Sprintf("string_value_strindex %d", v.StringValueStrindex)), "string_value_strindex")
+		}
+	case *common.AnyValue_ArrayValue:
+		for i, elem := range v.ArrayValue.GetValues() {
+			if err := c.checkAnyValueRefs(elem, strTable, append(slices.Clip(chain), fmt.Sprintf("[%d]", i))); err != nil {
+				errs = errors.Join(errs, prefixErrorf(err, "array_value.values[%d]", i))
+			}
+		}
+	case *common.AnyValue_KvlistValue:
+		for i, kv := range v.KvlistValue.GetValues() {
+			if kv.KeyStrindex != 0 {
+				if err := c.checkIndex(len(strTable), kv.KeyStrindex); err != nil {
+					err = danglingRef(err, chain, fmt.Sprintf("key_strindex %d", kv.KeyStrindex))
+					errs = errors.Join(errs, prefixErrorf(err, "kvlist_value.values[%d].key_strindex", i))
+					continue
+				}
+			}
+			if err := c.checkAnyValueRefs(kv.Value, strTable, append(slices.Clip(chain), keyLink(kv.Key, kv.KeyStrindex, strTable))); err != nil {
+				errs = errors.Join(errs, prefixErrorf(err, "kvlist_value.values[%d].value", i))
+			}
+		}
+	}
+	return errs
+}
+
+// keyLink describes the key of an attribute as a link of the chain of
+// checkAnyValueRefs, with the string it resolves to if it is a string table
+// reference. An out of range reference is described by its index only.
+func keyLink(key string, keyStrindex int32, strTable []string) string {
+	if keyStrindex == 0 {
+		return fmt.Sprintf("key %q", key)
+	}
+	if keyStrindex < 0 || int(keyStrindex) >= len(strTable) {
+		return fmt.Sprintf("key_strindex %d", keyStrindex)
+	}
+	return fmt.Sprintf("key_strindex %d %q", keyStrindex, strTable[keyStrindex])
+}
+
+// danglingRef adds the chain of references leading to the dangling reference
+// last to err.
+func danglingRef(err error, chain []string, last string) error {
+	return fmt.Errorf("%w, resolving %s", err, strings.Join(append(slices.Clip(chain), last), " -> "))
+}
+
+// checkEntityRefs verifies that every entity reference has a type and at
+// least one id key, and that its id and description keys exist in attrs, the
+// attributes of the containing message. Attributes with out of range keys are
+// skipped, checkKeyValues reports them.
+func checkEntityRefs(refs []*common.EntityRef, attrs []*common.KeyValue, dict *profiles.ProfilesDictionary) error {
+	if len(refs) == 0 {
+		return nil
+	}
+	keys := map[string]bool{}
+	for _, kv := range attrs {
+		key := kv.Key
+		if idx := kv.KeyStrindex; idx != 0 {
+			if idx < 0 || int(idx) >= len(dict.StringTable) {
+				continue
+			}
+			key = dict.StringTable[idx]
+		}
+		keys[key] = true
+	}
+	var errs error
+	for i, ref := range refs {
+		if ref == nil {
+			errs = errors.Join(errs, fmt.Errorf("[%d]: is nil", i))
+			continue
+		}
+		if ref.Type == "" {
+			errs = errors.Join(errs, fmt.Errorf("[%d].type: must not be empty", i))
+		}
+		if len(ref.IdKeys) == 0 {
+			errs = errors.Join(errs, fmt.Errorf("[%d].id_keys: must not be empty", i))
+		}
+		for _, field := range []struct {
+			name string
+			keys []string
+		}{
+			{"id_keys", ref.IdKeys},
+			{"description_keys", ref.DescriptionKeys},
+		} {
+			for j, key := range field.keys {
+				if !keys[key] {
+					errs = errors.Join(errs, fmt.Errorf("[%d].%s[%d]: %q is not a key of the resource attributes", i, field.name, j, key))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// resolveKeyValues returns attrs keyed by their key, with keys and string
+// values resolved from the string table. Attributes with out of range string
+// table references are skipped, checkKeyValues reports them.
+func resolveKeyValues(attrs []*common.KeyValue, dict *profiles.ProfilesDictionary) map[string]*common.AnyValue {
+	resolved := map[string]*common.AnyValue{}
+	strTable := dict.GetStringTable()
+	for _, kv := range attrs {
+		key := kv.GetKey()
+		if idx := kv.GetKeyStrindex(); idx != 0 {
+			if idx < 0 || int(idx) >= len(strTable) {
+				continue
+			}
+			key = strTable[idx]
+		}
+		value, ok := resolveAnyValue(kv.GetValue(), strTable)
+		if !ok {
+			continue
+		}
+		resolved[key] = value
+	}
+	return resolved
+}
+
+// resolveAnyValue returns v with a string table reference replaced by the
+// referenced string. It returns false if the reference is out of range.
+func resolveAnyValue(v *common.AnyValue, strTable []string) (*common.AnyValue, bool) {
+	ref, ok := v.GetValue().(*common.AnyValue_StringValueStrindex)
+	if !ok {
+		return v, true
+	}
+	if ref.StringValueStrindex < 0 || int(ref.StringValueStrindex) >= len(strTable) {
+		return nil, false
+	}
+	return &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: strTable[ref.StringValueStrindex]}}, true
+}
+
+// checkRedundantAttributes warns about attributes referenced by attrIndices
+// that have the same key and value as one of resourceAttrs. The indices must
+// have been checked by checkAttributeIndices.
+func checkRedundantAttributes(attrIndices []int32, dict *profiles.ProfilesDictionary, resourceAttrs map[string]*common.AnyValue) error {
+	if len(resourceAttrs) == 0 {
+		return nil
+	}
+	var errs error
+	for pos, attrIdx := range attrIndices {
+		attr := dict.AttributeTable[attrIdx]
+		key := dict.StringTable[attr.KeyStrindex]
+		resourceValue, ok := resourceAttrs[key]
+		if !ok {
+			continue
+		}
+		if value, ok := resolveAnyValue(attr.Value, dict.StringTable); ok && proto.Equal(value, resourceValue) {
+			errs = errors.Join(errs, warnf("[%d]: %q repeats the resource attribute with the same value", pos, key))
+		}
+	}
+	return errs
+}
+
+// checkSemanticValue verifies that the value of a well-known semantic
+// convention attribute has the type the conventions prescribe.
+func checkSemanticValue(key string, value *common.AnyValue) error {
+	want, ok := semconvValueKinds[key]
+	if !ok {
+		return nil
+	}
+	if got := anyValueKind(value); got != want {
+		return fmt.Errorf("%q has %s value, semantic conventions require %s", key, got, want)
+	}
+	return nil
+}
+
 func (c ConformanceChecker) checkIndex(length int, idx int32) error {
 	if idx < 0 || int(idx) >= length {
-		return fmt.Errorf("index %d is out of range [0..%d)", idx, length)
+		return inCheck("index-range", fmt.Errorf("index %d is out of range [0..%d)", idx, length))
 	}
 	return nil
 }
 
 func (c ConformanceChecker) checkNonNegative(n int64) error {
 	if n < 0 {
-		return fmt.Errorf("%d < 0, must be non-negative", n)
+		return inCheck("non-negative", fmt.Errorf("%d < 0, must be non-negative", n))
 	}
 	return nil
 }
 
+// prefixErrorf prefixes every error joined in err with the formatted path.
 func prefixErrorf(err error, format string, args ...any) error {
 	prefix := fmt.Sprintf(format, args...)
-	if merr, ok := err.(interface{ Unwrap() []error }); ok {
-		errs := merr.Unwrap()
-		for i, e := range errs {
-			errs[i] = fmt.Errorf("%s: %w", prefix, e)
-		}
-		return errors.Join(errs...)
+	errs := flattenErrors(err)
+	for i, e := range errs {
+		errs[i] = pathError{prefix: prefix, err: e}
 	}
-	return fmt.Errorf("%s: %w", prefix, err)
+	return errors.Join(errs...)
+}
+
+// pathError is an error prefixed with a path by prefixErrorf. The prefixes
+// are kept apart from the message so that Report can return them as
+// Finding.Path.
+type pathError struct {
+	prefix string
+	err    error
+}
+
+func (e pathError) Error() string { return e.prefix + ": " + e.err.Error() }
+func (e pathError) Unwrap() error { return e.err }
+
+// flattenErrors returns the errors joined in err, recursively. Repeated
+// errors.Join(errs, err) calls nest, so the joined errors are a tree.
+func flattenErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	merr, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var errs []error
+	for _, e := range merr.Unwrap() {
+		errs = append(errs, flattenErrors(e)...)
+	}
+	return errs
 }
@@ -2,6 +2,7 @@ package profcheck
 
 import (
 	"errors"
+	"slices"
 	"strings"
 	"testing"
 
@@ -9,9 +10,44 @@ import (
 
 	common "go.opentelemetry.io/proto/otlp/common/v1"
 	profiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
 )
 
-func TestCheckConformance(t *testing.T) {
+type conformanceTestCase struct {
+	desc              string
+	data              *profiles.ProfilesData
+	disableDupesCheck bool
+	checkSampleShapes bool
+	checkReferences   bool
+	checkSemconv      bool
+	checkSampleType   bool
+	checkZeroValues   bool
+	checkStacks       bool
+	checkAddressRange bool
+	checkDuration     bool
+	checkLinks        bool
+	checkScopeUnits   bool
+	checkUniqueness   bool
+	checkFilenames    bool
+	checkZeroAttrs    bool
+	checkLines        bool
+	checkNegatives    bool
+	checkUnits        bool
+	checkDropped      bool
+	checkDupTimes     bool
+	checkTimeSpan     bool
+	// strict uses StrictConformanceChecker instead of the check* fields,
+	// with RequireSamples unset if allowEmpty is set.
+	strict     bool
+	allowEmpty bool
+	wantErr    string
+	// wantWarning is a warning finding reported in addition to wantErr.
+	wantWarning string
+}
+
+// conformanceTestCases are the cases of TestCheckConformance. They also seed
+// FuzzCheck.
+func conformanceTestCases() []conformanceTestCase {
 	zeroDictionary := &profiles.ProfilesDictionary{
 		MappingTable:   []*profiles.Mapping{{}},
 		LocationTable:  []*profiles.Location{{}},
@@ -27,14 +63,7 @@ func TestCheckConformance(t *testing.T) {
 		return ret
 	}
 
-	for _, tc := range []struct {
-		desc              string
-		data              *profiles.ProfilesData
-		disableDupesCheck bool
-		checkSampleShapes bool
-		checkReferences   bool
-		wantErr           string
-	}{{
+	return []conformanceTestCase{{
 		desc:    "no profiles",
 		data:    &profiles.ProfilesData{},
 		wantErr: "resource profiles are empty",
@@ -50,6 +79,55 @@ func TestCheckConformance(t *testing.T) {
 		},
 		wantErr: "",
 	}, {
+		desc: "no dictionary",
+		data: &profiles.ProfilesData{
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{}},
+				}},
+			}},
+		},
+		wantErr: "dictionary is missing",
+	}, {
+		desc: "nil dictionary entries",
+		data: &profiles.ProfilesData{
+			Dictionary: &profiles.ProfilesDictionary{
+				MappingTable:   []*profiles.Mapping{{}},
+				LocationTable:  []*profiles.Location{{}, {Lines: []*profiles.Line{nil}}},
+				FunctionTable:  []*profiles.Function{{}},
+				LinkTable:      []*profiles.Link{{}},
+				StringTable:    []string{""},
+				AttributeTable: []*profiles.KeyValueAndUnit{{}, nil},
+				StackTable:     []*profiles.Stack{{}},
+			},
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{
+						Samples: []*profiles.Sample{{AttributeIndices: []int32{1}}},
+					}},
+				}},
+			}},
+		},
+		wantErr: "dictionary: attribute_table[1] is nil\n" +
+			"dictionary: location_table[1].line[0] is nil",
+	}, {
+		desc: "empty link table",
+		data: &profiles.ProfilesData{
+			Dictionary: &profiles.ProfilesDictionary{
+				MappingTable:   []*profiles.Mapping{{}},
+				LocationTable:  []*profiles.Location{{}},
+				FunctionTable:  []*profiles.Function{{}},
+				StringTable:    []string{""},
+				AttributeTable: []*profiles.KeyValueAndUnit{{}},
+				StackTable:     []*profiles.Stack{{}},
+			},
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{}},
+				}},
+			}},
+		},
+		wantErr: "link_table"}, {
 		desc: "no scope profiles",
 		data: &profiles.ProfilesData{
 			Dictionary:       zeroDictionary,
@@ -75,7 +153,7 @@ func TestCheckConformance(t *testing.T) {
 				}},
 			}},
 		},
-		wantErr: "must have empty string at index 0",
+		wantErr: "string_table: must have the zero value at index 0",
 	}, {
 		desc: "duplicate string",
 		data: &profiles.ProfilesData{
@@ -99,6 +177,30 @@ func TestCheckConformance(t *testing.T) {
 		},
 		disableDupesCheck: true,
 		wantErr:           "",
+	}, {
+		desc: "duplicate stack",
+		data: &profiles.ProfilesData{
+			Dictionary: &profiles.ProfilesDictionary{
+				MappingTable:   []*profiles.Mapping{{}},
+				LocationTable:  []*profiles.Location{{}, {Address: 0x10}, {Address: 0x20}},
+				FunctionTable:  []*profiles.Function{{}},
+				LinkTable:      []*profiles.Link{{}},
+				StringTable:    []string{""},
+				AttributeTable: []*profiles.KeyValueAndUnit{{}},
+				StackTable: []*profiles.Stack{
+					{},
+					{LocationIndices: []int32{1, 2}},
+					{LocationIndices: []int32{2, 1}},
+					{LocationIndices: []int32{1, 2}},
+				},
+			},
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{}},
+				}},
+			}},
+		},
+		wantErr: "stack_table: duplicate stack at index 3, orig index 1: [1 2]",
 	}, {
 		desc: "duplicate attribute key in location",
 		data: &profiles.ProfilesData{
@@ -125,6 +227,105 @@ func TestCheckConformance(t *testing.T) {
 			}},
 		},
 		wantErr: `duplicate key "k1"`,
+	}, {
+		desc: "duplicate resource attribute key",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictWithStringTable([]string{"", "service.name"}),
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				Resource: &resource.Resource{
+					Attributes: []*common.KeyValue{
+						{Key: "service.name", Value: makeAnyValue("a")},
+						{KeyStrindex: 1, Value: makeAnyValue("b")},
+					},
+				},
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{}},
+				}},
+			}},
+		},
+		wantErr: `resource_profiles[0]: resource.attributes: [1]: duplicate key "service.name"`,
+	}, {
+		desc: "dangling string reference nested in a resource attribute",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictWithStringTable([]string{"", "k8s.labels", "app"}),
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				Resource: &resource.Resource{
+					Attributes: []*common.KeyValue{{
+						KeyStrindex: 1,
+						Value: &common.AnyValue{Value: &common.AnyValue_KvlistValue{KvlistValue: &common.KeyValueList{
+							Values: []*common.KeyValue{{
+								KeyStrindex: 2,
+								Value:       &common.AnyValue{Value: &common.AnyValue_StringValueStrindex{StringValueStrindex: 9}},
+							}},
+						}}},
+					}},
+				},
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{}},
+				}},
+			}},
+		},
+		wantErr: `resource.attributes: [0].value: kvlist_value.values[0].value: string_value_strindex: index 9 is out of range [0..3), resolving key_strindex 1 "k8s.labels" -> key_strindex 2 "app" -> string_value_strindex 9`,
+	}, {
+		desc: "dangling string reference nested in an attribute table entry",
+		data: &profiles.ProfilesData{
+			Dictionary: &profiles.ProfilesDictionary{
+				MappingTable:  []*profiles.Mapping{{}},
+				LocationTable: []*profiles.Location{{}},
+				FunctionTable: []*profiles.Function{{}},
+				LinkTable:     []*profiles.Link{{}},
+				StringTable:   []string{"", "tags"},
+				AttributeTable: []*profiles.KeyValueAndUnit{{}, {
+					KeyStrindex: 1,
+					Value: &common.AnyValue{Value: &common.AnyValue_ArrayValue{ArrayValue: &common.ArrayValue{
+						Values: []*common.AnyValue{
+							makeAnyValue("a"),
+							{Value: &common.AnyValue_StringValueStrindex{StringValueStrindex: -1}},
+						},
+					}}},
+				}},
+				StackTable: []*profiles.Stack{{}},
+			},
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{}},
+				}},
+			}},
+		},
+		wantErr: `attribute_table: [1].value: array_value.values[1]: string_value_strindex: index -1 is out of range [0..2), resolving key_strindex 1 "tags" -> [1] -> string_value_strindex -1`,
+	}, {
+		desc: "duplicate scope attribute key",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictionary,
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Scope: &common.InstrumentationScope{
+						Attributes: []*common.KeyValue{
+							{Key: "k1", Value: makeAnyValue("a")},
+							{Key: "k1", Value: makeAnyValue("b")},
+						},
+					},
+					Profiles: []*profiles.Profile{{}},
+				}},
+			}},
+		},
+		wantErr: `scope_profiles[0]: scope.attributes: [1]: duplicate key "k1"`,
+	}, {
+		desc: "resource attribute key_strindex out of range",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictionary,
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				Resource: &resource.Resource{
+					Attributes: []*common.KeyValue{
+						{KeyStrindex: 5, Value: makeAnyValue("a")},
+					},
+				},
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{}},
+				}},
+			}},
+		},
+		wantErr: "resource.attributes: [0].key_strindex: index 5 is out of range",
 	}, {
 		desc: "timestamp before start",
 		data: &profiles.ProfilesData{
@@ -258,6 +459,41 @@ func TestCheckConformance(t *testing.T) {
 		},
 		checkSampleShapes: true,
 		wantErr:           "must contain a single element if timestamps_unix_nano is not set",
+	}, {
+		desc: "sample with multiple values without shape checks",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictionary,
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{
+						Samples: []*profiles.Sample{{
+							Values: []int64{1},
+						}, {
+							Values: []int64{1, 2},
+						}},
+					}},
+				}},
+			}},
+		},
+		wantErr: "sample[1]: values (len=2) must contain a single element if timestamps_unix_nano is not set",
+	}, {
+		desc: "sample with values paired with timestamps",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictionary,
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{
+						TimeUnixNano: 100,
+						DurationNano: 10,
+						Samples: []*profiles.Sample{{
+							Values:             []int64{1, 2},
+							TimestampsUnixNano: []uint64{100, 101},
+						}},
+					}},
+				}},
+			}},
+		},
+		wantErr: "",
 	}, {
 		desc: "sample with timestamps only",
 		data: &profiles.ProfilesData{
@@ -511,37 +747,740 @@ func TestCheckConformance(t *testing.T) {
 		},
 		checkReferences: true,
 		wantErr:         "",
-	}} {
-		t.Run(tc.desc, func(t *testing.T) {
-			c := ConformanceChecker{CheckDictionaryDuplicates: !tc.disableDupesCheck, CheckSampleTimestampShape: tc.checkSampleShapes, CheckDictionaryOrphans: tc.checkReferences}
-			err := c.Check(tc.data)
-			switch {
-			case tc.wantErr == "" && err != nil:
-				t.Errorf("Check(): got error %q, want no error", err)
-			case tc.wantErr == "" && err == nil:
-				break
-			case err == nil:
-				t.Errorf("Check(): got no error, want error containing %q", tc.wantErr)
-			case !strings.Contains(err.Error(), tc.wantErr):
-				t.Errorf("Check(): got error %q, want error containing %q", err, tc.wantErr)
-			}
-		})
-	}
-}
-
-func TestPrefixErrorf(t *testing.T) {
-	for _, tc := range []struct {
-		desc string
-		err  error
-		want string
-	}{{
-		desc: "single error",
-		err:  errors.New("error 1"),
-		want: "prefix: error 1",
 	}, {
-		desc: "multiple errors",
-		err:  errors.Join(errors.New("error 1"), errors.New("error 2")),
-		want: "prefix: error 1\nprefix: error 2",
+		desc: "semconv attribute with wrong value type",
+		data: &profiles.ProfilesData{
+			Dictionary: &profiles.ProfilesDictionary{
+				MappingTable:  []*profiles.Mapping{{}},
+				LocationTable: []*profiles.Location{{}},
+				FunctionTable: []*profiles.Function{{}},
+				LinkTable:     []*profiles.Link{{}},
+				StringTable:   []string{"", "thread.id", "process.pid"},
+				AttributeTable: []*profiles.KeyValueAndUnit{
+					{},
+					{KeyStrindex: 1, Value: makeAnyValue[int64](7)},
+					{KeyStrindex: 2, Value: makeAnyValue("1234")},
+				},
+				StackTable: []*profiles.Stack{{}},
+			},
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{
+						Samples: []*profiles.Sample{{
+							AttributeIndices: []int32{1, 2},
+						}},
+					}},
+				}},
+			}},
+		},
+		checkSemconv: true,
+		wantErr:      `sample[0]: attribute_indices: [1].value: "process.pid" has string value, semantic conventions require int`,
+	}, {
+		desc: "semconv resource attribute with wrong value type",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictionary,
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				Resource: &resource.Resource{
+					Attributes: []*common.KeyValue{{Key: "service.name", Value: makeAnyValue[int64](1)}},
+				},
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{}},
+				}},
+			}},
+		},
+		checkSemconv: true,
+		wantErr:      `resource.attributes: [0].value: "service.name" has int value, semantic conventions require string`,
+	}, {
+		desc: "semconv attribute with wrong value type, check disabled",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictionary,
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				Resource: &resource.Resource{
+					Attributes: []*common.KeyValue{{Key: "service.name", Value: makeAnyValue[int64](1)}},
+				},
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{}},
+				}},
+			}},
+		},
+		wantErr: "",
+	}, {
+		desc: "unset sample type",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictionary,
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{
+						Samples: []*profiles.Sample{{}},
+					}},
+				}},
+			}},
+		},
+		checkSampleType: true,
+		wantErr:         "profile[0]: sample_type: type_strindex: must not reference the empty string",
+	}, {
+		desc: "unset sample type in profile without samples",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictionary,
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{}},
+				}},
+			}},
+		},
+		checkSampleType: true,
+		wantErr:         "",
+	}, {
+		desc: "sample type without unit",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictWithStringTable([]string{"", "cpu"}),
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{
+						SampleType: &profiles.ValueType{TypeStrindex: 1},
+						Samples:    []*profiles.Sample{{}},
+					}},
+				}},
+			}},
+		},
+		checkSampleType: true,
+		wantErr:         "profile[0]: sample_type: unit_strindex: must not reference the empty string",
+	}, {
+		desc: "sample type resolving to the empty string",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictWithStringTable([]string{"", "cpu", ""}),
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{
+						SampleType: &profiles.ValueType{TypeStrindex: 1, UnitStrindex: 2},
+						Samples:    []*profiles.Sample{{}},
+					}},
+				}},
+			}},
+		},
+		disableDupesCheck: true,
+		checkSampleType:   true,
+		wantErr:           "profile[0]: sample_type: unit_strindex: 2 resolves to the empty string, the field is effectively unset",
+	}, {
+		desc: "sample type set",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictWithStringTable([]string{"", "cpu", "nanoseconds"}),
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{
+						SampleType: &profiles.ValueType{TypeStrindex: 1, UnitStrindex: 2},
+					}},
+				}},
+			}},
+		},
+		checkSampleType: true,
+		wantErr:         "",
+	}, {
+		desc: "minimal valid profile, strict",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictionary,
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{}},
+				}},
+			}},
+		},
+		strict:  true,
+		wantErr: "profile[0]: profile has no samples",
+	}, {
+		desc: "minimal valid profile, strict allowing empty profiles",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictionary,
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{}},
+				}},
+			}},
+		},
+		strict:     true,
+		allowEmpty: true,
+		wantErr:    "",
+	}, {
+		desc: "all zero sample values",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictionary,
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{
+						TimeUnixNano: 10,
+						DurationNano: 10,
+						Samples: []*profiles.Sample{
+							{Values: []int64{1}},
+							{Values: []int64{0, 0}, TimestampsUnixNano: []uint64{11, 12}},
+						},
+					}},
+				}},
+			}},
+		},
+		checkZeroValues: true,
+		wantWarning:     "sample[1]: values: all values are zero",
+	}, {
+		desc: "some zero sample values",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictionary,
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{
+						TimeUnixNano: 10,
+						DurationNano: 10,
+						Samples: []*profiles.Sample{
+							{Values: []int64{0, 2}, TimestampsUnixNano: []uint64{11, 12}},
+							{TimestampsUnixNano: []uint64{13}},
+						},
+					}},
+				}},
+			}},
+		},
+		checkZeroValues: true,
+		wantErr:         "",
+	}, {
+		desc: "location address outside of its mapping",
+		data: &profiles.ProfilesData{
+			Dictionary: &profiles.ProfilesDictionary{
+				MappingTable: []*profiles.Mapping{{}, {MemoryStart: 0x1000, MemoryLimit: 0x2000}},
+				LocationTable: []*profiles.Location{
+					{},
+					{MappingIndex: 1, Address: 0x1800},
+					{MappingIndex: 1, Address: 0x2000},
+				},
+				FunctionTable:  []*profiles.Function{{}},
+				LinkTable:      []*profiles.Link{{}},
+				StringTable:    []string{""},
+				AttributeTable: []*profiles.KeyValueAndUnit{{}},
+				StackTable:     []*profiles.Stack{{}, {LocationIndices: []int32{1, 2}}},
+			},
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{}},
+				}},
+			}},
+		},
+		checkAddressRange: true,
+		wantErr:           "dictionary: location_table: [2]: address 0000000000002000 is outside of mapping_table[1] [0000000000001000, 0000000000002000)",
+	}, {
+		desc: "stack moving between overlapping mappings",
+		data: &profiles.ProfilesData{
+			Dictionary: &profiles.ProfilesDictionary{
+				MappingTable: []*profiles.Mapping{
+					{},
+					{MemoryStart: 0x1000, MemoryLimit: 0x3000},
+					{MemoryStart: 0x2000, MemoryLimit: 0x4000},
+					{MemoryStart: 0x4000, MemoryLimit: 0x5000},
+				},
+				LocationTable: []*profiles.Location{
+					{},
+					{MappingIndex: 1, Address: 0x1800},
+					{MappingIndex: 2, Address: 0x3800},
+					{MappingIndex: 3, Address: 0x4800},
+				},
+				FunctionTable:  []*profiles.Function{{}},
+				LinkTable:      []*profiles.Link{{}},
+				StringTable:    []string{""},
+				AttributeTable: []*profiles.KeyValueAndUnit{{}},
+				StackTable:     []*profiles.Stack{{}, {LocationIndices: []int32{3, 1, 2}}},
+			},
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{}},
+				}},
+			}},
+		},
+		checkStacks: true,
+		wantWarning: "stack_table[1].location_indices[2]: mapping_table[2] overlaps mapping_table[1]",
+	}, {
+		desc: "profiles with different units in one scope",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictWithStringTable([]string{"", "cpu", "nanoseconds", "alloc_space", "bytes"}),
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{
+						{SampleType: &profiles.ValueType{TypeStrindex: 1, UnitStrindex: 2}},
+						{SampleType: &profiles.ValueType{TypeStrindex: 1, UnitStrindex: 2}},
+						{SampleType: &profiles.ValueType{TypeStrindex: 3, UnitStrindex: 4}},
+					},
+				}},
+			}},
+		},
+		checkScopeUnits: true,
+		wantWarning:     `scope_profiles[0]: profile[2]: sample_type unit "bytes" differs from unit "nanoseconds" of profile[0] in the same scope`,
+	}, {
+		desc: "mapping attribute repeating the filename",
+		data: &profiles.ProfilesData{
+			Dictionary: &profiles.ProfilesDictionary{
+				MappingTable: []*profiles.Mapping{
+					{},
+					{FilenameStrindex: 1, AttributeIndices: []int32{1, 2}},
+				},
+				LocationTable: []*profiles.Location{{}},
+				FunctionTable: []*profiles.Function{{}},
+				LinkTable:     []*profiles.Link{{}},
+				StringTable:   []string{"", "/usr/bin/app", "process.executable.path", "service.name"},
+				AttributeTable: []*profiles.KeyValueAndUnit{
+					{},
+					{KeyStrindex: 3, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "app"}}},
+					{KeyStrindex: 2, Value: &common.AnyValue{Value: &common.AnyValue_StringValueStrindex{StringValueStrindex: 1}}},
+				},
+				StackTable: []*profiles.Stack{{}},
+			},
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{}},
+				}},
+			}},
+		},
+		checkFilenames: true,
+		wantWarning:    `mapping_table: [1].attribute_indices: [1]: "process.executable.path" repeats the filename "/usr/bin/app"`,
+	}, {
+		desc: "entity refs with unknown keys",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictWithStringTable([]string{"", "host.name"}),
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				Resource: &resource.Resource{
+					Attributes: []*common.KeyValue{
+						{KeyStrindex: 1, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "node-1"}}},
+						{Key: "service.name", Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "api"}}},
+					},
+					EntityRefs: []*common.EntityRef{
+						{Type: "host", IdKeys: []string{"host.name"}},
+						{Type: "service", IdKeys: []string{"service.name"}, DescriptionKeys: []string{"service.version"}},
+						{IdKeys: []string{"host.name"}},
+					},
+				},
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{}},
+				}},
+			}},
+		},
+		wantErr: `resource_profiles[0]: resource.entity_refs: [1].description_keys[0]: "service.version" is not a key of the resource attributes`,
+	}, {
+		desc: "attribute_indices referencing the zero value attribute",
+		data: &profiles.ProfilesData{
+			Dictionary: &profiles.ProfilesDictionary{
+				MappingTable:  []*profiles.Mapping{{}},
+				LocationTable: []*profiles.Location{{}, {AttributeIndices: []int32{0}}},
+				FunctionTable: []*profiles.Function{{}},
+				LinkTable:     []*profiles.Link{{}},
+				StringTable:   []string{"", "thread.name"},
+				AttributeTable: []*profiles.KeyValueAndUnit{
+					{},
+					{KeyStrindex: 1, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "main"}}},
+				},
+				StackTable: []*profiles.Stack{{}},
+			},
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{
+						Samples: []*profiles.Sample{{Values: []int64{1}, AttributeIndices: []int32{1, 0}}},
+					}},
+				}},
+			}},
+		},
+		checkZeroAttrs: true,
+		wantErr:        "sample[0]: attribute_indices: [1]: references the zero value attribute_table[0]",
+	}, {
+		desc: "duplicate samples",
+		data: &profiles.ProfilesData{
+			Dictionary: &profiles.ProfilesDictionary{
+				MappingTable:   []*profiles.Mapping{{}},
+				LocationTable:  []*profiles.Location{{}},
+				FunctionTable:  []*profiles.Function{{}},
+				LinkTable:      []*profiles.Link{{}},
+				StringTable:    []string{"", "k", "a", "b"},
+				AttributeTable: []*profiles.KeyValueAndUnit{{}, {KeyStrindex: 1}, {KeyStrindex: 2}, {KeyStrindex: 3}},
+				StackTable:     []*profiles.Stack{{}},
+			},
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{
+						Samples: []*profiles.Sample{
+							{Values: []int64{1}, AttributeIndices: []int32{1, 2}},
+							{Values: []int64{2}, AttributeIndices: []int32{1, 3}},
+							{Values: []int64{3}, AttributeIndices: []int32{1}},
+							// Attributes are unordered.
+							{Values: []int64{4}, AttributeIndices: []int32{2, 1}},
+						},
+					}},
+				}},
+			}},
+		},
+		checkUniqueness: true,
+		wantErr:         "profile[0]: sample[3]: same stack_index, link_index and attribute_indices as sample[0]",
+	}, {
+		desc: "timestamped samples without time_unix_nano",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictionary,
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{
+						DurationNano: 10,
+						Samples: []*profiles.Sample{
+							{Values: []int64{1}, TimestampsUnixNano: []uint64{1700000000000000000}},
+						},
+					}},
+				}},
+			}},
+		},
+		wantErr: "profile[0]: profile has timestamped samples but time_unix_nano is unset",
+	}, {
+		desc: "link without span correlation attributes",
+		data: &profiles.ProfilesData{
+			Dictionary: &profiles.ProfilesDictionary{
+				MappingTable:  []*profiles.Mapping{{}},
+				LocationTable: []*profiles.Location{{}},
+				FunctionTable: []*profiles.Function{{}},
+				LinkTable:     []*profiles.Link{{}, {TraceId: []byte("0123456789abcdef"), SpanId: []byte("01234567")}},
+				StringTable:   []string{"", "span.id"},
+				AttributeTable: []*profiles.KeyValueAndUnit{
+					{},
+					{KeyStrindex: 1, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "3031323334353637"}}},
+				},
+				StackTable: []*profiles.Stack{{}},
+			},
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{
+						Samples: []*profiles.Sample{
+							{Values: []int64{1}, LinkIndex: 1, AttributeIndices: []int32{1}},
+							{Values: []int64{1}, LinkIndex: 1},
+						},
+					}},
+				}},
+			}},
+		},
+		checkLinks:  true,
+		wantWarning: "sample[1]: link_index: sample references a link but has no span correlation attributes",
+	}, {
+		desc: "span correlation attributes without link",
+		data: &profiles.ProfilesData{
+			Dictionary: &profiles.ProfilesDictionary{
+				MappingTable:  []*profiles.Mapping{{}},
+				LocationTable: []*profiles.Location{{}},
+				FunctionTable: []*profiles.Function{{}},
+				LinkTable:     []*profiles.Link{{}},
+				StringTable:   []string{"", "trace.id"},
+				AttributeTable: []*profiles.KeyValueAndUnit{
+					{},
+					{KeyStrindex: 1, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "30313233343536373839616263646566"}}},
+				},
+				StackTable: []*profiles.Stack{{}},
+			},
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{
+						Samples: []*profiles.Sample{{Values: []int64{1}, AttributeIndices: []int32{1}}},
+					}},
+				}},
+			}},
+		},
+		checkLinks:  true,
+		wantWarning: `sample[0]: attribute_indices[0]: "trace.id" correlates the sample with a span but link_index is unset`,
+	}, {
+		desc: "line numbers that are byte offsets",
+		data: &profiles.ProfilesData{
+			Dictionary: &profiles.ProfilesDictionary{
+				MappingTable: []*profiles.Mapping{{}},
+				LocationTable: []*profiles.Location{
+					{},
+					{Lines: []*profiles.Line{{FunctionIndex: 1, Line: 42}, {FunctionIndex: 1, Line: 123456789}}},
+				},
+				FunctionTable:  []*profiles.Function{{}, {StartLine: 20_000_000}},
+				LinkTable:      []*profiles.Link{{}},
+				StringTable:    []string{""},
+				AttributeTable: []*profiles.KeyValueAndUnit{{}},
+				StackTable:     []*profiles.Stack{{}},
+			},
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{}},
+				}},
+			}},
+		},
+		checkLines:  true,
+		wantWarning: "location_table: [1].line[1]: line: 123456789 exceeds the maximum of 10000000, byte offset instead of line number?",
+	}, {
+		desc: "negative cumulative values",
+		data: &profiles.ProfilesData{
+			Dictionary: &profiles.ProfilesDictionary{
+				MappingTable:   []*profiles.Mapping{{}},
+				LocationTable:  []*profiles.Location{{}},
+				FunctionTable:  []*profiles.Function{{}},
+				LinkTable:      []*profiles.Link{{}},
+				StringTable:    []string{"", "cpu", "nanoseconds", "inuse_space", "bytes"},
+				AttributeTable: []*profiles.KeyValueAndUnit{{}},
+				StackTable:     []*profiles.Stack{{}},
+			},
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{
+						SampleType: &profiles.ValueType{TypeStrindex: 1, UnitStrindex: 2},
+						Samples:    []*profiles.Sample{{Values: []int64{5}}, {Values: []int64{-3}}},
+					}, {
+						// A gauge may go down, e.g. in a delta profile.
+						SampleType: &profiles.ValueType{TypeStrindex: 3, UnitStrindex: 4},
+						Samples:    []*profiles.Sample{{Values: []int64{-7}}},
+					}},
+				}},
+			}},
+		},
+		checkNegatives: true,
+		wantErr:        `profile[0]: sample[1].values[0]: -3 < 0, "cpu" values are cumulative`,
+	}, {
+		desc: "unknown attribute unit",
+		data: &profiles.ProfilesData{
+			Dictionary: &profiles.ProfilesDictionary{
+				MappingTable:  []*profiles.Mapping{{}},
+				LocationTable: []*profiles.Location{{}},
+				FunctionTable: []*profiles.Function{{}},
+				LinkTable:     []*profiles.Link{{}},
+				StringTable:   []string{"", "gc.pause", "ns", "alloc.size", "nanosecond"},
+				AttributeTable: []*profiles.KeyValueAndUnit{
+					{},
+					{KeyStrindex: 1, UnitStrindex: 2, Value: &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: 1}}},
+					{KeyStrindex: 3, UnitStrindex: 4, Value: &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: 2}}},
+				},
+				StackTable: []*profiles.Stack{{}},
+			},
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{}},
+				}},
+			}},
+		},
+		checkUnits:  true,
+		wantWarning: `attribute_table: [2].unit_strindex: "nanosecond" is not a known unit`,
+	}, {
+		desc: "implausible dropped attributes count",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictionary,
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				Resource: &resource.Resource{DroppedAttributesCount: 3},
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{DroppedAttributesCount: 4_000_000_000}},
+				}},
+			}},
+		},
+		checkDropped: true,
+		wantWarning:  "resource_profiles[0]: scope_profiles[0]: profile[0]: dropped_attributes_count: 4000000000 exceeds the maximum of 100000, counter not reset between exports?",
+	}, {
+		desc: "duplicate timestamps",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictionary,
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{
+						TimeUnixNano: 100,
+						DurationNano: 10,
+						Samples: []*profiles.Sample{{
+							TimestampsUnixNano: []uint64{100, 101, 102},
+						}, {
+							TimestampsUnixNano: []uint64{100, 103, 103, 103, 104},
+						}},
+					}},
+				}},
+			}},
+		},
+		checkDupTimes: true,
+		wantWarning:   "sample[1]: timestamps_unix_nano[2]=103 repeats the previous timestamp 2 times",
+	}, {
+		desc: "duration in the wrong unit",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictionary,
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{
+						DurationNano: 10e9,
+					}, {
+						// A timestamp mistakenly used as the duration.
+						DurationNano: 17e17,
+					}},
+				}},
+			}},
+		},
+		checkDuration: true,
+		wantWarning:   "profile[1]: duration_nano: 472222h13m20s exceeds the maximum of 24h0m0s",
+	}, {
+		desc: "sample timestamps span more than the duration",
+		data: &profiles.ProfilesData{
+			Dictionary: zeroDictionary,
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: []*profiles.Profile{{
+						TimeUnixNano: 1e9,
+						DurationNano: 10e9,
+						Samples: []*profiles.Sample{
+							{TimestampsUnixNano: []uint64{2e9, 3e9}},
+							{TimestampsUnixNano: []uint64{1e9}},
+						},
+					}, {
+						// The duration in seconds instead of nanoseconds.
+						TimeUnixNano: 1e9,
+						DurationNano: 10,
+						Samples: []*profiles.Sample{
+							{TimestampsUnixNano: []uint64{3e9, 1e9}},
+							{TimestampsUnixNano: []uint64{2e9}},
+						},
+					}},
+				}},
+			}},
+		},
+		checkTimeSpan: true,
+		wantErr:       "timestamps_unix_nano[0]=3000000000 is outside profile time range [1000000000, 1000000010)",
+		wantWarning:   "profile[1]: profile sample timestamp span (2s) exceeds declared duration (10ns)",
+	}}
+}
+
+func TestCheckConformance(t *testing.T) {
+	for _, tc := range conformanceTestCases() {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := ConformanceChecker{CheckDictionaryDuplicates: !tc.disableDupesCheck, CheckSampleTimestampShape: tc.checkSampleShapes, CheckDictionaryOrphans: tc.checkReferences, CheckSemanticAttributes: tc.checkSemconv, CheckSampleTypeSet: tc.checkSampleType, CheckZeroValueSamples: tc.checkZeroValues, CheckStackPlausibility: tc.checkStacks, CheckAddressRange: tc.checkAddressRange, CheckProfileDuration: tc.checkDuration, CheckLinkConsistency: tc.checkLinks, CheckScopeUnitConsistency: tc.checkScopeUnits, CheckSampleUniqueness: tc.checkUniqueness, CheckMappingFilenameAttributes: tc.checkFilenames, CheckZeroAttributeReferences: tc.checkZeroAttrs, CheckLineNumbers: tc.checkLines, CheckNegativeValues: tc.checkNegatives, CheckAttributeUnits: tc.checkUnits, CheckDroppedAttributes: tc.checkDropped, CheckDuplicateTimestamps: tc.checkDupTimes, CheckTimestampSpan: tc.checkTimeSpan}
+			if tc.strict {
+				c = StrictConformanceChecker()
+				c.RequireSamples = !tc.allowEmpty
+			}
+			err := c.Check(tc.data)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("Check(): got error %q, want no error", err)
+			case tc.wantErr == "" && err == nil:
+				break
+			case err == nil:
+				t.Errorf("Check(): got no error, want error containing %q", tc.wantErr)
+			case !strings.Contains(err.Error(), tc.wantErr):
+				t.Errorf("Check(): got error %q, want error containing %q", err, tc.wantErr)
+			}
+			if tc.wantWarning != "" && !slices.ContainsFunc(c.Report(tc.data), func(f Finding) bool {
+				return f.Severity == SeverityWarning && strings.Contains(f.Message, tc.wantWarning)
+			}) {
+				t.Errorf("Report(): got %v, want warning containing %q", c.Report(tc.data), tc.wantWarning)
+			}
+			for _, f := range c.Report(tc.data) {
+				if f.Check == "" {
+					t.Errorf("Report(): finding %q has no check", f.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckDictionaryOrder(t *testing.T) {
+	// Every table lacks its zero value, so every table check fails.
+	dict := &profiles.ProfilesDictionary{
+		MappingTable:   []*profiles.Mapping{{MemoryStart: 1}},
+		LocationTable:  []*profiles.Location{{Address: 1}},
+		FunctionTable:  []*profiles.Function{{StartLine: 1}},
+		LinkTable:      []*profiles.Link{{TraceId: []byte{1}}},
+		StringTable:    []string{"a"},
+		AttributeTable: []*profiles.KeyValueAndUnit{{UnitStrindex: 1}},
+		StackTable:     []*profiles.Stack{{LocationIndices: []int32{0}}},
+	}
+	c := ConformanceChecker{}
+	want := c.checkDictionary(dict).Error()
+	var tables []string
+	for _, line := range strings.Split(want, "\n") {
+		if table, _, _ := strings.Cut(line, ":"); !slices.Contains(tables, table) {
+			tables = append(tables, table)
+		}
+	}
+	wantTables := []string{"mapping_table", "location_table", "function_table", "link_table", "string_table", "attribute_table", "stack_table"}
+	if !slices.Equal(tables, wantTables) {
+		t.Errorf("checkDictionary(): got tables %v, want %v", tables, wantTables)
+	}
+	for range 20 {
+		if got := c.checkDictionary(dict).Error(); got != want {
+			t.Fatalf("checkDictionary(): got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestCheckDictionaryZeroValues(t *testing.T) {
+	zeroDictionary := func() *profiles.ProfilesDictionary {
+		return &profiles.ProfilesDictionary{
+			MappingTable:   []*profiles.Mapping{{}},
+			LocationTable:  []*profiles.Location{{}},
+			FunctionTable:  []*profiles.Function{{}},
+			LinkTable:      []*profiles.Link{{}},
+			StringTable:    []string{""},
+			AttributeTable: []*profiles.KeyValueAndUnit{{}},
+			StackTable:     []*profiles.Stack{{}},
+		}
+	}
+	for _, tc := range []struct {
+		table  string
+		mutate func(dict *profiles.ProfilesDictionary)
+		empty  func(dict *profiles.ProfilesDictionary)
+	}{{
+		table:  "mapping_table",
+		mutate: func(dict *profiles.ProfilesDictionary) { dict.MappingTable[0].MemoryStart = 1 },
+		empty:  func(dict *profiles.ProfilesDictionary) { dict.MappingTable = nil },
+	}, {
+		table:  "location_table",
+		mutate: func(dict *profiles.ProfilesDictionary) { dict.LocationTable[0].Address = 1 },
+		empty:  func(dict *profiles.ProfilesDictionary) { dict.LocationTable = nil },
+	}, {
+		table:  "function_table",
+		mutate: func(dict *profiles.ProfilesDictionary) { dict.FunctionTable[0].StartLine = 1 },
+		empty:  func(dict *profiles.ProfilesDictionary) { dict.FunctionTable = nil },
+	}, {
+		table:  "link_table",
+		mutate: func(dict *profiles.ProfilesDictionary) { dict.LinkTable[0].SpanId = []byte{1} },
+		empty:  func(dict *profiles.ProfilesDictionary) { dict.LinkTable = nil },
+	}, {
+		table:  "string_table",
+		mutate: func(dict *profiles.ProfilesDictionary) { dict.StringTable[0] = "a" },
+		empty:  func(dict *profiles.ProfilesDictionary) { dict.StringTable = nil },
+	}, {
+		table: "attribute_table",
+		mutate: func(dict *profiles.ProfilesDictionary) {
+			dict.AttributeTable[0].Value = &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: 1}}
+		},
+		empty: func(dict *profiles.ProfilesDictionary) { dict.AttributeTable = nil },
+	}, {
+		table:  "stack_table",
+		mutate: func(dict *profiles.ProfilesDictionary) { dict.StackTable[0].LocationIndices = []int32{0} },
+		empty:  func(dict *profiles.ProfilesDictionary) { dict.StackTable = nil },
+	}} {
+		for _, sub := range []struct {
+			desc    string
+			apply   func(dict *profiles.ProfilesDictionary)
+			wantErr string
+		}{
+			{desc: "non-zero", apply: tc.mutate, wantErr: tc.table + ": must have the zero value at index 0, got "},
+			{desc: "empty", apply: tc.empty, wantErr: tc.table + ": empty table, must have the zero value at index 0"},
+		} {
+			t.Run(tc.table+"/"+sub.desc, func(t *testing.T) {
+				dict := zeroDictionary()
+				sub.apply(dict)
+				err := ConformanceChecker{}.checkDictionary(dict)
+				if err == nil || !strings.Contains(err.Error(), sub.wantErr) {
+					t.Fatalf("checkDictionary(): got error %v, want error containing %q", err, sub.wantErr)
+				}
+			})
+		}
+	}
+}
+
+func TestPrefixErrorf(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		err  error
+		want string
+	}{{
+		desc: "single error",
+		err:  errors.New("error 1"),
+		want: "prefix: error 1",
+	}, {
+		desc: "multiple errors",
+		err:  errors.Join(errors.New("error 1"), errors.New("error 2")),
+		want: "prefix: error 1\nprefix: error 2",
+	}, {
+		desc: "nested errors",
+		err:  errors.Join(errors.Join(errors.New("error 1"), errors.New("error 2")), errors.New("error 3")),
+		want: "prefix: error 1\nprefix: error 2\nprefix: error 3",
 	}} {
 		t.Run(tc.desc, func(t *testing.T) {
 			got := prefixErrorf(tc.err, "prefix").Error()
@@ -0,0 +1,158 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// profileExtensions are the extensions of the archive entries that are
+// checked. Other entries, e.g. a README, are skipped.
+var profileExtensions = []string{".pb", ".binpb", ".otlp", ".json"}
+
+// input is a profile to check, either a file or an entry of an archive.
+type input struct {
+	// name is the path of the file, or the path of the archive and the name
+	// of the entry separated by a colon.
+	name string
+	// contents are the bytes of an archive entry. Files are read when they
+	// are checked, so that a large batch isn't held in memory.
+	contents []byte
+	// err is the error reading the archive, reported as the input failing.
+	err error
+}
+
+// read returns the bytes of in.
+func (in input) read() ([]byte, error) {
+	if in.err != nil {
+		return nil, in.err
+	}
+	if in.contents != nil {
+		return in.contents, nil
+	}
+	contents, err := os.ReadFile(in.name)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+	return contents, nil
+}
+
+// expandInputs returns the inputs to check for the command line arguments,
+// replacing every .tar, .tar.gz, .tgz or .zip archive with its profile
+// entries.
+func expandInputs(args []string) []input {
+	var inputs []input
+	for _, arg := range args {
+		var entries []input
+		var err error
+		switch {
+		case strings.HasSuffix(arg, ".tar"):
+			entries, err = readTar(arg, false)
+		case strings.HasSuffix(arg, ".tar.gz"), strings.HasSuffix(arg, ".tgz"):
+			entries, err = readTar(arg, true)
+		case strings.HasSuffix(arg, ".zip"):
+			entries, err = readZip(arg)
+		default:
+			inputs = append(inputs, input{name: arg})
+			continue
+		}
+		if err != nil {
+			inputs = append(inputs, input{name: arg, err: fmt.Errorf("error reading archive: %w", err)})
+			continue
+		}
+		if len(entries) == 0 {
+			inputs = append(inputs, input{name: arg, err: fmt.Errorf("archive has no entries ending in %s", strings.Join(profileExtensions, ", "))})
+		}
+		inputs = append(inputs, entries...)
+	}
+	return inputs
+}
+
+// isProfileEntry reports whether the archive entry name looks like a profile.
+// Hidden files, such as the resource forks macOS adds to archives, are not.
+func isProfileEntry(name string) bool {
+	base := path.Base(name)
+	return !strings.HasPrefix(base, ".") && !strings.HasPrefix(name, "__MACOSX/") && slices.Contains(profileExtensions, path.Ext(base))
+}
+
+func readTar(archivePath string, gzipped bool) ([]input, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if gzipped {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	var entries []input
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg || !isProfileEntry(hdr.Name) {
+			continue
+		}
+		contents, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+		entries = append(entries, input{name: archivePath + ":" + hdr.Name, contents: contents})
+	}
+}
+
+func readZip(archivePath string) ([]input, error) {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var entries []input
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() || !isProfileEntry(f.Name) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		contents, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		entries = append(entries, input{name: archivePath + ":" + f.Name, contents: contents})
+	}
+	return entries, nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/open-telemetry/sig-profiling/profcheck"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// archiveEntry is a file of an archive written by writeArchive.
+type archiveEntry struct {
+	name     string
+	contents string
+}
+
+// writeArchive writes entries to path as a tar, gzipped tar or zip archive,
+// depending on the extension of path.
+func writeArchive(t *testing.T, path string, entries []archiveEntry) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if strings.HasSuffix(path, ".zip") {
+		zw := zip.NewWriter(f)
+		for _, e := range entries {
+			w, err := zw.Create(e.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.WriteString(w, e.contents); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	var w io.Writer = f
+	if strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".tgz") {
+		gz := gzip.NewWriter(f)
+		defer func() {
+			if err := gz.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}()
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		if err := tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.contents)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, e.contents); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// generated returns the marshaled profile profcheck.Generate returns for
+// defect.
+func generated(t *testing.T, defect string) string {
+	t.Helper()
+	data, err := profcheck.Generate(defect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, err := proto.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(contents)
+}
+
+func TestExpandInputs(t *testing.T) {
+	entries := []archiveEntry{
+		{"a.pb", "a"},
+		{"dir/b.binpb", "b"},
+		{"dir/.hidden.pb", "hidden"},
+		{"__MACOSX/._a.pb", "fork"},
+		{"README.md", "readme"},
+		{"c.json", "{}"},
+	}
+	for _, ext := range []string{".tar", ".tar.gz", ".tgz", ".zip"} {
+		for _, tc := range []struct {
+			desc    string
+			entries []archiveEntry
+			// want are the names and contents of the inputs, without
+			// the archive path.
+			want    []archiveEntry
+			wantErr string
+		}{{
+			desc:    "profile entries",
+			entries: entries,
+			want:    []archiveEntry{{"a.pb", "a"}, {"dir/b.binpb", "b"}, {"c.json", "{}"}},
+		}, {
+			desc:    "no profile entries",
+			entries: []archiveEntry{{"README.md", "readme"}, {".hidden.pb", "hidden"}},
+			wantErr: "archive has no entries ending in .pb, .binpb, .otlp, .json",
+		}, {
+			desc:    "empty archive",
+			wantErr: "archive has no entries ending in .pb, .binpb, .otlp, .json",
+		}} {
+			t.Run(ext+"/"+tc.desc, func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "profiles"+ext)
+				writeArchive(t, path, tc.entries)
+				inputs := expandInputs([]string{"plain.pb", path})
+				if !reflect.DeepEqual(inputs[0], input{name: "plain.pb"}) {
+					t.Errorf("expandInputs()[0]: got %+v, want the plain file", inputs[0])
+				}
+				inputs = inputs[1:]
+
+				if tc.wantErr != "" {
+					if len(inputs) != 1 || inputs[0].name != path || inputs[0].err == nil || inputs[0].err.Error() != tc.wantErr {
+						t.Errorf("expandInputs(): got %+v, want the archive with error %q", inputs, tc.wantErr)
+					}
+					return
+				}
+				var got []archiveEntry
+				for _, in := range inputs {
+					if in.err != nil {
+						t.Fatalf("expandInputs(): %s: %v", in.name, in.err)
+					}
+					name, ok := strings.CutPrefix(in.name, path+":")
+					if !ok {
+						t.Errorf("expandInputs(): got input %q, want it prefixed with %q", in.name, path+":")
+					}
+					got = append(got, archiveEntry{name, string(in.contents)})
+				}
+				if !reflect.DeepEqual(got, tc.want) {
+					t.Errorf("expandInputs(): got %v, want %v", got, tc.want)
+				}
+			})
+		}
+	}
+}
+
+func TestExpandInputsCorrupt(t *testing.T) {
+	for _, ext := range []string{".tar.gz", ".zip"} {
+		path := filepath.Join(t.TempDir(), "corrupt"+ext)
+		if err := os.WriteFile(path, []byte("not an archive"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		inputs := expandInputs([]string{path})
+		if len(inputs) != 1 || inputs[0].err == nil || !strings.HasPrefix(inputs[0].err.Error(), "error reading archive: ") {
+			t.Errorf("expandInputs(%s): got %+v, want a single input with an error reading the archive", ext, inputs)
+		}
+	}
+}
+
+func TestCheckArchive(t *testing.T) {
+	for _, tc := range []struct {
+		desc     string
+		entries  []archiveEntry
+		wantCode int
+		want     []string
+	}{{
+		desc:    "all entries pass",
+		entries: []archiveEntry{{"a.pb", generated(t, "")}, {"b.pb", generated(t, "")}},
+		want: []string{
+			"profiles.zip:a.pb: conformance checks passed",
+			"profiles.zip:b.pb: conformance checks passed",
+			"0 of 2 files failed",
+		},
+	}, {
+		desc:     "one entry fails",
+		entries:  []archiveEntry{{"a.pb", generated(t, "")}, {"b.pb", generated(t, "out-of-range-index")}, {"c.pb", generated(t, "")}},
+		wantCode: 1,
+		want: []string{
+			"profiles.zip:a.pb: conformance checks passed",
+			"profiles.zip:b.pb: conformance checks failed",
+			"profiles.zip:c.pb: conformance checks passed",
+			"1 of 3 files failed",
+		},
+	}, {
+		desc:     "empty archive",
+		wantCode: 1,
+		want:     []string{"profiles.zip: archive has no entries"},
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			writeArchive(t, filepath.Join(dir, "profiles.zip"), tc.entries)
+			cmd := []string{"-quiet", filepath.Join(dir, "profiles.zip")}
+			out, code := runProfcheck(t, cmd...)
+			if code != tc.wantCode {
+				t.Errorf("profcheck %v: got exit code %d, want %d\n%s", cmd, code, tc.wantCode, out)
+			}
+			out = strings.ReplaceAll(out, dir+string(filepath.Separator), "")
+			for _, want := range tc.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("profcheck %v: got output\n%s\nwant it to contain %q", cmd, out, want)
+				}
+			}
+		})
+	}
+}
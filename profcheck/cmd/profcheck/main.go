@@ -14,54 +14,484 @@
 
 // profcheck is a tool that verifies that a ProfilesData proto conforms with
 // the signal schema requirements and spec.
+//
+// Inputs ending in .tar, .tar.gz, .tgz or .zip are read as archives, and each
+// of their entries that looks like a profile is checked as a file of its own.
+// With -stream, every input is read as a stream of length-prefixed records,
+// which are checked one at a time without loading the whole input.
 package main
 
 import (
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/open-telemetry/sig-profiling/profcheck"
 
 	profiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
 )
 
+// options are the settings of a profcheck run. They are loaded from the
+// -config file, if any, with the command line flags taking precedence.
+type options struct {
+	profcheck.ConformanceChecker `yaml:",inline"`
+
+	WarningsAsErrors   bool `yaml:"warnings_as_errors"`
+	Strict             bool `yaml:"strict"`
+	AllowEmptyProfiles bool `yaml:"allow_empty_profiles"`
+	ReportGaps         bool `yaml:"report_gaps"`
+	Quiet              bool `yaml:"quiet"`
+	// CountOnly skips the checks and only prints the structure sizes.
+	CountOnly bool `yaml:"count_only"`
+	// InputFormat is one of inputFormats.
+	InputFormat string `yaml:"input_format"`
+	// Output is one of outputFormats.
+	Output string `yaml:"output"`
+}
+
+// inputFormats are the supported -input-format values. With "auto", files
+// starting with '{' are decoded as JSON and all others as protobuf.
+var inputFormats = []string{"auto", "proto", "json"}
+
+// outputFormats are the supported -output values. text prints the findings
+// of every file as they are found, sarif prints a SARIF log of all findings
+// at the end, see writeSARIF.
+var outputFormats = []string{"text", "sarif"}
+
 var (
-	checkDupes        = flag.Bool("check-dupes", false, "Enable check for duplicate entries in the dictionary")
-	checkSampleShapes = flag.Bool("check-sample-shapes", true, "Enable check for sample shapes")
-	checkOrphans      = flag.Bool("check-orphans", false, "Enable check for orphaned / unreferenced entries in the dictionary")
+	opts = options{
+		ConformanceChecker: profcheck.ConformanceChecker{
+			CheckSampleTimestampShape: true,
+			AllowedPayloadFormats:     profcheck.DefaultPayloadFormats,
+			CumulativeSampleTypes:     profcheck.DefaultCumulativeSampleTypes,
+			KnownUnits:                profcheck.DefaultKnownUnits,
+			MaxProfileDuration:        profcheck.DefaultMaxProfileDuration,
+			MaxLineNumber:             profcheck.DefaultMaxLineNumber,
+		},
+		InputFormat: "auto",
+		Output:      "text",
+	}
+	configPath = flag.String("config", "", "YAML file with check toggles and options, e.g. check_dictionary_orphans: true; flags override it")
+	fix        = flag.Bool("fix", false, "Write a canonicalized copy of the input to -fix-out, dropping unreferenced dictionary entries, merging duplicate strings and sorting attribute indices, and check it instead of the input")
+	stream     = flag.Bool("stream", false, "Read every input as a stream of ProfilesData records with a 4-byte big-endian length prefix, as written by the collector's fileexporter, and check one record at a time; inputs ending in .gz are decompressed as they are read")
+	printText  = flag.Bool("print", false, "Skip the checks and print a canonical text rendering of each input, with dictionary references resolved and attributes and samples sorted, so that profiles can be compared with diff")
+	fixOut     = flag.String("fix-out", "", "Output file for -fix, written as protojson if it ends in .json and as protobuf otherwise")
+	listChecks = flag.Bool("list-checks", false, "Print the names of the registered checks, which -checks and -disable-checks accept, and exit")
+	version    = flag.Bool("version", false, "Print the version of profcheck and of "+profcheck.ProtoModule+" the checks are compiled against and exit; same as the version subcommand")
+	// protoVersion makes profcheck refuse to check captures against a
+	// different schema than the one they target. Selecting the schema
+	// requires rebuilding, see profcheck.ProtoModule.
+	protoVersion = flag.String("proto-version", "", "Fail unless the checks are compiled against this version of "+profcheck.ProtoModule+", e.g. v0.4.0")
 )
 
+func init() {
+	flag.BoolVar(&opts.CheckDictionaryDuplicates, "check-dupes", opts.CheckDictionaryDuplicates, "Enable check for duplicate entries in the dictionary")
+	flag.BoolVar(&opts.CheckSampleTimestampShape, "check-sample-shapes", opts.CheckSampleTimestampShape, "Enable check for sample shapes")
+	flag.BoolVar(&opts.CheckDictionaryOrphans, "check-orphans", opts.CheckDictionaryOrphans, "Enable check for orphaned / unreferenced entries in the dictionary")
+	flag.BoolVar(&opts.CheckSemanticAttributes, "check-semconv", opts.CheckSemanticAttributes, "Enable check that well-known semantic convention attributes have values of the expected type")
+	flag.BoolVar(&opts.CheckSampleTypeSet, "check-sample-type", opts.CheckSampleTypeSet, "Enable check that every profile declares a non-empty sample type and unit")
+	flag.BoolVar(&opts.CheckPayloadFormat, "check-payload-format", opts.CheckPayloadFormat, "Enable check that original_payload_format is set for original payloads, warning about formats not in -allowed-payload-formats")
+	flag.BoolVar(&opts.CheckZeroValueSamples, "check-zero-values", opts.CheckZeroValueSamples, "Warn about samples whose values are all zero")
+	flag.BoolVar(&opts.CheckRedundantProfileAttributes, "check-redundant-attrs", opts.CheckRedundantProfileAttributes, "Warn about profile attributes that repeat a resource attribute with the same value")
+	flag.BoolVar(&opts.CheckStackPlausibility, "check-stack-plausibility", opts.CheckStackPlausibility, "Warn about stacks moving between overlapping mappings")
+	flag.BoolVar(&opts.CheckAddressRange, "check-address-range", opts.CheckAddressRange, "Require location addresses to lie within the memory range of their mapping")
+	flag.BoolVar(&opts.CheckProfileDuration, "check-duration", opts.CheckProfileDuration, "Warn about profiles whose duration exceeds -max-duration, which usually means a unit bug")
+	flag.DurationVar(&opts.MaxProfileDuration, "max-duration", opts.MaxProfileDuration, "Longest plausible profile duration for -check-duration")
+	flag.BoolVar(&opts.CheckLineNumbers, "check-line-numbers", opts.CheckLineNumbers, "Warn about function start lines and line numbers above -max-line, which usually are byte offsets")
+	flag.Int64Var(&opts.MaxLineNumber, "max-line", opts.MaxLineNumber, "Largest plausible line number for -check-line-numbers")
+	flag.BoolVar(&opts.CheckNegativeValues, "check-negative-values", opts.CheckNegativeValues, "Enable check that samples of the -cumulative-sample-types have no negative values")
+	flag.Var((*commaList)(&opts.CumulativeSampleTypes), "cumulative-sample-types", "Comma separated list of sample type names whose values must not be negative")
+	flag.BoolVar(&opts.CheckAttributeUnits, "check-attribute-units", opts.CheckAttributeUnits, "Warn about attribute units that are not in -known-units")
+	flag.Var((*commaList)(&opts.KnownUnits), "known-units", "Comma separated list of units accepted by -check-attribute-units")
+	flag.Func("max-dropped", "Warn about dropped_attributes_count values above this threshold, e.g. 100000", func(s string) error {
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return err
+		}
+		opts.CheckDroppedAttributes = true
+		opts.MaxDroppedAttributes = uint32(n)
+		return nil
+	})
+	flag.BoolVar(&opts.CheckDuplicateTimestamps, "check-duplicate-timestamps", opts.CheckDuplicateTimestamps, "Warn about samples that repeat a timestamp, which may be several hits in one nanosecond or a stale clock")
+	flag.BoolVar(&opts.CheckTimestampSpan, "check-timestamp-span", opts.CheckTimestampSpan, "Warn about profiles whose sample timestamps span more than duration_nano, which usually is a duration in the wrong unit")
+	flag.BoolVar(&opts.CheckLinkConsistency, "check-link-consistency", opts.CheckLinkConsistency, "Warn about samples with a link but no span correlation attributes, or the other way around")
+	flag.BoolVar(&opts.CheckScopeUnitConsistency, "check-scope-units", opts.CheckScopeUnitConsistency, "Warn about scopes whose profiles declare sample types with different units")
+	flag.BoolVar(&opts.CheckSampleUniqueness, "check-sample-uniqueness", opts.CheckSampleUniqueness, "Report samples with the same stack, link and attributes as another sample of the profile")
+	flag.BoolVar(&opts.CheckMappingFilenameAttributes, "check-mapping-filenames", opts.CheckMappingFilenameAttributes, "Warn about mapping attributes whose value repeats the mapping filename")
+	flag.BoolVar(&opts.CheckZeroAttributeReferences, "check-zero-attrs", opts.CheckZeroAttributeReferences, "Reject attribute_indices entries referencing the zero value attribute at index 0")
+	flag.Var((*commaList)(&opts.AllowedPayloadFormats), "allowed-payload-formats", "Comma separated list of known original_payload_format values")
+	flag.Var((*commaList)(&opts.Checks), "checks", "Comma separated list of registered checks to run in addition to those enabled by the -check-* flags, see -list-checks")
+	flag.Var((*commaList)(&opts.DisabledChecks), "disable-checks", "Comma separated list of registered checks not to run, even with -strict")
+	flag.BoolVar(&opts.WarningsAsErrors, "warnings-as-errors", opts.WarningsAsErrors, "Fail the checks on warnings too")
+	flag.BoolVar(&opts.Strict, "strict", opts.Strict, "Enable all optional checks, overriding the individual -check-* flags")
+	flag.BoolVar(&opts.AllowEmptyProfiles, "allow-empty-profiles", opts.AllowEmptyProfiles, "With -strict, accept profiles without samples and exempt them from checks on sample values")
+	flag.BoolVar(&opts.ReportGaps, "report-gaps", opts.ReportGaps, "Report the highest referenced index of each dictionary table and flag tables with many trailing unreferenced entries")
+	flag.StringVar(&opts.InputFormat, "input-format", opts.InputFormat, "Format of the input files, one of "+strings.Join(inputFormats, ", ")+"; auto treats files starting with '{' as protojson")
+	flag.StringVar(&opts.Output, "output", opts.Output, "Output format, one of "+strings.Join(outputFormats, ", ")+"; sarif prints a SARIF log of all findings for code scanning")
+	flag.BoolVar(&opts.Quiet, "quiet", opts.Quiet, "Do not print a summary of the structure sizes for files that pass")
+	flag.BoolVar(&opts.CountOnly, "count-only", opts.CountOnly, "Skip the checks and only print the message counts and dictionary table sizes of each file")
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "generate" {
+		if err := generate(os.Args[2:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "version" {
+		printVersion()
+		return
+	}
+
 	flag.Parse()
+	if *configPath != "" {
+		if err := loadConfig(*configPath, &opts); err != nil {
+			fmt.Printf("%s: %s\n", *configPath, err)
+			os.Exit(1)
+		}
+		// Parse the flags again so that they override the config file.
+		flag.Parse()
+	}
 
-	args := flag.Args()
-	if len(args) != 1 {
-		fmt.Println("Usage: profcheck [-check-dupes] <file>")
+	if *version {
+		printVersion()
+		return
+	}
+	if *listChecks {
+		for _, name := range profcheck.RegisteredChecks() {
+			fmt.Println(name)
+		}
+		return
+	}
+	if *protoVersion != "" && *protoVersion != profcheck.ProtoVersion() {
+		fmt.Printf("profcheck is compiled against %s %s, not %s; rebuild it with that version to check such captures\n", profcheck.ProtoModule, profcheck.ProtoVersion(), *protoVersion)
 		os.Exit(1)
 	}
 
-	inputPath := args[0]
-	contents, err := os.ReadFile(inputPath)
-	if err != nil {
-		fmt.Printf("Error reading file: %s\n", err)
+	if !slices.Contains(inputFormats, opts.InputFormat) {
+		fmt.Printf("unknown input format %q, must be one of %s\n", opts.InputFormat, strings.Join(inputFormats, ", "))
 		os.Exit(1)
 	}
 
-	var data profiles.ProfilesData
-	if err := proto.Unmarshal(contents, &data); err != nil {
-		fmt.Printf("Failed to read file %s as ProfilesData: %s\n", inputPath, err)
+	if !slices.Contains(outputFormats, opts.Output) {
+		fmt.Printf("unknown output format %q, must be one of %s\n", opts.Output, strings.Join(outputFormats, ", "))
+		os.Exit(1)
+	}
+
+	args := flag.Args()
+	if len(args) == 0 {
+		fmt.Println("Usage: profcheck [-check-dupes] <file> [<file> ...]")
+		fmt.Println("       profcheck generate [-defect <name>] -out <file>")
+		fmt.Println("       profcheck version")
+		os.Exit(1)
+	}
+	inputs := expandInputs(args)
+	if *fix && (*fixOut == "" || len(inputs) != 1) {
+		fmt.Println("-fix requires -fix-out and exactly one input file")
+		os.Exit(1)
+	}
+	if *fix && opts.CountOnly {
+		fmt.Println("-fix and -count-only are mutually exclusive")
+		os.Exit(1)
+	}
+	if opts.Output == "sarif" && (*fix || opts.CountOnly) {
+		fmt.Println("-output=sarif can't be combined with -fix or -count-only")
+		os.Exit(1)
+	}
+	if *printText && (*fix || opts.CountOnly || opts.Output != "text" || *stream) {
+		fmt.Println("-print can't be combined with -fix, -count-only, -output=sarif or -stream")
+		os.Exit(1)
+	}
+	if *stream && (*fix || opts.CountOnly || opts.Output != "text" || opts.InputFormat == "json") {
+		fmt.Println("-stream can't be combined with -fix, -count-only, -output=sarif or -input-format=json")
 		os.Exit(1)
 	}
 
-	if err := (profcheck.ConformanceChecker{
-		CheckDictionaryDuplicates: *checkDupes,
-		CheckSampleTimestampShape: *checkSampleShapes,
-		CheckDictionaryOrphans:    *checkOrphans,
-	}).Check(&data); err != nil {
-		fmt.Printf("%s: conformance checks failed: %v\n", inputPath, err)
+	checker := opts.ConformanceChecker
+	if opts.Strict {
+		checker = profcheck.StrictConformanceChecker()
+		checker.RequireSamples = !opts.AllowEmptyProfiles
+		checker.AllowedPayloadFormats = opts.AllowedPayloadFormats
+		checker.CumulativeSampleTypes = opts.CumulativeSampleTypes
+		checker.KnownUnits = opts.KnownUnits
+		checker.MaxProfileDuration = opts.MaxProfileDuration
+		checker.MaxLineNumber = opts.MaxLineNumber
+		checker.MaxDroppedAttributes = opts.MaxDroppedAttributes
+		checker.Checks = opts.Checks
+		checker.DisabledChecks = opts.DisabledChecks
+	}
+
+	if opts.CountOnly {
+		failed := 0
+		for _, in := range inputs {
+			inputPath := in.name
+			data, err := readInput(in)
+			if err != nil {
+				fmt.Printf("%s: %s\n", inputPath, err)
+				failed++
+				continue
+			}
+			fmt.Printf("%s: %s\n", inputPath, profcheck.ComputeStats(data))
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *printText {
+		failed := 0
+		for _, in := range inputs {
+			data, err := readInput(in)
+			if err != nil {
+				fmt.Printf("%s: %s\n", in.name, err)
+				failed++
+				continue
+			}
+			// The text of a single input is printed as is, so that the
+			// output of two runs can be diffed.
+			if len(inputs) > 1 {
+				fmt.Printf("== %s ==\n", in.name)
+			}
+			if err := profcheck.WriteText(os.Stdout, data); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if opts.Output == "sarif" {
+		failed, err := writeSARIF(os.Stdout, checker, inputs)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if !opts.Quiet {
+		fmt.Printf("checking against %s %s\n", profcheck.ProtoModule, profcheck.ProtoVersion())
+	}
+	// Every file is checked, even if an earlier one could not be read or
+	// decoded, and the exit code reflects all of them.
+	failed := 0
+	for _, in := range inputs {
+		if *stream {
+			if checkStream(checker, in) {
+				failed++
+			}
+			continue
+		}
+		inputPath := in.name
+		data, warnings, err := checkFile(checker, in)
+		if *fix && data != nil {
+			// The fixed profile is reported in place of the input, the
+			// findings on the input are only informational.
+			for _, w := range warnings {
+				fmt.Printf("%s: %s\n", inputPath, w)
+			}
+			if err != nil {
+				fmt.Printf("%s: %s\n", inputPath, err)
+			}
+			data, warnings, err = fixFile(checker, data, *fixOut)
+			if data != nil {
+				fmt.Printf("%s: wrote fixed profile to %s\n", inputPath, *fixOut)
+				inputPath = *fixOut
+			}
+		}
+		for _, w := range warnings {
+			fmt.Printf("%s: %s\n", inputPath, w)
+		}
+		if opts.ReportGaps && data != nil {
+			for _, gap := range profcheck.ComputeGaps(data) {
+				fmt.Printf("%s: gaps: %s\n", inputPath, gap)
+			}
+		}
+		if err != nil {
+			fmt.Printf("%s: %s\n", inputPath, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s: conformance checks passed\n", inputPath)
+		if !opts.Quiet {
+			fmt.Printf("%s: %s\n", inputPath, profcheck.ComputeStats(data))
+		}
+	}
+	if len(inputs) > 1 {
+		fmt.Printf("%d of %d files failed\n", failed, len(inputs))
+	}
+	if failed > 0 {
 		os.Exit(1)
 	}
-	fmt.Printf("%s: conformance checks passed\n", inputPath)
+}
+
+// checkFile reads and checks in. It returns the warning findings, which do
+// not fail the checks unless -warnings-as-errors is set. The decoded data is
+// returned even if the conformance checks fail.
+func checkFile(checker profcheck.ConformanceChecker, in input) (*profiles.ProfilesData, []profcheck.Finding, error) {
+	data, err := readInput(in)
+	if err != nil {
+		return nil, nil, err
+	}
+	warnings, err := checkData(checker, data)
+	return data, warnings, err
+}
+
+// readInput reads and decodes in without checking it.
+func readInput(in input) (*profiles.ProfilesData, error) {
+	contents, err := in.read()
+	if err != nil {
+		return nil, err
+	}
+
+	var data profiles.ProfilesData
+	if err := unmarshalProfilesData(contents, opts.InputFormat, &data); err != nil {
+		return nil, fmt.Errorf("failed to read file as ProfilesData: %w", err)
+	}
+	return &data, nil
+}
+
+// fixFile canonicalizes data, writes the result to outputPath and checks it
+// like checkFile.
+func fixFile(checker profcheck.ConformanceChecker, data *profiles.ProfilesData, outputPath string) (*profiles.ProfilesData, []profcheck.Finding, error) {
+	fixed, err := profcheck.Canonicalize(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := writeProfilesData(outputPath, fixed); err != nil {
+		return nil, nil, fmt.Errorf("error writing fixed profile: %w", err)
+	}
+	warnings, err := checkData(checker, fixed)
+	return fixed, warnings, err
+}
+
+// writeProfilesData writes data to outputPath as protojson if it ends in
+// .json and as protobuf otherwise.
+func writeProfilesData(outputPath string, data *profiles.ProfilesData) error {
+	var contents []byte
+	var err error
+	if strings.HasSuffix(outputPath, ".json") {
+		contents, err = protojson.Marshal(data)
+	} else {
+		contents, err = proto.Marshal(data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode profile: %w", err)
+	}
+	return os.WriteFile(outputPath, contents, 0o644)
+}
+
+// printVersion prints the version of profcheck and of the proto module it is
+// compiled against, so that users can tell whether a capture targets a newer
+// revision of the unstable schema than the checks.
+func printVersion() {
+	fmt.Printf("profcheck %s\n", profcheck.Version())
+	fmt.Printf("%s %s\n", profcheck.ProtoModule, profcheck.ProtoVersion())
+}
+
+// generate implements the generate subcommand, which writes a synthetic
+// profile with a defect from profcheck.Defects, to build test corpora and
+// reproduce issues.
+func generate(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	defect := fs.String("defect", "", "Defect to introduce, see -list; without one the profile passes the -strict checks")
+	out := fs.String("out", "", "Output file, written as protojson if it ends in .json and as protobuf otherwise")
+	list := fs.Bool("list", false, "List the known defects and exit")
+	fs.Parse(args)
+
+	if *list {
+		for _, d := range profcheck.Defects {
+			fmt.Printf("%s: %s\n", d.Name, d.Description)
+		}
+		return nil
+	}
+	if *out == "" || fs.NArg() > 0 {
+		return errors.New("Usage: profcheck generate [-defect <name>] -out <file>")
+	}
+	data, err := profcheck.Generate(*defect)
+	if err != nil {
+		return err
+	}
+	if err := writeProfilesData(*out, data); err != nil {
+		return fmt.Errorf("error writing generated profile: %w", err)
+	}
+	return nil
+}
+
+// checkData returns the warning findings of checker on data, and the error
+// findings joined as an error.
+func checkData(checker profcheck.ConformanceChecker, data *profiles.ProfilesData) ([]profcheck.Finding, error) {
+	var warnings []profcheck.Finding
+	var errs []error
+	for _, f := range checker.Report(data) {
+		switch {
+		case f.Severity == profcheck.SeverityError:
+			errs = append(errs, errors.New(f.Message))
+		case opts.WarningsAsErrors:
+			errs = append(errs, errors.New(f.String()))
+		default:
+			warnings = append(warnings, f)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return warnings, fmt.Errorf("conformance checks failed: %w", err)
+	}
+	return warnings, nil
+}
+
+// unmarshalProfilesData decodes contents in the given input format into data.
+func unmarshalProfilesData(contents []byte, format string, data *profiles.ProfilesData) error {
+	if format == "auto" {
+		format = "proto"
+		if trimmed := bytes.TrimLeft(contents, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '{' {
+			format = "json"
+		}
+	}
+	if format == "json" {
+		return protojson.Unmarshal(contents, data)
+	}
+	return proto.Unmarshal(contents, data)
+}
+
+// loadConfig sets the fields of opts that are present in the YAML file at
+// path. Unknown keys are rejected to catch typos.
+func loadConfig(path string, opts *options) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(contents))
+	dec.KnownFields(true)
+	if err := dec.Decode(opts); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+	return nil
+}
+
+// commaList is a flag.Value for a comma separated list of strings.
+type commaList []string
+
+func (l *commaList) String() string { return strings.Join(*l, ",") }
+
+func (l *commaList) Set(s string) error {
+	*l = strings.Split(s, ",")
+	return nil
 }
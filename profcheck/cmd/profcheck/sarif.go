@@ -0,0 +1,161 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/open-telemetry/sig-profiling/profcheck"
+)
+
+// The subset of the SARIF 2.1.0 log format that writeSARIF produces.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool    sarifTool     `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name           string      `json:"name"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID string `json:"id"`
+	}
+	sarifResult struct {
+		RuleID    string          `json:"ruleId"`
+		Level     string          `json:"level"`
+		Message   sarifMessage    `json:"message"`
+		Locations []sarifLocation `json:"locations"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifLocation struct {
+		PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+		LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+	}
+	sarifPhysicalLocation struct {
+		ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	}
+	sarifArtifactLocation struct {
+		URI string `json:"uri"`
+	}
+	sarifLogicalLocation struct {
+		FullyQualifiedName string `json:"fullyQualifiedName"`
+		Kind               string `json:"kind"`
+	}
+)
+
+// readRuleID is the rule of the results for inputs that can't be read or
+// decoded.
+const readRuleID = "read"
+
+// writeSARIF checks every input and writes the findings as a SARIF log to
+// out. It returns the number of inputs with error findings, which includes
+// warnings with -warnings-as-errors.
+func writeSARIF(out io.Writer, checker profcheck.ConformanceChecker, inputs []input) (int, error) {
+	results := []sarifResult{}
+	rules := map[string]bool{}
+	failed := 0
+	for _, in := range inputs {
+		data, err := readInput(in)
+		if err != nil {
+			results = append(results, sarifResult{
+				RuleID:    readRuleID,
+				Level:     "error",
+				Message:   sarifMessage{Text: err.Error()},
+				Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: in.name}}}},
+			})
+			rules[readRuleID] = true
+			failed++
+			continue
+		}
+		inputFailed := false
+		for _, f := range checker.Report(data) {
+			level := f.Severity.String()
+			if opts.WarningsAsErrors {
+				level = "error"
+			}
+			inputFailed = inputFailed || level == "error"
+			loc := sarifLocation{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: in.name}}}
+			if len(f.Path) > 0 {
+				loc.LogicalLocations = []sarifLogicalLocation{{FullyQualifiedName: logicalPath(f.Path), Kind: "member"}}
+			}
+			rules[f.Check] = true
+			results = append(results, sarifResult{
+				RuleID:    f.Check,
+				Level:     level,
+				Message:   sarifMessage{Text: f.Message},
+				Locations: []sarifLocation{loc},
+			})
+		}
+		if inputFailed {
+			failed++
+		}
+	}
+
+	driver := sarifDriver{
+		Name:           "profcheck",
+		InformationURI: "https://github.com/open-telemetry/sig-profiling/tree/main/profcheck",
+		Rules:          []sarifRule{},
+	}
+	for _, id := range slices.Sorted(maps.Keys(rules)) {
+		driver.Rules = append(driver.Rules, sarifRule{ID: id})
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to write SARIF log: %w", err)
+	}
+	return failed, nil
+}
+
+// logicalPath formats path like the prefixes of the finding messages, e.g.
+// "resource_profiles[0].scope_profiles[1].profile[0]".
+func logicalPath(path []any) string {
+	var b strings.Builder
+	for _, segment := range path {
+		switch s := segment.(type) {
+		case int:
+			fmt.Fprintf(&b, "[%d]", s)
+		default:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			fmt.Fprint(&b, s)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/open-telemetry/sig-profiling/profcheck"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// writeProfile writes the profile profcheck.Generate returns for defect to
+// dir and returns its path.
+func writeProfile(t *testing.T, dir, name, defect string) string {
+	t.Helper()
+	data, err := profcheck.Generate(defect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, err := proto.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, contents, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestWriteSARIF(t *testing.T) {
+	dir := t.TempDir()
+	inputs := []input{
+		{name: writeProfile(t, dir, "ok.pb", "")},
+		{name: writeProfile(t, dir, "long.pb", "long-duration")},
+		{name: writeProfile(t, dir, "range.pb", "out-of-range-index")},
+		{name: filepath.Join(dir, "missing.pb")},
+	}
+	type result struct {
+		uri, ruleID, level, logical string
+	}
+
+	for _, tc := range []struct {
+		desc             string
+		warningsAsErrors bool
+		wantFailed       int
+		want             []result
+	}{{
+		desc:       "warnings",
+		wantFailed: 2,
+		want: []result{
+			{inputs[1].name, "profile-duration", "warning", "resource_profiles[0].scope_profiles[0].profile[0].duration_nano"},
+			{inputs[2].name, "index-range", "error", "resource_profiles[0].scope_profiles[0].profile[0].sample[0].stack_index"},
+			{inputs[3].name, readRuleID, "error", ""},
+		},
+	}, {
+		desc:             "warnings as errors",
+		warningsAsErrors: true,
+		wantFailed:       3,
+		want: []result{
+			{inputs[1].name, "profile-duration", "error", "resource_profiles[0].scope_profiles[0].profile[0].duration_nano"},
+			{inputs[2].name, "index-range", "error", "resource_profiles[0].scope_profiles[0].profile[0].sample[0].stack_index"},
+			{inputs[3].name, readRuleID, "error", ""},
+		},
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			defer func(orig bool) { opts.WarningsAsErrors = orig }(opts.WarningsAsErrors)
+			opts.WarningsAsErrors = tc.warningsAsErrors
+
+			var out bytes.Buffer
+			failed, err := writeSARIF(&out, profcheck.StrictConformanceChecker(), inputs)
+			if err != nil {
+				t.Fatalf("writeSARIF(): %v", err)
+			}
+			if failed != tc.wantFailed {
+				t.Errorf("writeSARIF(): got %d failed inputs, want %d", failed, tc.wantFailed)
+			}
+
+			var log sarifLog
+			if err := json.Unmarshal(out.Bytes(), &log); err != nil {
+				t.Fatalf("decoding SARIF log: %v\n%s", err, out.String())
+			}
+			if log.Version != "2.1.0" || len(log.Runs) != 1 {
+				t.Fatalf("got version %q with %d runs, want 2.1.0 with 1 run", log.Version, len(log.Runs))
+			}
+			run := log.Runs[0]
+			var got []result
+			for _, r := range run.Results {
+				if len(r.Locations) != 1 {
+					t.Fatalf("result %q: got %d locations, want 1", r.Message.Text, len(r.Locations))
+				}
+				loc := r.Locations[0]
+				res := result{uri: loc.PhysicalLocation.ArtifactLocation.URI, ruleID: r.RuleID, level: r.Level}
+				if len(loc.LogicalLocations) > 0 {
+					res.logical = loc.LogicalLocations[0].FullyQualifiedName
+				}
+				got = append(got, res)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("results: got %v, want %v", got, tc.want)
+			}
+
+			var rules []string
+			for _, rule := range run.Tool.Driver.Rules {
+				rules = append(rules, rule.ID)
+			}
+			if want := []string{"index-range", "profile-duration", readRuleID}; !reflect.DeepEqual(rules, want) {
+				t.Errorf("rules: got %v, want %v", rules, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/open-telemetry/sig-profiling/profcheck"
+
+	profiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxRecordSize is the largest record -stream accepts, so that a corrupt
+// length prefix fails the stream instead of allocating gigabytes.
+const maxRecordSize = 1 << 30
+
+// open returns a reader of the bytes of in. Files ending in .gz are
+// decompressed as they are read.
+func (in input) open() (io.ReadCloser, error) {
+	if in.err != nil {
+		return nil, in.err
+	}
+	if in.contents != nil {
+		return io.NopCloser(bytes.NewReader(in.contents)), nil
+	}
+	f, err := os.Open(in.name)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+	if !strings.HasSuffix(in.name, ".gz") {
+		return f, nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{gz, f}, nil
+}
+
+// checkStream checks in as a stream of ProfilesData records, each preceded by
+// its length as a 4-byte big-endian integer, like the proto output of the
+// collector's fileexporter. The records are read and checked one at a time,
+// so the memory used is bounded by the largest record rather than the size of
+// the stream. The findings of every record are printed under the name of the
+// input and the record's index, followed by a summary of the stream. It
+// returns whether any record failed or the stream could not be read to its
+// end.
+func checkStream(checker profcheck.ConformanceChecker, in input) bool {
+	records, failed, err := readStream(in, func(name string, data *profiles.ProfilesData, err error) bool {
+		var warnings []profcheck.Finding
+		if err == nil {
+			warnings, err = checkData(checker, data)
+		}
+		for _, w := range warnings {
+			fmt.Printf("%s: %s\n", name, w)
+		}
+		if err != nil {
+			fmt.Printf("%s: %s\n", name, err)
+			return false
+		}
+		fmt.Printf("%s: conformance checks passed\n", name)
+		if !opts.Quiet {
+			fmt.Printf("%s: %s\n", name, profcheck.ComputeStats(data))
+		}
+		return true
+	})
+	if err != nil {
+		fmt.Printf("%s: %s\n", in.name, err)
+	}
+	fmt.Printf("%s: %d of %d records failed\n", in.name, failed, records)
+	return failed > 0 || err != nil
+}
+
+// readStream decodes the length-prefixed records of in and calls check with
+// the name and the decoded data of each, or the error decoding it. It returns
+// the number of records and of those for which check returned false, and the
+// error that ended the stream early, if any.
+func readStream(in input, check func(name string, data *profiles.ProfilesData, err error) bool) (records, failed int, err error) {
+	r, err := in.open()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer r.Close()
+
+	var prefix [4]byte
+	var buf []byte
+	for ; ; records++ {
+		if _, err := io.ReadFull(r, prefix[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return records, failed, nil
+			}
+			return records, failed, fmt.Errorf("record %d: error reading length prefix: %w", records, err)
+		}
+		size := binary.BigEndian.Uint32(prefix[:])
+		if size > maxRecordSize {
+			return records, failed, fmt.Errorf("record %d: length %d exceeds the maximum of %d, the stream is probably not length-prefixed", records, size, maxRecordSize)
+		}
+		buf = slices.Grow(buf[:0], int(size))[:size]
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return records, failed, fmt.Errorf("record %d: error reading %d bytes: %w", records, size, err)
+		}
+
+		data := &profiles.ProfilesData{}
+		var decodeErr error
+		if err := proto.Unmarshal(buf, data); err != nil {
+			data, decodeErr = nil, fmt.Errorf("failed to read record as ProfilesData: %w", err)
+		}
+		if !check(fmt.Sprintf("%s[%d]", in.name, records), data, decodeErr) {
+			failed++
+		}
+	}
+}
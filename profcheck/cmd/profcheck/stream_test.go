@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/open-telemetry/sig-profiling/profcheck"
+
+	profiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
+)
+
+// lengthPrefixed returns records with a 4-byte big-endian length prefix
+// each, as readStream expects them.
+func lengthPrefixed(records ...string) []byte {
+	var b []byte
+	for _, r := range records {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(r)))
+		b = append(b, r...)
+	}
+	return b
+}
+
+// writeStream writes contents to name in dir, gzipped if name ends in .gz,
+// and returns its path.
+func writeStream(t *testing.T, dir, name string, contents []byte) string {
+	t.Helper()
+	if strings.HasSuffix(name, ".gz") {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(contents); err != nil {
+			t.Fatal(err)
+		}
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+		contents = buf.Bytes()
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, contents, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadStream(t *testing.T) {
+	ok, bad := generated(t, ""), generated(t, "out-of-range-index")
+	for _, tc := range []struct {
+		desc     string
+		name     string
+		contents []byte
+		// want are the results of the records: "pass", "fail" or
+		// "decode error".
+		want        []string
+		wantRecords int
+		wantFailed  int
+		wantErr     string
+	}{{
+		desc:        "records",
+		name:        "stream.pb",
+		contents:    lengthPrefixed(ok, bad, "\xff", ok),
+		want:        []string{"pass", "fail", "decode error", "pass"},
+		wantRecords: 4,
+		wantFailed:  2,
+	}, {
+		desc:        "gzipped records",
+		name:        "stream.pb.gz",
+		contents:    lengthPrefixed(ok, bad),
+		want:        []string{"pass", "fail"},
+		wantRecords: 2,
+		wantFailed:  1,
+	}, {
+		desc:     "empty stream",
+		name:     "stream.pb",
+		contents: nil,
+	}, {
+		desc:        "truncated record",
+		name:        "stream.pb",
+		contents:    lengthPrefixed(ok, ok)[:len(ok)+4+10],
+		want:        []string{"pass"},
+		wantRecords: 1,
+		wantErr:     fmt.Sprintf("record 1: error reading %d bytes: unexpected EOF", len(ok)),
+	}, {
+		desc:        "truncated length prefix",
+		name:        "stream.pb",
+		contents:    append(lengthPrefixed(ok), 0, 0),
+		want:        []string{"pass"},
+		wantRecords: 1,
+		wantErr:     "record 1: error reading length prefix: unexpected EOF",
+	}, {
+		desc:        "length above the maximum",
+		name:        "stream.pb",
+		contents:    append(lengthPrefixed(ok), binary.BigEndian.AppendUint32(nil, maxRecordSize+1)...),
+		want:        []string{"pass"},
+		wantRecords: 1,
+		wantErr:     fmt.Sprintf("record 1: length %d exceeds the maximum of %d, the stream is probably not length-prefixed", maxRecordSize+1, maxRecordSize),
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			path := writeStream(t, t.TempDir(), tc.name, tc.contents)
+			var got, names []string
+			records, failed, err := readStream(input{name: path}, func(name string, data *profiles.ProfilesData, err error) bool {
+				names = append(names, name)
+				switch {
+				case err != nil:
+					got = append(got, "decode error")
+					return false
+				case (profcheck.ConformanceChecker{}).Check(data) != nil:
+					got = append(got, "fail")
+					return false
+				}
+				got = append(got, "pass")
+				return true
+			})
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("readStream(): got records %v, want %v", got, tc.want)
+			}
+			for i, name := range names {
+				if want := fmt.Sprintf("%s[%d]", path, i); name != want {
+					t.Errorf("readStream(): got record name %q, want %q", name, want)
+				}
+			}
+			if records != tc.wantRecords || failed != tc.wantFailed {
+				t.Errorf("readStream(): got %d records, %d failed, want %d records, %d failed", records, failed, tc.wantRecords, tc.wantFailed)
+			}
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("readStream(): got error %q, want no error", err)
+			case tc.wantErr != "" && (err == nil || err.Error() != tc.wantErr):
+				t.Errorf("readStream(): got error %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckStream(t *testing.T) {
+	ok, bad := generated(t, ""), generated(t, "out-of-range-index")
+	for _, tc := range []struct {
+		desc     string
+		streams  map[string][]byte
+		wantCode int
+		want     []string
+	}{{
+		desc:    "all records pass",
+		streams: map[string][]byte{"a.pb": lengthPrefixed(ok, ok), "b.pb.gz": lengthPrefixed(ok)},
+		want: []string{
+			"a.pb[0]: conformance checks passed",
+			"a.pb[1]: conformance checks passed",
+			"a.pb: 0 of 2 records failed",
+			"b.pb.gz[0]: conformance checks passed",
+			"b.pb.gz: 0 of 1 records failed",
+			"0 of 2 files failed",
+		},
+	}, {
+		desc:     "one record fails",
+		streams:  map[string][]byte{"a.pb": lengthPrefixed(ok, bad, ok), "b.pb.gz": lengthPrefixed(ok)},
+		wantCode: 1,
+		want: []string{
+			"a.pb[0]: conformance checks passed",
+			"a.pb[1]: conformance checks failed",
+			"a.pb[2]: conformance checks passed",
+			"a.pb: 1 of 3 records failed",
+			"b.pb.gz: 0 of 1 records failed",
+			"1 of 2 files failed",
+		},
+	}, {
+		desc:     "truncated stream",
+		streams:  map[string][]byte{"a.pb": lengthPrefixed(ok, ok)[:len(ok)+6], "b.pb.gz": lengthPrefixed(ok)},
+		wantCode: 1,
+		want: []string{
+			"a.pb[0]: conformance checks passed",
+			"a.pb: record 1: error reading length prefix: unexpected EOF",
+			"a.pb: 0 of 1 records failed",
+			"1 of 2 files failed",
+		},
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			args := []string{"-stream", "-quiet"}
+			for _, name := range []string{"a.pb", "b.pb.gz"} {
+				args = append(args, writeStream(t, dir, name, tc.streams[name]))
+			}
+			out, code := runProfcheck(t, args...)
+			if code != tc.wantCode {
+				t.Errorf("profcheck %v: got exit code %d, want %d\n%s", args, code, tc.wantCode, out)
+			}
+			out = strings.ReplaceAll(out, dir+string(filepath.Separator), "")
+			for _, want := range tc.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("profcheck %v: got output\n%s\nwant it to contain %q", args, out, want)
+				}
+			}
+		})
+	}
+}
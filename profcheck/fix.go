@@ -0,0 +1,277 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profcheck
+
+import (
+	"fmt"
+	"slices"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	profiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
+	"google.golang.org/protobuf/proto"
+)
+
+// Canonicalize returns a copy of data with the repairs applied that provably
+// preserve its semantics:
+//
+//   - dictionary entries that are not reachable from any profile are dropped,
+//     including entries only referenced by other unreachable entries,
+//   - duplicate strings are merged into the first occurrence,
+//   - attribute_indices are sorted, as attributes are unordered.
+//
+// The zero value entries at index 0 are kept and all references are
+// rewritten. data must pass the checks of the zero ConformanceChecker, as
+// invalid references can't be rewritten.
+func Canonicalize(data *profiles.ProfilesData) (*profiles.ProfilesData, error) {
+	if err := (ConformanceChecker{}).Check(data); err != nil {
+		return nil, fmt.Errorf("cannot canonicalize non-conformant data: %w", err)
+	}
+	out := proto.Clone(data).(*profiles.ProfilesData)
+	dict := out.Dictionary
+
+	// Mark the reachable entries, following the references from the profiles
+	// through the tables in dependency order.
+	keep := dictionaryKeep{
+		str:      make([]bool, len(dict.StringTable)),
+		attr:     make([]bool, len(dict.AttributeTable)),
+		mapping:  make([]bool, len(dict.MappingTable)),
+		function: make([]bool, len(dict.FunctionTable)),
+		location: make([]bool, len(dict.LocationTable)),
+		stack:    make([]bool, len(dict.StackTable)),
+		link:     make([]bool, len(dict.LinkTable)),
+	}
+	// References that the basic checks don't cover, e.g. in nested
+	// attribute values, may be out of range. They are left as they are.
+	mark := func(table []bool) func(*int32) {
+		table[0] = true
+		return func(idx *int32) {
+			if *idx >= 0 && int(*idx) < len(table) {
+				table[*idx] = true
+			}
+		}
+	}
+	v := indexVisitor{
+		str:      mark(keep.str),
+		attr:     mark(keep.attr),
+		mapping:  mark(keep.mapping),
+		function: mark(keep.function),
+		location: mark(keep.location),
+		stack:    mark(keep.stack),
+		link:     mark(keep.link),
+	}
+	v.profiles(out)
+	visitKept(keep.stack, dict.StackTable, v.stackEntry)
+	visitKept(keep.location, dict.LocationTable, v.locationEntry)
+	visitKept(keep.mapping, dict.MappingTable, v.mappingEntry)
+	visitKept(keep.function, dict.FunctionTable, v.functionEntry)
+	visitKept(keep.attr, dict.AttributeTable, v.attributeEntry)
+
+	// Drop the unreachable entries and rewrite the references of the kept
+	// ones.
+	var remap dictionaryRemap
+	dict.StackTable, remap.stack = compact(dict.StackTable, keep.stack)
+	dict.LocationTable, remap.location = compact(dict.LocationTable, keep.location)
+	dict.MappingTable, remap.mapping = compact(dict.MappingTable, keep.mapping)
+	dict.FunctionTable, remap.function = compact(dict.FunctionTable, keep.function)
+	dict.AttributeTable, remap.attr = compact(dict.AttributeTable, keep.attr)
+	dict.LinkTable, remap.link = compact(dict.LinkTable, keep.link)
+	dict.StringTable, remap.str = compactStrings(dict.StringTable, keep.str)
+	rewrite := func(table []int32) func(*int32) {
+		return func(idx *int32) {
+			if *idx >= 0 && int(*idx) < len(table) {
+				*idx = table[*idx]
+			}
+		}
+	}
+	v = indexVisitor{
+		str:      rewrite(remap.str),
+		attr:     rewrite(remap.attr),
+		mapping:  rewrite(remap.mapping),
+		function: rewrite(remap.function),
+		location: rewrite(remap.location),
+		stack:    rewrite(remap.stack),
+		link:     rewrite(remap.link),
+	}
+	v.profiles(out)
+	for _, stack := range dict.StackTable {
+		v.stackEntry(stack)
+	}
+	for _, loc := range dict.LocationTable {
+		v.locationEntry(loc)
+		slices.Sort(loc.AttributeIndices)
+	}
+	for _, m := range dict.MappingTable {
+		v.mappingEntry(m)
+		slices.Sort(m.AttributeIndices)
+	}
+	for _, fnc := range dict.FunctionTable {
+		v.functionEntry(fnc)
+	}
+	for _, attr := range dict.AttributeTable {
+		v.attributeEntry(attr)
+	}
+	for _, rp := range out.ResourceProfiles {
+		for _, sp := range rp.ScopeProfiles {
+			for _, prof := range sp.Profiles {
+				slices.Sort(prof.AttributeIndices)
+				for _, s := range prof.Samples {
+					slices.Sort(s.AttributeIndices)
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// dictionaryKeep holds whether each entry of the dictionary tables is kept.
+type dictionaryKeep struct {
+	str, attr, mapping, function, location, stack, link []bool
+}
+
+// dictionaryRemap maps the old indices of the dictionary tables to the new
+// ones.
+type dictionaryRemap struct {
+	str, attr, mapping, function, location, stack, link []int32
+}
+
+func visitKept[T any](keep []bool, table []*T, visit func(*T)) {
+	for i, entry := range table {
+		if keep[i] {
+			visit(entry)
+		}
+	}
+}
+
+// compact returns the kept entries of table and the mapping from old to new
+// indices. Dropped entries map to 0, they are no longer referenced.
+func compact[T any](table []*T, keep []bool) ([]*T, []int32) {
+	remap := make([]int32, len(table))
+	var kept []*T
+	for i, entry := range table {
+		if keep[i] {
+			remap[i] = int32(len(kept))
+			kept = append(kept, entry)
+		}
+	}
+	return kept, remap
+}
+
+// compactStrings is compact for the string table, which in addition merges
+// duplicate strings.
+func compactStrings(table []string, keep []bool) ([]string, []int32) {
+	remap := make([]int32, len(table))
+	var kept []string
+	index := map[string]int32{}
+	for i, s := range table {
+		if !keep[i] {
+			continue
+		}
+		idx, ok := index[s]
+		if !ok {
+			idx = int32(len(kept))
+			index[s] = idx
+			kept = append(kept, s)
+		}
+		remap[i] = idx
+	}
+	return kept, remap
+}
+
+// indexVisitor calls the function of the referenced table for every
+// dictionary reference, so that the same traversal can collect and rewrite
+// references.
+type indexVisitor struct {
+	str, attr, mapping, function, location, stack, link func(*int32)
+}
+
+// profiles visits the references of the resource, scope and profile
+// messages of data, but not the ones within the dictionary.
+func (v indexVisitor) profiles(data *profiles.ProfilesData) {
+	for _, rp := range data.ResourceProfiles {
+		v.keyValues(rp.GetResource().GetAttributes())
+		for _, sp := range rp.ScopeProfiles {
+			v.keyValues(sp.GetScope().GetAttributes())
+			for _, prof := range sp.Profiles {
+				for _, vt := range []*profiles.ValueType{prof.SampleType, prof.PeriodType} {
+					if vt != nil {
+						v.str(&vt.TypeStrindex)
+						v.str(&vt.UnitStrindex)
+					}
+				}
+				v.all(v.attr, prof.AttributeIndices)
+				for _, s := range prof.Samples {
+					v.stack(&s.StackIndex)
+					v.link(&s.LinkIndex)
+					v.all(v.attr, s.AttributeIndices)
+				}
+			}
+		}
+	}
+}
+
+func (v indexVisitor) all(visit func(*int32), indices []int32) {
+	for i := range indices {
+		visit(&indices[i])
+	}
+}
+
+func (v indexVisitor) stackEntry(stack *profiles.Stack) {
+	v.all(v.location, stack.LocationIndices)
+}
+
+func (v indexVisitor) locationEntry(loc *profiles.Location) {
+	v.mapping(&loc.MappingIndex)
+	for _, line := range loc.Lines {
+		v.function(&line.FunctionIndex)
+	}
+	v.all(v.attr, loc.AttributeIndices)
+}
+
+func (v indexVisitor) mappingEntry(m *profiles.Mapping) {
+	v.str(&m.FilenameStrindex)
+	v.all(v.attr, m.AttributeIndices)
+}
+
+func (v indexVisitor) functionEntry(fnc *profiles.Function) {
+	v.str(&fnc.NameStrindex)
+	v.str(&fnc.SystemNameStrindex)
+	v.str(&fnc.FilenameStrindex)
+}
+
+func (v indexVisitor) attributeEntry(attr *profiles.KeyValueAndUnit) {
+	v.str(&attr.KeyStrindex)
+	v.str(&attr.UnitStrindex)
+	v.anyValue(attr.Value)
+}
+
+func (v indexVisitor) keyValues(kvs []*common.KeyValue) {
+	for _, kv := range kvs {
+		v.str(&kv.KeyStrindex)
+		v.anyValue(kv.Value)
+	}
+}
+
+func (v indexVisitor) anyValue(av *common.AnyValue) {
+	switch val := av.GetValue().(type) {
+	case *common.AnyValue_StringValueStrindex:
+		v.str(&val.StringValueStrindex)
+	case *common.AnyValue_ArrayValue:
+		for _, elem := range val.ArrayValue.GetValues() {
+			v.anyValue(elem)
+		}
+	case *common.AnyValue_KvlistValue:
+		v.keyValues(val.KvlistValue.GetValues())
+	}
+}
@@ -0,0 +1,129 @@
+package profcheck
+
+import (
+	"testing"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	profiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCanonicalize(t *testing.T) {
+	data := &profiles.ProfilesData{
+		Dictionary: &profiles.ProfilesDictionary{
+			MappingTable: []*profiles.Mapping{{}, {FilenameStrindex: 5}},
+			// Location 2 is only referenced by the unreferenced stack 2.
+			LocationTable: []*profiles.Location{
+				{},
+				{MappingIndex: 1, Lines: []*profiles.Line{{FunctionIndex: 1}}, AttributeIndices: []int32{3, 1}},
+				{Lines: []*profiles.Line{{FunctionIndex: 2}}},
+			},
+			FunctionTable: []*profiles.Function{{}, {NameStrindex: 1}, {NameStrindex: 3}},
+			LinkTable:     []*profiles.Link{{}, {TraceId: make([]byte, 16), SpanId: make([]byte, 8)}},
+			// "main" is duplicated and "dead" is only referenced by the
+			// unreachable function 2.
+			StringTable: []string{"", "main", "main", "dead", "thread.name", "libc.so", "service.name", "api"},
+			AttributeTable: []*profiles.KeyValueAndUnit{
+				{},
+				{KeyStrindex: 4, Value: &common.AnyValue{Value: &common.AnyValue_StringValueStrindex{StringValueStrindex: 2}}},
+				{KeyStrindex: 4, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "unused"}}},
+				{KeyStrindex: 6, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "other"}}},
+			},
+			StackTable: []*profiles.Stack{{}, {LocationIndices: []int32{1}}, {LocationIndices: []int32{2}}},
+		},
+		ResourceProfiles: []*profiles.ResourceProfiles{{
+			Resource: &resource.Resource{
+				Attributes: []*common.KeyValue{{
+					KeyStrindex: 6,
+					Value:       &common.AnyValue{Value: &common.AnyValue_StringValueStrindex{StringValueStrindex: 7}},
+				}},
+			},
+			ScopeProfiles: []*profiles.ScopeProfiles{{
+				Profiles: []*profiles.Profile{{
+					Samples: []*profiles.Sample{{StackIndex: 1, Values: []int64{1}, AttributeIndices: []int32{3, 1}}},
+				}},
+			}},
+		}},
+	}
+	input := proto.Clone(data)
+
+	got, err := Canonicalize(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &profiles.ProfilesData{
+		Dictionary: &profiles.ProfilesDictionary{
+			MappingTable: []*profiles.Mapping{{}, {FilenameStrindex: 3}},
+			LocationTable: []*profiles.Location{
+				{},
+				{MappingIndex: 1, Lines: []*profiles.Line{{FunctionIndex: 1}}, AttributeIndices: []int32{1, 2}},
+			},
+			FunctionTable: []*profiles.Function{{}, {NameStrindex: 1}},
+			LinkTable:     []*profiles.Link{{}},
+			StringTable:   []string{"", "main", "thread.name", "libc.so", "service.name", "api"},
+			AttributeTable: []*profiles.KeyValueAndUnit{
+				{},
+				{KeyStrindex: 2, Value: &common.AnyValue{Value: &common.AnyValue_StringValueStrindex{StringValueStrindex: 1}}},
+				{KeyStrindex: 4, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "other"}}},
+			},
+			StackTable: []*profiles.Stack{{}, {LocationIndices: []int32{1}}},
+		},
+		ResourceProfiles: []*profiles.ResourceProfiles{{
+			Resource: &resource.Resource{
+				Attributes: []*common.KeyValue{{
+					KeyStrindex: 4,
+					Value:       &common.AnyValue{Value: &common.AnyValue_StringValueStrindex{StringValueStrindex: 5}},
+				}},
+			},
+			ScopeProfiles: []*profiles.ScopeProfiles{{
+				Profiles: []*profiles.Profile{{
+					Samples: []*profiles.Sample{{StackIndex: 1, Values: []int64{1}, AttributeIndices: []int32{1, 2}}},
+				}},
+			}},
+		}},
+	}
+	if !proto.Equal(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", prototext.Format(got), prototext.Format(want))
+	}
+	if !proto.Equal(data, input) {
+		t.Error("Canonicalize modified its input")
+	}
+
+	c := ConformanceChecker{CheckDictionaryDuplicates: true, CheckDictionaryOrphans: true}
+	if err := c.Check(got); err != nil {
+		t.Errorf("canonicalized data fails the checks: %v", err)
+	}
+	again, err := Canonicalize(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(again, got) {
+		t.Errorf("Canonicalize is not idempotent, got:\n%s", prototext.Format(again))
+	}
+}
+
+func TestCanonicalizeNonConformant(t *testing.T) {
+	data := &profiles.ProfilesData{
+		Dictionary: &profiles.ProfilesDictionary{
+			MappingTable:   []*profiles.Mapping{{}},
+			LocationTable:  []*profiles.Location{{}},
+			FunctionTable:  []*profiles.Function{{}},
+			LinkTable:      []*profiles.Link{{}},
+			StringTable:    []string{""},
+			AttributeTable: []*profiles.KeyValueAndUnit{{}},
+			StackTable:     []*profiles.Stack{{}},
+		},
+		ResourceProfiles: []*profiles.ResourceProfiles{{
+			ScopeProfiles: []*profiles.ScopeProfiles{{
+				Profiles: []*profiles.Profile{{
+					Samples: []*profiles.Sample{{StackIndex: 5}},
+				}},
+			}},
+		}},
+	}
+	if _, err := Canonicalize(data); err == nil {
+		t.Error("expected error for an out of range stack_index")
+	}
+}
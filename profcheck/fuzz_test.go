@@ -0,0 +1,38 @@
+package profcheck
+
+import (
+	"testing"
+
+	profiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
+	"google.golang.org/protobuf/proto"
+)
+
+// FuzzCheck verifies that checking arbitrary bytes decoded as ProfilesData
+// never panics, as profcheck is run on untrusted input, and that
+// canonicalizing conformant data keeps it conformant.
+func FuzzCheck(f *testing.F) {
+	for _, tc := range conformanceTestCases() {
+		data, err := proto.Marshal(tc.data)
+		if err != nil {
+			f.Fatalf("%s: %v", tc.desc, err)
+		}
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var data profiles.ProfilesData
+		if err := proto.Unmarshal(b, &data); err != nil {
+			return
+		}
+		_ = ConformanceChecker{}.Check(&data)
+		for _, f := range StrictConformanceChecker().Report(&data) {
+			if f.Check == "" {
+				t.Errorf("finding %q has no check", f.Message)
+			}
+		}
+		if fixed, err := Canonicalize(&data); err == nil {
+			if err := (ConformanceChecker{}).Check(fixed); err != nil {
+				t.Errorf("canonicalized data fails the checks: %v", err)
+			}
+		}
+	})
+}
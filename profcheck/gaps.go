@@ -0,0 +1,84 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profcheck
+
+import (
+	"fmt"
+
+	profiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
+)
+
+// Thresholds above which trailing unreferenced entries of a table are
+// reported as suspicious.
+const (
+	minSuspiciousGap      = 8
+	minSuspiciousGapRatio = 0.1
+)
+
+// TableGap describes how far a dictionary table extends past its highest
+// referenced index. Many trailing unreferenced entries are a cheap hint that
+// a table was appended to without its references being updated.
+type TableGap struct {
+	Table string
+	Len   int
+	// MaxReferenced is the highest referenced index, or 0 if only the
+	// zero-value sentinel is referenced.
+	MaxReferenced int
+}
+
+// Trailing returns the number of entries after MaxReferenced.
+func (g TableGap) Trailing() int {
+	return max(g.Len-1-g.MaxReferenced, 0)
+}
+
+// Suspicious reports whether there are many trailing unreferenced entries,
+// both in absolute terms and relative to the table length.
+func (g TableGap) Suspicious() bool {
+	trailing := g.Trailing()
+	return trailing >= minSuspiciousGap && float64(trailing) >= minSuspiciousGapRatio*float64(g.Len)
+}
+
+func (g TableGap) String() string {
+	s := fmt.Sprintf("%s len=%d max_referenced=%d trailing_unreferenced=%d", g.Table, g.Len, g.MaxReferenced, g.Trailing())
+	if g.Suspicious() {
+		s += " (suspicious)"
+	}
+	return s
+}
+
+// ComputeGaps returns the highest referenced index of every dictionary table
+// in data. Out of range references are ignored.
+func ComputeGaps(data *profiles.ProfilesData) []TableGap {
+	dict := data.GetDictionary()
+	refs := collectReferences(data)
+	gap := func(table string, length int, refs map[int32]bool) TableGap {
+		g := TableGap{Table: table, Len: length}
+		for idx := range refs {
+			if int(idx) < length && int(idx) > g.MaxReferenced {
+				g.MaxReferenced = int(idx)
+			}
+		}
+		return g
+	}
+	return []TableGap{
+		gap("mapping_table", len(dict.GetMappingTable()), refs.mapping),
+		gap("location_table", len(dict.GetLocationTable()), refs.location),
+		gap("function_table", len(dict.GetFunctionTable()), refs.function),
+		gap("link_table", len(dict.GetLinkTable()), refs.link),
+		gap("string_table", len(dict.GetStringTable()), refs.str),
+		gap("attribute_table", len(dict.GetAttributeTable()), refs.attr),
+		gap("stack_table", len(dict.GetStackTable()), refs.stack),
+	}
+}
@@ -0,0 +1,51 @@
+package profcheck
+
+import (
+	"testing"
+
+	profiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
+)
+
+func TestComputeGaps(t *testing.T) {
+	data := &profiles.ProfilesData{
+		Dictionary: &profiles.ProfilesDictionary{
+			MappingTable:   []*profiles.Mapping{{}},
+			LocationTable:  make([]*profiles.Location, 20),
+			FunctionTable:  []*profiles.Function{{}},
+			LinkTable:      []*profiles.Link{{}},
+			StringTable:    []string{""},
+			AttributeTable: []*profiles.KeyValueAndUnit{{}},
+			StackTable: []*profiles.Stack{
+				{},
+				{LocationIndices: []int32{1, 3}},
+				{LocationIndices: []int32{99}}, // Out of range.
+			},
+		},
+		ResourceProfiles: []*profiles.ResourceProfiles{{
+			ScopeProfiles: []*profiles.ScopeProfiles{{
+				Profiles: []*profiles.Profile{{
+					Samples: []*profiles.Sample{{StackIndex: 1}},
+				}},
+			}},
+		}},
+	}
+	for i := range data.Dictionary.LocationTable {
+		data.Dictionary.LocationTable[i] = &profiles.Location{}
+	}
+
+	want := map[string]string{
+		"mapping_table":   "mapping_table len=1 max_referenced=0 trailing_unreferenced=0",
+		"location_table":  "location_table len=20 max_referenced=3 trailing_unreferenced=16 (suspicious)",
+		"stack_table":     "stack_table len=3 max_referenced=1 trailing_unreferenced=1",
+		"attribute_table": "attribute_table len=1 max_referenced=0 trailing_unreferenced=0",
+	}
+	gaps := ComputeGaps(data)
+	if len(gaps) != 7 {
+		t.Fatalf("ComputeGaps(): got %d tables, want 7", len(gaps))
+	}
+	for _, g := range gaps {
+		if w, ok := want[g.Table]; ok && g.String() != w {
+			t.Errorf("ComputeGaps(): got %q, want %q", g, w)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profcheck
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	profiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
+)
+
+// Defect is a kind of non-conformance that Generate can introduce into an
+// otherwise conformant profile.
+type Defect struct {
+	Name        string
+	Description string
+	apply       func(data *profiles.ProfilesData)
+}
+
+// Defects are the defects known to Generate.
+var Defects = []Defect{{
+	Name:        "out-of-range-index",
+	Description: "a sample references a stack past the end of the stack table",
+	apply: func(data *profiles.ProfilesData) {
+		firstProfile(data).Samples[0].StackIndex = int32(len(data.Dictionary.StackTable))
+	},
+}, {
+	Name:        "duplicate-string",
+	Description: "the function system name references a second copy of its name in the string table",
+	apply: func(data *profiles.ProfilesData) {
+		dict := data.Dictionary
+		dict.FunctionTable[1].SystemNameStrindex = int32(len(dict.StringTable))
+		dict.StringTable = append(dict.StringTable, dict.StringTable[dict.FunctionTable[1].NameStrindex])
+	},
+}, {
+	Name:        "orphan-string",
+	Description: "the string table has an entry that nothing references",
+	apply: func(data *profiles.ProfilesData) {
+		data.Dictionary.StringTable = append(data.Dictionary.StringTable, "unused")
+	},
+}, {
+	Name:        "non-empty-zero-string",
+	Description: "the zero value string at index 0 is not empty",
+	apply: func(data *profiles.ProfilesData) {
+		data.Dictionary.StringTable[0] = "zero"
+	},
+}, {
+	Name:        "timestamp-out-of-range",
+	Description: "a sample timestamp lies after the end of the profile",
+	apply: func(data *profiles.ProfilesData) {
+		prof := firstProfile(data)
+		prof.Samples[0].TimestampsUnixNano = []uint64{prof.TimeUnixNano + prof.DurationNano + 1}
+		// The other samples are timestamped too, so that only the range
+		// and not the sample shape is wrong.
+		for _, s := range prof.Samples[1:] {
+			s.TimestampsUnixNano = []uint64{prof.TimeUnixNano}
+		}
+	},
+}, {
+	Name:        "negative-value",
+	Description: "a cpu sample has a negative value",
+	apply: func(data *profiles.ProfilesData) {
+		firstProfile(data).Samples[0].Values[0] = -1
+	},
+}, {
+	Name:        "missing-sample-type",
+	Description: "the profile has no sample type",
+	apply: func(data *profiles.ProfilesData) {
+		firstProfile(data).SampleType = nil
+	},
+}, {
+	Name:        "long-duration",
+	Description: "the profile duration is 30 days, longer than any plausible profile",
+	apply: func(data *profiles.ProfilesData) {
+		firstProfile(data).DurationNano = uint64(30 * 24 * time.Hour)
+	},
+}}
+
+// Generate returns a small synthetic profile with the named defect, which
+// must be the name of one of Defects. Without a defect, the profile passes
+// the checks of StrictConformanceChecker. The result is the same on every
+// call, so that it can be used to reproduce issues.
+func Generate(defect string) (*profiles.ProfilesData, error) {
+	data := conformantProfilesData()
+	if defect == "" {
+		return data, nil
+	}
+	var names []string
+	for _, d := range Defects {
+		if d.Name == defect {
+			d.apply(data)
+			return data, nil
+		}
+		names = append(names, d.Name)
+	}
+	return nil, fmt.Errorf("unknown defect %q, must be one of %s", defect, strings.Join(names, ", "))
+}
+
+// conformantProfilesData returns a cpu profile of a single process with two
+// samples of the same stack, one of them with a thread name attribute.
+func conformantProfilesData() *profiles.ProfilesData {
+	const start = uint64(1_700_000_000_000_000_000)
+	return &profiles.ProfilesData{
+		Dictionary: &profiles.ProfilesDictionary{
+			StringTable: []string{"", "cpu", "nanoseconds", "main", "main.go", "/usr/bin/app", "thread.name"},
+			MappingTable: []*profiles.Mapping{{}, {
+				MemoryStart:      0x1000,
+				MemoryLimit:      0x2000,
+				FilenameStrindex: 5,
+			}},
+			FunctionTable: []*profiles.Function{{}, {
+				NameStrindex:       3,
+				SystemNameStrindex: 3,
+				FilenameStrindex:   4,
+				StartLine:          10,
+			}},
+			LocationTable: []*profiles.Location{{}, {
+				MappingIndex: 1,
+				Address:      0x1100,
+				Lines:        []*profiles.Line{{FunctionIndex: 1, Line: 12}},
+			}},
+			StackTable: []*profiles.Stack{{}, {LocationIndices: []int32{1}}},
+			LinkTable:  []*profiles.Link{{}},
+			AttributeTable: []*profiles.KeyValueAndUnit{{}, {
+				KeyStrindex: 6,
+				Value:       &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "worker"}},
+			}},
+		},
+		ResourceProfiles: []*profiles.ResourceProfiles{{
+			ScopeProfiles: []*profiles.ScopeProfiles{{
+				Profiles: []*profiles.Profile{{
+					SampleType:   &profiles.ValueType{TypeStrindex: 1, UnitStrindex: 2},
+					PeriodType:   &profiles.ValueType{TypeStrindex: 1, UnitStrindex: 2},
+					Period:       int64(10 * time.Millisecond),
+					TimeUnixNano: start,
+					DurationNano: uint64(10 * time.Second),
+					Samples: []*profiles.Sample{
+						{StackIndex: 1, Values: []int64{int64(30 * time.Millisecond)}},
+						{StackIndex: 1, Values: []int64{int64(20 * time.Millisecond)}, AttributeIndices: []int32{1}},
+					},
+				}},
+			}},
+		}},
+	}
+}
+
+func firstProfile(data *profiles.ProfilesData) *profiles.Profile {
+	return data.ResourceProfiles[0].ScopeProfiles[0].Profiles[0]
+}
@@ -0,0 +1,56 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profcheck
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestGenerate(t *testing.T) {
+	c := StrictConformanceChecker()
+	data, err := Generate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if findings := c.Report(data); len(findings) > 0 {
+		t.Fatalf("got findings %v for the profile without defects, want none", findings)
+	}
+
+	for _, d := range Defects {
+		t.Run(d.Name, func(t *testing.T) {
+			data, err := Generate(d.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			findings := c.Report(data)
+			if len(findings) == 0 {
+				t.Fatalf("got no findings, want some for %s", d.Description)
+			}
+			again, err := Generate(d.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(data, again) {
+				t.Error("got a different profile on the second call, want the same")
+			}
+		})
+	}
+
+	if _, err := Generate("no-such-defect"); err == nil {
+		t.Error("expected error for an unknown defect")
+	}
+}
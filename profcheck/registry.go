@@ -0,0 +1,150 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profcheck
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	profiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
+)
+
+// Check is an optional conformance check. It reports its findings to ctx.
+// Checks are registered with RegisterCheck and run by a ConformanceChecker
+// whose active checks include them, see ActiveChecks. The built-in checks
+// register themselves, custom checks can add rules that only apply within an
+// organization, such as required resource attributes.
+type Check func(ctx *CheckContext)
+
+// CheckContext is the data a Check runs on and collects its findings.
+type CheckContext struct {
+	// Data is the checked data, which must not be modified. The registered
+	// checks only run if the required checks found it structurally sound
+	// enough to be traversed: it has resource profiles and a dictionary
+	// without nil entries. References may still be out of range.
+	Data *profiles.ProfilesData
+	// Checker is the checker running the check, e.g. to look up
+	// KnownUnits. The fields enabling the built-in checks are set to
+	// whether they are active.
+	Checker ConformanceChecker
+
+	errs error
+}
+
+// Errorf reports an error finding at path, e.g.
+// "resource_profiles[0].resource.attributes[2]", or for the whole data if
+// path is empty.
+func (ctx *CheckContext) Errorf(path, format string, args ...any) {
+	ctx.report(path, fmt.Errorf(format, args...))
+}
+
+// Warnf reports a warning finding at path, see Errorf.
+func (ctx *CheckContext) Warnf(path, format string, args ...any) {
+	ctx.report(path, warnf(format, args...))
+}
+
+func (ctx *CheckContext) report(path string, err error) {
+	if path != "" {
+		err = prefixErrorf(err, "%s", path)
+	}
+	ctx.errs = errors.Join(ctx.errs, err)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Check{}
+)
+
+// RegisterCheck registers check under name, so that a ConformanceChecker
+// listing name in Checks runs it. It is meant to be called from the init
+// function of the package defining the check, and panics if name is empty or
+// already registered. To replace a built-in check, register the replacement
+// under another name and list the built-in one in DisabledChecks.
+func RegisterCheck(name string, check Check) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if name == "" {
+		panic("profcheck: RegisterCheck with empty name")
+	}
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("profcheck: check %q registered twice", name))
+	}
+	registry[name] = check
+}
+
+// RegisteredChecks returns the names of the registered checks, sorted.
+func RegisteredChecks() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return slices.Sorted(maps.Keys(registry))
+}
+
+// ActiveChecks returns the names of the registered checks c runs after the
+// required checks, in order: the built-in checks enabled by their fields,
+// then the other Checks, without the DisabledChecks.
+func (c ConformanceChecker) ActiveChecks() []string {
+	var active []string
+	for _, b := range builtinChecks {
+		if *b.enabled(&c) {
+			active = append(active, b.name)
+		}
+	}
+	for _, name := range c.Checks {
+		if !slices.Contains(active, name) {
+			active = append(active, name)
+		}
+	}
+	return slices.DeleteFunc(active, func(name string) bool {
+		return slices.Contains(c.DisabledChecks, name)
+	})
+}
+
+// runRegisteredChecks runs the active checks of c on data. Names that are
+// not registered are reported as errors, as they usually are a typo in the
+// configuration.
+func (c ConformanceChecker) runRegisteredChecks(data *profiles.ProfilesData) error {
+	active := c.ActiveChecks()
+	// Built-in checks may depend on each other's fields, e.g. sample-type-set
+	// on RequireSamples, so they see the active set rather than the fields.
+	checker := c
+	for _, b := range builtinChecks {
+		*b.enabled(&checker) = slices.Contains(active, b.name)
+	}
+	var errs error
+	for _, name := range c.DisabledChecks {
+		registryMu.RLock()
+		_, ok := registry[name]
+		registryMu.RUnlock()
+		if !ok {
+			errs = errors.Join(errs, inCheck("unknown-check", fmt.Errorf("unknown disabled check %q, registered checks are %v", name, RegisteredChecks())))
+		}
+	}
+	for _, name := range active {
+		registryMu.RLock()
+		check, ok := registry[name]
+		registryMu.RUnlock()
+		if !ok {
+			errs = errors.Join(errs, inCheck("unknown-check", fmt.Errorf("unknown check %q, registered checks are %v", name, RegisteredChecks())))
+			continue
+		}
+		ctx := &CheckContext{Data: data, Checker: checker}
+		check(ctx)
+		errs = errors.Join(errs, inCheck(name, ctx.errs))
+	}
+	return errs
+}
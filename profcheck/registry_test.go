@@ -0,0 +1,177 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profcheck
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	profiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func init() {
+	// An organization-specific rule, as a downstream package would register.
+	RegisterCheck("test-service-name", func(ctx *CheckContext) {
+		for i, rp := range ctx.Data.ResourceProfiles {
+			if !slices.ContainsFunc(rp.GetResource().GetAttributes(), func(kv *common.KeyValue) bool {
+				return kv.Key == "service.name"
+			}) {
+				ctx.Errorf(fmt.Sprintf("resource_profiles[%d].resource.attributes", i), "missing service.name")
+			}
+		}
+	})
+	RegisterCheck("test-warning", func(ctx *CheckContext) {
+		ctx.Warnf("", "always warns")
+	})
+}
+
+func TestRegisteredChecks(t *testing.T) {
+	data := &profiles.ProfilesData{
+		Dictionary: &profiles.ProfilesDictionary{
+			MappingTable:   []*profiles.Mapping{{}},
+			LocationTable:  []*profiles.Location{{}},
+			FunctionTable:  []*profiles.Function{{}},
+			LinkTable:      []*profiles.Link{{}},
+			StringTable:    []string{""},
+			AttributeTable: []*profiles.KeyValueAndUnit{{}},
+			StackTable:     []*profiles.Stack{{}},
+		},
+		ResourceProfiles: []*profiles.ResourceProfiles{{
+			Resource: &resource.Resource{Attributes: []*common.KeyValue{{Key: "service.name", Value: makeAnyValue("a")}}},
+			ScopeProfiles: []*profiles.ScopeProfiles{{
+				Profiles: []*profiles.Profile{{}},
+			}},
+		}, {
+			ScopeProfiles: []*profiles.ScopeProfiles{{
+				Profiles: []*profiles.Profile{{}},
+			}},
+		}},
+	}
+
+	if err := (ConformanceChecker{}).Check(data); err != nil {
+		t.Fatalf("Check() without registered checks: %v", err)
+	}
+
+	c := ConformanceChecker{Checks: []string{"test-service-name", "test-warning"}}
+	want := []Finding{{
+		Severity: SeverityError,
+		Check:    "test-service-name",
+		Path:     []any{"resource_profiles", 1, "resource", "attributes"},
+		Message:  "resource_profiles[1].resource.attributes: missing service.name",
+	}, {
+		Severity: SeverityWarning,
+		Check:    "test-warning",
+		Message:  "always warns",
+	}}
+	if got := c.Report(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("Report(): got %v, want %v", got, want)
+	}
+
+	c = ConformanceChecker{Checks: []string{"no-such-check"}}
+	if err := c.Check(data); err == nil {
+		t.Error("Check(): expected error for an unknown check")
+	}
+
+	for _, name := range []string{"test-service-name", "test-warning"} {
+		if !slices.Contains(RegisteredChecks(), name) {
+			t.Errorf("RegisteredChecks(): got %v, want it to contain %q", RegisteredChecks(), name)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("RegisterCheck(): expected panic for a name registered twice")
+		}
+	}()
+	RegisterCheck("test-warning", func(*CheckContext) {})
+}
+
+func TestBuiltinChecks(t *testing.T) {
+	data := &profiles.ProfilesData{
+		Dictionary: &profiles.ProfilesDictionary{
+			MappingTable:   []*profiles.Mapping{{}},
+			LocationTable:  []*profiles.Location{{}},
+			FunctionTable:  []*profiles.Function{{}},
+			LinkTable:      []*profiles.Link{{}},
+			StringTable:    []string{"", "a", "a"},
+			AttributeTable: []*profiles.KeyValueAndUnit{{}},
+			StackTable:     []*profiles.Stack{{}},
+		},
+		ResourceProfiles: []*profiles.ResourceProfiles{{
+			ScopeProfiles: []*profiles.ScopeProfiles{{
+				Profiles: []*profiles.Profile{{}},
+			}},
+		}},
+	}
+	const duplicate = "dictionary: string_table: duplicate string at index 2, orig index 1: a"
+
+	for _, name := range []string{"dictionary-duplicates", "sample-timestamp-shape", "require-samples", "timestamp-span"} {
+		if !slices.Contains(RegisteredChecks(), name) {
+			t.Errorf("RegisteredChecks(): got %v, want it to contain %q", RegisteredChecks(), name)
+		}
+	}
+
+	for _, tc := range []struct {
+		desc       string
+		c          ConformanceChecker
+		wantActive []string
+		wantErr    string
+		// notErr is an error the active checks must not report.
+		notErr string
+	}{{
+		desc:       "enabled by field",
+		c:          ConformanceChecker{CheckDictionaryDuplicates: true},
+		wantActive: []string{"dictionary-duplicates"},
+		wantErr:    duplicate,
+	}, {
+		desc:       "enabled by name",
+		c:          ConformanceChecker{Checks: []string{"dictionary-duplicates"}},
+		wantActive: []string{"dictionary-duplicates"},
+		wantErr:    duplicate,
+	}, {
+		desc:       "disabled",
+		c:          ConformanceChecker{CheckDictionaryDuplicates: true, RequireSamples: true, DisabledChecks: []string{"dictionary-duplicates"}},
+		wantActive: []string{"require-samples"},
+		wantErr:    "profile[0]: profile has no samples",
+		notErr:     duplicate,
+	}, {
+		desc:       "replaced",
+		c:          ConformanceChecker{CheckDictionaryDuplicates: true, Checks: []string{"test-warning"}, DisabledChecks: []string{"dictionary-duplicates"}},
+		wantActive: []string{"test-warning"},
+	}, {
+		desc:    "unknown disabled check",
+		c:       ConformanceChecker{DisabledChecks: []string{"no-such-check"}},
+		wantErr: `unknown disabled check "no-such-check"`,
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := tc.c.ActiveChecks(); !slices.Equal(got, tc.wantActive) {
+				t.Errorf("ActiveChecks(): got %v, want %v", got, tc.wantActive)
+			}
+			err := tc.c.Check(data)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("Check(): got error %q, want no error", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("Check(): got error %v, want error containing %q", err, tc.wantErr)
+			case tc.notErr != "" && strings.Contains(err.Error(), tc.notErr):
+				t.Errorf("Check(): got error %q, want no error containing %q", err, tc.notErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profcheck
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	profiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
+)
+
+// Severity is the severity of a Finding.
+type Severity int
+
+const (
+	// SeverityError findings make the data non-conformant.
+	SeverityError Severity = iota
+	// SeverityWarning findings are surfaced for review but do not fail the
+	// conformance checks.
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "error"
+	}
+}
+
+// MarshalText encodes s as its name, e.g. "warning".
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Finding is a single problem found by the conformance checks. It encodes
+// to JSON with the path as an array, so that findings can be filtered with
+// e.g. jq 'select(.path[0] == "dictionary")'.
+type Finding struct {
+	Severity Severity `json:"severity"`
+	// Check identifies the check that found the problem: the name of a
+	// registered check, e.g. "dictionary-duplicates", or the category of a
+	// required check, e.g. "index-range" for dangling references.
+	Check string `json:"check"`
+	// Path is the path of the offending field as field names and indices,
+	// e.g. ["resource_profiles", 0, "scope_profiles", 1, "profile", 0,
+	// "sample", 3, "stack_index"]. Each element is a string or an int.
+	Path []any `json:"path"`
+	// Message describes the problem, prefixed with the path of the offending
+	// field, e.g. "resource_profiles[0]: scope_profiles[0]: ...".
+	Message string `json:"message"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s", f.Severity, f.Message)
+}
+
+// Report runs all enabled checks on data and returns every finding, errors
+// and warnings, in a deterministic order.
+func (c ConformanceChecker) Report(data *profiles.ProfilesData) []Finding {
+	var findings []Finding
+	for _, err := range flattenErrors(c.check(data)) {
+		severity := SeverityError
+		if isWarning(err) {
+			severity = SeverityWarning
+		}
+		var ce checkError
+		errors.As(err, &ce)
+		findings = append(findings, Finding{Severity: severity, Check: ce.check, Path: findingPath(err), Message: err.Error()})
+	}
+	return findings
+}
+
+// findingPath returns the path segments of the prefixes added to err by
+// prefixErrorf, outermost first.
+func findingPath(err error) []any {
+	var path []any
+	for {
+		switch e := err.(type) {
+		case pathError:
+			path = append(path, pathSegments(e.prefix)...)
+			err = e.err
+		case checkError:
+			err = e.err
+		default:
+			return path
+		}
+	}
+}
+
+// pathSegments splits a prefix like "resource.attributes" or "[2].line[0]"
+// into its field names and indices.
+func pathSegments(prefix string) []any {
+	var segments []any
+	for _, part := range strings.Split(prefix, ".") {
+		name, rest, _ := strings.Cut(part, "[")
+		if name != "" {
+			segments = append(segments, name)
+		}
+		for rest != "" {
+			var idx string
+			idx, rest, _ = strings.Cut(rest, "]")
+			if n, err := strconv.Atoi(idx); err == nil {
+				segments = append(segments, n)
+			} else {
+				segments = append(segments, idx)
+			}
+			rest = strings.TrimPrefix(rest, "[")
+		}
+	}
+	return segments
+}
+
+// Check runs all enabled checks on data and returns the joined errors.
+// Warnings are not returned, use Report to get them too.
+func (c ConformanceChecker) Check(data *profiles.ProfilesData) error {
+	var errs []error
+	for _, err := range flattenErrors(c.check(data)) {
+		if !isWarning(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// warning marks an error found by a check as a SeverityWarning finding.
+type warning struct {
+	err error
+}
+
+func (w warning) Error() string { return w.err.Error() }
+func (w warning) Unwrap() error { return w.err }
+
+func warnf(format string, args ...any) error {
+	return warning{err: fmt.Errorf(format, args...)}
+}
+
+func isWarning(err error) bool {
+	var w warning
+	return errors.As(err, &w)
+}
+
+// checkError attributes an error to the check that found it, see
+// Finding.Check.
+type checkError struct {
+	check string
+	err   error
+}
+
+func (e checkError) Error() string { return e.err.Error() }
+func (e checkError) Unwrap() error { return e.err }
+
+// inCheck attributes every error joined in err to the check name, unless a
+// nested check already claimed it, e.g. the index-range errors of
+// checkIndex within checkKeyValues.
+func inCheck(name string, err error) error {
+	errs := flattenErrors(err)
+	for i, e := range errs {
+		var ce checkError
+		if !errors.As(e, &ce) {
+			errs[i] = checkError{check: name, err: e}
+		}
+	}
+	return errors.Join(errs...)
+}
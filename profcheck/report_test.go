@@ -0,0 +1,149 @@
+package profcheck
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	profiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func TestReport(t *testing.T) {
+	newData := func(profs ...*profiles.Profile) *profiles.ProfilesData {
+		return &profiles.ProfilesData{
+			Dictionary: &profiles.ProfilesDictionary{
+				MappingTable:   []*profiles.Mapping{{}},
+				LocationTable:  []*profiles.Location{{}},
+				FunctionTable:  []*profiles.Function{{}},
+				LinkTable:      []*profiles.Link{{}},
+				StringTable:    []string{""},
+				AttributeTable: []*profiles.KeyValueAndUnit{{}},
+				StackTable:     []*profiles.Stack{{}},
+			},
+			ResourceProfiles: []*profiles.ResourceProfiles{{
+				ScopeProfiles: []*profiles.ScopeProfiles{{
+					Profiles: profs,
+				}},
+			}},
+		}
+	}
+
+	for _, tc := range []struct {
+		desc    string
+		checker ConformanceChecker
+		data    *profiles.ProfilesData
+		want    []Finding
+		wantErr string
+	}{{
+		desc:    "known payload format",
+		checker: ConformanceChecker{CheckPayloadFormat: true},
+		data:    newData(&profiles.Profile{OriginalPayloadFormat: "pprof", OriginalPayload: []byte("x")}),
+	}, {
+		desc:    "unknown payload format",
+		checker: ConformanceChecker{CheckPayloadFormat: true},
+		data:    newData(&profiles.Profile{}, &profiles.Profile{OriginalPayloadFormat: "xyz"}),
+		want: []Finding{{
+			Severity: SeverityWarning,
+			Check:    "payload-format",
+			Path:     []any{"resource_profiles", 0, "scope_profiles", 0, "profile", 1, "original_payload_format"},
+			Message:  `resource_profiles[0]: scope_profiles[0]: profile[1]: original_payload_format: unknown format "xyz", known formats are pprof, jfr, perf`,
+		}},
+	}, {
+		desc:    "custom allowed payload formats",
+		checker: ConformanceChecker{CheckPayloadFormat: true, AllowedPayloadFormats: []string{"xyz"}},
+		data:    newData(&profiles.Profile{OriginalPayloadFormat: "xyz"}),
+	}, {
+		desc:    "payload without format",
+		checker: ConformanceChecker{CheckPayloadFormat: true},
+		data:    newData(&profiles.Profile{OriginalPayload: []byte("x")}),
+		want: []Finding{{
+			Severity: SeverityError,
+			Check:    "payload-format",
+			Path:     []any{"resource_profiles", 0, "scope_profiles", 0, "profile", 0, "original_payload_format"},
+			Message:  "resource_profiles[0]: scope_profiles[0]: profile[0]: original_payload_format: must be set if original_payload is set",
+		}},
+		wantErr: "original_payload_format: must be set if original_payload is set",
+	}, {
+		desc:    "profile attribute repeating resource attribute",
+		checker: ConformanceChecker{CheckRedundantProfileAttributes: true},
+		data: func() *profiles.ProfilesData {
+			data := newData(&profiles.Profile{AttributeIndices: []int32{1, 2}})
+			data.Dictionary.StringTable = []string{"", "service.name", "checkout", "host.name"}
+			data.Dictionary.AttributeTable = []*profiles.KeyValueAndUnit{
+				{},
+				{KeyStrindex: 1, Value: &common.AnyValue{Value: &common.AnyValue_StringValueStrindex{StringValueStrindex: 2}}},
+				{KeyStrindex: 3, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "b"}}},
+			}
+			data.ResourceProfiles[0].Resource = &resource.Resource{Attributes: []*common.KeyValue{
+				{Key: "service.name", Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "checkout"}}},
+				{Key: "host.name", Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "a"}}},
+			}}
+			return data
+		}(),
+		want: []Finding{{
+			Severity: SeverityWarning,
+			Check:    "redundant-profile-attributes",
+			Path:     []any{"resource_profiles", 0, "scope_profiles", 0, "profile", 0, "attribute_indices"},
+			Message:  `resource_profiles[0]: scope_profiles[0]: profile[0]: attribute_indices: [0]: "service.name" repeats the resource attribute with the same value`,
+		}}}, {
+		desc: "payload format check disabled",
+		data: newData(&profiles.Profile{OriginalPayload: []byte("x")}),
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := tc.checker.Report(tc.data)
+			if len(got) != len(tc.want) {
+				t.Fatalf("Report(): got %v, want %v", got, tc.want)
+			}
+			for i := range got {
+				if !reflect.DeepEqual(got[i], tc.want[i]) {
+					t.Errorf("Report()[%d]: got %v, want %v", i, got[i], tc.want[i])
+				}
+			}
+
+			err := tc.checker.Check(tc.data)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("Check(): got error %q, want no error", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("Check(): got error %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestPathSegments(t *testing.T) {
+	for _, tc := range []struct {
+		prefix string
+		want   []any
+	}{
+		{"dictionary", []any{"dictionary"}},
+		{"resource.attributes", []any{"resource", "attributes"}},
+		{"resource_profiles[3]", []any{"resource_profiles", 3}},
+		{"[2].line[0]", []any{2, "line", 0}},
+		{"[1].value.string_value_strindex", []any{1, "value", "string_value_strindex"}},
+	} {
+		if got := pathSegments(tc.prefix); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("pathSegments(%q): got %v, want %v", tc.prefix, got, tc.want)
+		}
+	}
+}
+
+func TestFindingJSON(t *testing.T) {
+	f := Finding{
+		Severity: SeverityWarning,
+		Check:    "index-range",
+		Path:     []any{"dictionary", "mapping_table", 1},
+		Message:  "dictionary: mapping_table: [1]: index 5 is out of range [0..2)",
+	}
+	got, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"severity":"warning","check":"index-range","path":["dictionary","mapping_table",1],"message":"dictionary: mapping_table: [1]: index 5 is out of range [0..2)"}`
+	if string(got) != want {
+		t.Errorf("json.Marshal(): got %s, want %s", got, want)
+	}
+}
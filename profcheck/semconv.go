@@ -0,0 +1,96 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profcheck
+
+import (
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+// valueKind is the type of the value held by an AnyValue, as named by the
+// semantic conventions.
+type valueKind string
+
+const (
+	kindString valueKind = "string"
+	kindInt    valueKind = "int"
+	kindDouble valueKind = "double"
+	kindBool   valueKind = "boolean"
+	kindArray  valueKind = "array"
+	kindKvList valueKind = "kvlist"
+	kindBytes  valueKind = "bytes"
+	kindEmpty  valueKind = "empty"
+)
+
+// semconvValueKinds maps well-known semantic convention attribute keys that
+// commonly appear in profiles to the type their value must have.
+var semconvValueKinds = map[string]valueKind{
+	"code.file.path":                      kindString,
+	"code.function.name":                  kindString,
+	"code.line.number":                    kindInt,
+	"container.id":                        kindString,
+	"host.name":                           kindString,
+	"k8s.container.name":                  kindString,
+	"k8s.namespace.name":                  kindString,
+	"k8s.pod.name":                        kindString,
+	"process.command":                     kindString,
+	"process.executable.build_id.gnu":     kindString,
+	"process.executable.build_id.go":      kindString,
+	"process.executable.build_id.htlhash": kindString,
+	"process.executable.name":             kindString,
+	"process.executable.path":             kindString,
+	"process.parent_pid":                  kindInt,
+	"process.pid":                         kindInt,
+	"process.runtime.name":                kindString,
+	"process.runtime.version":             kindString,
+	"profile.frame.type":                  kindString,
+	"service.name":                        kindString,
+	"service.namespace":                   kindString,
+	"service.version":                     kindString,
+	"thread.id":                           kindInt,
+	"thread.name":                         kindString,
+}
+
+// linkAttributeKeys are sample attribute keys that correlate a sample with a
+// span. There is no convention for them yet, these are the spellings seen in
+// producers. A sample carrying them is expected to reference a link too.
+var linkAttributeKeys = map[string]bool{
+	"span.id":  true,
+	"span_id":  true,
+	"trace.id": true,
+	"trace_id": true,
+}
+
+// anyValueKind returns the kind of value held by v. Strings referenced via
+// the string table count as strings.
+func anyValueKind(v *common.AnyValue) valueKind {
+	switch v.GetValue().(type) {
+	case *common.AnyValue_StringValue, *common.AnyValue_StringValueStrindex:
+		return kindString
+	case *common.AnyValue_IntValue:
+		return kindInt
+	case *common.AnyValue_DoubleValue:
+		return kindDouble
+	case *common.AnyValue_BoolValue:
+		return kindBool
+	case *common.AnyValue_ArrayValue:
+		return kindArray
+	case *common.AnyValue_KvlistValue:
+		return kindKvList
+	case *common.AnyValue_BytesValue:
+		return kindBytes
+	default:
+		return kindEmpty
+	}
+}
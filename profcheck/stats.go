@@ -0,0 +1,71 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profcheck
+
+import (
+	"fmt"
+
+	profiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
+)
+
+// Stats summarizes the structure of a ProfilesData proto.
+type Stats struct {
+	ResourceProfiles int
+	ScopeProfiles    int
+	Profiles         int
+	Samples          int
+
+	MappingTable   int
+	LocationTable  int
+	FunctionTable  int
+	LinkTable      int
+	StringTable    int
+	AttributeTable int
+	StackTable     int
+}
+
+// ComputeStats counts the resource, scope and profile messages, samples and
+// dictionary table entries in data.
+func ComputeStats(data *profiles.ProfilesData) Stats {
+	dict := data.GetDictionary()
+	s := Stats{
+		ResourceProfiles: len(data.GetResourceProfiles()),
+		MappingTable:     len(dict.GetMappingTable()),
+		LocationTable:    len(dict.GetLocationTable()),
+		FunctionTable:    len(dict.GetFunctionTable()),
+		LinkTable:        len(dict.GetLinkTable()),
+		StringTable:      len(dict.GetStringTable()),
+		AttributeTable:   len(dict.GetAttributeTable()),
+		StackTable:       len(dict.GetStackTable()),
+	}
+	for _, rp := range data.GetResourceProfiles() {
+		s.ScopeProfiles += len(rp.GetScopeProfiles())
+		for _, sp := range rp.GetScopeProfiles() {
+			s.Profiles += len(sp.GetProfiles())
+			for _, prof := range sp.GetProfiles() {
+				s.Samples += len(prof.GetSamples())
+			}
+		}
+	}
+	return s
+}
+
+// String returns the stats as a single line of key=value pairs.
+func (s Stats) String() string {
+	return fmt.Sprintf("resource_profiles=%d scope_profiles=%d profiles=%d samples=%d "+
+		"mapping_table=%d location_table=%d function_table=%d link_table=%d string_table=%d attribute_table=%d stack_table=%d",
+		s.ResourceProfiles, s.ScopeProfiles, s.Profiles, s.Samples,
+		s.MappingTable, s.LocationTable, s.FunctionTable, s.LinkTable, s.StringTable, s.AttributeTable, s.StackTable)
+}
@@ -0,0 +1,36 @@
+package profcheck
+
+import (
+	"testing"
+
+	profiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
+)
+
+func TestComputeStats(t *testing.T) {
+	data := &profiles.ProfilesData{
+		Dictionary: &profiles.ProfilesDictionary{
+			MappingTable:   []*profiles.Mapping{{}},
+			LocationTable:  []*profiles.Location{{}, {}},
+			FunctionTable:  []*profiles.Function{{}},
+			LinkTable:      []*profiles.Link{{}},
+			StringTable:    []string{"", "a", "b"},
+			AttributeTable: []*profiles.KeyValueAndUnit{{}},
+			StackTable:     []*profiles.Stack{{}},
+		},
+		ResourceProfiles: []*profiles.ResourceProfiles{{
+			ScopeProfiles: []*profiles.ScopeProfiles{{
+				Profiles: []*profiles.Profile{{
+					Samples: []*profiles.Sample{{}, {}},
+				}, {
+					Samples: []*profiles.Sample{{}},
+				}},
+			}},
+		}, {}},
+	}
+	got := ComputeStats(data).String()
+	want := "resource_profiles=2 scope_profiles=1 profiles=2 samples=3 " +
+		"mapping_table=1 location_table=2 function_table=1 link_table=1 string_table=3 attribute_table=1 stack_table=1"
+	if got != want {
+		t.Errorf("ComputeStats(): got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profcheck
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	profiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
+)
+
+// WriteText writes a canonical text rendering of data to w, to diff profiles
+// in reviews and tests. Every dictionary reference is resolved, so the text
+// doesn't depend on the layout of the dictionary, and string values render
+// the same whether they are inlined or referenced. Attributes are sorted by
+// key and the samples of a profile by their rendering, as neither is ordered.
+// Resource profiles, scope profiles and profiles keep their order. Two
+// profiles that only differ in these respects render to the same bytes.
+//
+// Invalid references render as e.g. <invalid stack_index 7> rather than
+// failing, so that non-conformant profiles can be diffed too.
+func WriteText(w io.Writer, data *profiles.ProfilesData) error {
+	t := textRenderer{dict: data.GetDictionary()}
+	for i, rp := range data.GetResourceProfiles() {
+		t.line(0, "resource_profiles[%d]:", i)
+		if attrs := t.keyValues(rp.GetResource().GetAttributes()); attrs != "" {
+			t.line(1, "resource: %s", attrs)
+		}
+		if n := rp.GetResource().GetDroppedAttributesCount(); n != 0 {
+			t.line(1, "resource.dropped_attributes_count: %d", n)
+		}
+		if rp.GetSchemaUrl() != "" {
+			t.line(1, "schema_url: %s", rp.GetSchemaUrl())
+		}
+		for j, sp := range rp.GetScopeProfiles() {
+			t.line(1, "scope_profiles[%d]:", j)
+			if scope := sp.GetScope(); scope != nil {
+				t.line(2, "scope: %q %q", scope.GetName(), scope.GetVersion())
+				if attrs := t.keyValues(scope.GetAttributes()); attrs != "" {
+					t.line(2, "scope.attributes: %s", attrs)
+				}
+			}
+			if sp.GetSchemaUrl() != "" {
+				t.line(2, "schema_url: %s", sp.GetSchemaUrl())
+			}
+			for k, p := range sp.GetProfiles() {
+				t.line(2, "profile[%d]:", k)
+				t.profile(p)
+			}
+		}
+	}
+	_, err := io.WriteString(w, t.b.String())
+	return err
+}
+
+// textRenderer builds the text of WriteText.
+type textRenderer struct {
+	b    strings.Builder
+	dict *profiles.ProfilesDictionary
+}
+
+// line writes a line indented by depth levels.
+func (t *textRenderer) line(depth int, format string, args ...any) {
+	t.b.WriteString(strings.Repeat("  ", depth))
+	fmt.Fprintf(&t.b, format, args...)
+	t.b.WriteByte('\n')
+}
+
+func (t *textRenderer) profile(p *profiles.Profile) {
+	if len(p.ProfileId) > 0 {
+		t.line(3, "profile_id: %s", hex.EncodeToString(p.ProfileId))
+	}
+	t.line(3, "sample_type: %s", t.valueType(p.GetSampleType()))
+	t.line(3, "period_type: %s period: %d", t.valueType(p.GetPeriodType()), p.Period)
+	t.line(3, "time_unix_nano: %d duration_nano: %d", p.TimeUnixNano, p.DurationNano)
+	if attrs := t.attributes(p.AttributeIndices); attrs != "" {
+		t.line(3, "attributes: %s", attrs)
+	}
+	if p.DroppedAttributesCount != 0 {
+		t.line(3, "dropped_attributes_count: %d", p.DroppedAttributesCount)
+	}
+	if p.OriginalPayloadFormat != "" || len(p.OriginalPayload) > 0 {
+		sum := sha256.Sum256(p.OriginalPayload)
+		t.line(3, "original_payload: %q %d bytes sha256:%s", p.OriginalPayloadFormat, len(p.OriginalPayload), hex.EncodeToString(sum[:]))
+	}
+	samples := make([]string, len(p.Samples))
+	for i, s := range p.Samples {
+		samples[i] = t.sample(s)
+	}
+	slices.Sort(samples)
+	for _, s := range samples {
+		t.b.WriteString(s)
+	}
+}
+
+// sample returns the lines of s, the sample line followed by its frames, leaf
+// first.
+func (t *textRenderer) sample(s *profiles.Sample) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "      sample: values=%v", s.Values)
+	if len(s.TimestampsUnixNano) > 0 {
+		fmt.Fprintf(&b, " timestamps_unix_nano=%v", s.TimestampsUnixNano)
+	}
+	if attrs := t.attributes(s.AttributeIndices); attrs != "" {
+		fmt.Fprintf(&b, " attributes: %s", attrs)
+	}
+	if s.LinkIndex != 0 {
+		if link := entry(t.dict.GetLinkTable(), s.LinkIndex); link != nil {
+			fmt.Fprintf(&b, " link: trace_id=%s span_id=%s", hex.EncodeToString(link.TraceId), hex.EncodeToString(link.SpanId))
+		} else {
+			fmt.Fprintf(&b, " link: <invalid link_index %d>", s.LinkIndex)
+		}
+	}
+	b.WriteByte('\n')
+	stack := entry(t.dict.GetStackTable(), s.StackIndex)
+	if stack == nil {
+		fmt.Fprintf(&b, "        <invalid stack_index %d>\n", s.StackIndex)
+		return b.String()
+	}
+	for _, locIdx := range stack.LocationIndices {
+		fmt.Fprintf(&b, "        %s\n", t.location(locIdx))
+	}
+	return b.String()
+}
+
+// location renders a location as its address, mapping file and lines,
+// innermost inlined line first.
+func (t *textRenderer) location(idx int32) string {
+	loc := entry(t.dict.GetLocationTable(), idx)
+	if loc == nil {
+		return fmt.Sprintf("<invalid location_index %d>", idx)
+	}
+	parts := []string{fmt.Sprintf("0x%x", loc.Address)}
+	if loc.MappingIndex != 0 {
+		if m := entry(t.dict.GetMappingTable(), loc.MappingIndex); m != nil {
+			parts = append(parts, fmt.Sprintf("[%s 0x%x-0x%x+0x%x]", t.str(m.FilenameStrindex), m.MemoryStart, m.MemoryLimit, m.FileOffset))
+			if attrs := t.attributes(m.AttributeIndices); attrs != "" {
+				parts = append(parts, fmt.Sprintf("mapping attributes: %s", attrs))
+			}
+		} else {
+			parts = append(parts, fmt.Sprintf("<invalid mapping_index %d>", loc.MappingIndex))
+		}
+	}
+	for _, line := range loc.Lines {
+		fn := entry(t.dict.GetFunctionTable(), line.FunctionIndex)
+		if fn == nil {
+			parts = append(parts, fmt.Sprintf("<invalid function_index %d>", line.FunctionIndex))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s (%s) %s:%d:%d start_line=%d",
+			t.str(fn.NameStrindex), t.str(fn.SystemNameStrindex), t.str(fn.FilenameStrindex), line.Line, line.Column, fn.StartLine))
+	}
+	if attrs := t.attributes(loc.AttributeIndices); attrs != "" {
+		parts = append(parts, fmt.Sprintf("attributes: %s", attrs))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (t *textRenderer) valueType(vt *profiles.ValueType) string {
+	return t.str(vt.GetTypeStrindex()) + "/" + t.str(vt.GetUnitStrindex())
+}
+
+// attributes renders the attribute table entries at indices, sorted.
+func (t *textRenderer) attributes(indices []int32) string {
+	parts := make([]string, len(indices))
+	for i, idx := range indices {
+		attr := entry(t.dict.GetAttributeTable(), idx)
+		if attr == nil {
+			parts[i] = fmt.Sprintf("<invalid attribute_index %d>", idx)
+			continue
+		}
+		parts[i] = t.str(attr.KeyStrindex) + "=" + t.anyValue(attr.Value)
+		if attr.UnitStrindex != 0 {
+			parts[i] += " " + t.str(attr.UnitStrindex)
+		}
+	}
+	slices.Sort(parts)
+	return strings.Join(parts, ", ")
+}
+
+// keyValues renders resource, scope and key-value list attributes, sorted.
+func (t *textRenderer) keyValues(kvs []*common.KeyValue) string {
+	parts := make([]string, len(kvs))
+	for i, kv := range kvs {
+		key := kv.Key
+		if kv.KeyStrindex != 0 {
+			key = t.str(kv.KeyStrindex)
+		}
+		parts[i] = key + "=" + t.anyValue(kv.Value)
+	}
+	slices.Sort(parts)
+	return strings.Join(parts, ", ")
+}
+
+func (t *textRenderer) anyValue(v *common.AnyValue) string {
+	switch v := v.GetValue().(type) {
+	case nil:
+		return "<unset>"
+	case *common.AnyValue_StringValue:
+		return strconv.Quote(v.StringValue)
+	case *common.AnyValue_StringValueStrindex:
+		if s, ok := lookupString(t.dict, v.StringValueStrindex); ok {
+			return strconv.Quote(s)
+		}
+		return fmt.Sprintf("<invalid string_value_strindex %d>", v.StringValueStrindex)
+	case *common.AnyValue_BoolValue:
+		return strconv.FormatBool(v.BoolValue)
+	case *common.AnyValue_IntValue:
+		return strconv.FormatInt(v.IntValue, 10)
+	case *common.AnyValue_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'g', -1, 64)
+	case *common.AnyValue_BytesValue:
+		return "0x" + hex.EncodeToString(v.BytesValue)
+	case *common.AnyValue_ArrayValue:
+		elems := make([]string, len(v.ArrayValue.GetValues()))
+		for i, elem := range v.ArrayValue.GetValues() {
+			elems[i] = t.anyValue(elem)
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case *common.AnyValue_KvlistValue:
+		return "{" + t.keyValues(v.KvlistValue.GetValues()) + "}"
+	default:
+		return fmt.Sprintf("<unknown value %T>", v)
+	}
+}
+
+// str resolves a string table reference.
+func (t *textRenderer) str(idx int32) string {
+	if s, ok := lookupString(t.dict, idx); ok {
+		return s
+	}
+	return fmt.Sprintf("<invalid strindex %d>", idx)
+}
+
+func lookupString(dict *profiles.ProfilesDictionary, idx int32) (string, bool) {
+	strTable := dict.GetStringTable()
+	if idx < 0 || int(idx) >= len(strTable) {
+		return "", false
+	}
+	return strTable[idx], true
+}
+
+// entry returns table[idx], or nil if idx is out of range.
+func entry[T any](table []*T, idx int32) *T {
+	if idx < 0 || int(idx) >= len(table) {
+		return nil
+	}
+	return table[idx]
+}
@@ -0,0 +1,73 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profcheck
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	profiles "go.opentelemetry.io/proto/otlp/profiles/v1development"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestWriteText(t *testing.T) {
+	data := conformantProfilesData()
+	var want strings.Builder
+	if err := WriteText(&want, data); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"      sample_type: cpu/nanoseconds",
+		`      sample: values=[20000000] attributes: thread.name="worker"`,
+		"        0x1100 [/usr/bin/app 0x1000-0x2000+0x0] main (main) main.go:12:0 start_line=10",
+	} {
+		if !strings.Contains(want.String(), line+"\n") {
+			t.Errorf("WriteText(): missing line %q in\n%s", line, want.String())
+		}
+	}
+
+	// The same profile with another dictionary layout, the samples in
+	// reverse order and the attribute value referenced instead of inlined.
+	same := proto.CloneOf(data)
+	dict := same.Dictionary
+	dict.StringTable = append(dict.StringTable, "worker")
+	dict.AttributeTable = append(dict.AttributeTable, &profiles.KeyValueAndUnit{
+		KeyStrindex: 6,
+		Value:       &common.AnyValue{Value: &common.AnyValue_StringValueStrindex{StringValueStrindex: 7}},
+	})
+	dict.StackTable = append(dict.StackTable, proto.CloneOf(dict.StackTable[1]))
+	p := firstProfile(same)
+	slices.Reverse(p.Samples)
+	p.Samples[0].AttributeIndices = []int32{2}
+	p.Samples[1].StackIndex = 2
+	var got strings.Builder
+	if err := WriteText(&got, same); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("WriteText(): got\n%s\nwant\n%s", got.String(), want.String())
+	}
+
+	p.Samples[0].Values[0]++
+	got.Reset()
+	if err := WriteText(&got, same); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() == want.String() {
+		t.Error("WriteText(): got the same text for a different sample value")
+	}
+}
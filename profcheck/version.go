@@ -0,0 +1,99 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profcheck
+
+import (
+	"runtime/debug"
+)
+
+// ProtoModule is the module of the generated profiles proto the checks are
+// compiled against. Captures produced with a different version of the schema
+// may fail to decode or be checked against fields they don't use. To check
+// them against their own schema, build profcheck with that version of the
+// module, e.g. with a replace directive in go.mod.
+const ProtoModule = "go.opentelemetry.io/proto/otlp/profiles/v1development"
+
+// Module is the module of profcheck itself.
+const Module = "github.com/open-telemetry/sig-profiling/profcheck"
+
+// ProtoVersion returns the version of ProtoModule the binary was built with,
+// or "unknown" if the build information is not available.
+func ProtoVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	return depVersion(info, ProtoModule)
+}
+
+// Version returns the version of profcheck the binary was built with: the
+// module version if it was installed with go install or is a dependency of
+// the main module, the VCS revision if it was built from a checkout, or
+// "(devel)" if neither is known. It returns "unknown" if the build
+// information is not available.
+func Version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	return buildVersion(info)
+}
+
+// buildVersion returns the version of Module in info, see Version.
+func buildVersion(info *debug.BuildInfo) string {
+	if info.Main.Path != Module {
+		return depVersion(info, Module)
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	var revision string
+	var modified bool
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	switch {
+	case revision == "":
+		return "(devel)"
+	case modified:
+		return revision + "+dirty"
+	default:
+		return revision
+	}
+}
+
+// depVersion returns the version of the dependency path in info, or
+// "unknown" if info has no such dependency.
+func depVersion(info *debug.BuildInfo, path string) string {
+	for _, dep := range info.Deps {
+		if dep.Path != path {
+			continue
+		}
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		if dep.Version == "" {
+			// A replacement by a local directory has no version.
+			return "(devel)"
+		}
+		return dep.Version
+	}
+	return "unknown"
+}
@@ -0,0 +1,63 @@
+package profcheck
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestProtoVersion(t *testing.T) {
+	// Test binaries carry the build information of their dependencies.
+	if got := ProtoVersion(); got == "unknown" {
+		t.Errorf("ProtoVersion() = %q, want the version of %s from go.mod", got, ProtoModule)
+	}
+}
+
+func TestBuildVersion(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		info *debug.BuildInfo
+		want string
+	}{{
+		desc: "go install",
+		info: &debug.BuildInfo{Main: debug.Module{Path: Module, Version: "v0.2.0"}},
+		want: "v0.2.0",
+	}, {
+		desc: "checkout",
+		info: &debug.BuildInfo{
+			Main:     debug.Module{Path: Module, Version: "(devel)"},
+			Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: "abc123"}, {Key: "vcs.modified", Value: "false"}},
+		},
+		want: "abc123",
+	}, {
+		desc: "checkout with local changes",
+		info: &debug.BuildInfo{
+			Main:     debug.Module{Path: Module, Version: "(devel)"},
+			Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: "abc123"}, {Key: "vcs.modified", Value: "true"}},
+		},
+		want: "abc123+dirty",
+	}, {
+		desc: "no vcs information",
+		info: &debug.BuildInfo{Main: debug.Module{Path: Module, Version: "(devel)"}},
+		want: "(devel)",
+	}, {
+		desc: "dependency",
+		info: &debug.BuildInfo{
+			Main: debug.Module{Path: "example.com/tool"},
+			Deps: []*debug.Module{{Path: Module, Version: "v0.1.0"}},
+		},
+		want: "v0.1.0",
+	}, {
+		desc: "replaced dependency",
+		info: &debug.BuildInfo{
+			Main: debug.Module{Path: "example.com/tool"},
+			Deps: []*debug.Module{{Path: Module, Version: "v0.1.0", Replace: &debug.Module{Path: "../profcheck"}}},
+		},
+		want: "(devel)",
+	}} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := buildVersion(tc.info); got != tc.want {
+				t.Errorf("buildVersion() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}